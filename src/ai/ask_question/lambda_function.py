@@ -4,7 +4,7 @@ import json
 import os
 
 
-def ask_question(topic):
+def ask_question(topic, difficulty="Intermediate"):
 
     client = OpenAI(api_key=os.environ.get("OPENAI_API_KEY"))
 
@@ -12,7 +12,7 @@ def ask_question(topic):
         messages=[
             {
                 "role": "user",
-                "content": "Give me a question about " + topic + ". No context",
+                "content": "Give me a " + difficulty.lower() + " level question about " + topic + ". No context",
             }
         ],
         model="gpt-4o-mini",
@@ -26,7 +26,8 @@ def ask_question(topic):
 def lambda_handler(event, context):
     # Extract topic from query parameters
     topic = event['queryStringParameters']['topic']
-    question = ask_question(topic)
+    difficulty = event['queryStringParameters'].get('difficulty') or "Intermediate"
+    question = ask_question(topic, difficulty)
     return {
         'statusCode': 200,
         'body': json.dumps({'question': question})
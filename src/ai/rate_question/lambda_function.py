@@ -3,31 +3,64 @@ import os
 
 from pydantic import BaseModel
 from openai import OpenAI
+from typing import List, Optional
+
+
+class RubricCriterion(BaseModel):
+    name: str
+    description: str
+    weight: float
+
+
+class Rubric(BaseModel):
+    criteria: List[RubricCriterion]
+    referenceAnswer: Optional[str] = None
 
 
 class RateQuestionRequest(BaseModel):
     question: str
     answer: str
+    rubric: Optional[Rubric] = None
+
+
+class CriterionScore(BaseModel):
+    criterion: str
+    score: int
+    justification: str
 
 
 class RateQuestionResponse(BaseModel):
-    rating: int
     insight: str
+    scores: List[CriterionScore]
 
 client = OpenAI(api_key=os.environ.get("OPENAI_API_KEY"))
 
 
+def grading_prompt(rubric: Optional[Rubric]) -> str:
+    prompt = "Grade the answer to the following question."
+    if rubric is None:
+        return prompt + " Score it from 1 to 10 on the criterion Correctness."
+
+    prompt += " Score it from 1 to 10 on each of these criteria:\n"
+    for criterion in rubric.criteria:
+        prompt += "- " + criterion.name + ": " + criterion.description + "\n"
+    if rubric.referenceAnswer:
+        prompt += "A reference answer is: " + rubric.referenceAnswer + "\n"
+    return prompt
+
+
 def rate_question(request: RateQuestionRequest) -> str:
-    completion = client.chat.completions.create(
+    # The rating is derived from the scores by the backend, only the scores and insight are asked for
+    completion = client.beta.chat.completions.parse(
         model="gpt-4o-mini",
         messages=[
-            {"role": "system",
-             "content": "Return a json object with rating and insight of the following question and answer"},
+            {"role": "system", "content": grading_prompt(request.rubric)},
             {"role": "user", "content": "Question: " + request.question + "\nAnswer: " + request.answer},
         ],
+        response_format=RateQuestionResponse,
     )
 
-    return completion.choices[0].message.content
+    return completion.choices[0].message.parsed.json()
 
 
 def lambda_handler(event, context):
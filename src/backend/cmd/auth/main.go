@@ -2,6 +2,8 @@ package main
 
 import (
 	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
 	"backend/internal/repository"
 	"backend/internal/services"
 	"backend/internal/utils"
@@ -15,6 +17,7 @@ import (
 	"gopkg.in/gomail.v2"
 	"log"
 	"os"
+	"time"
 )
 
 func main() {
@@ -30,6 +33,25 @@ func main() {
 		panic("Couldn't connect to dynamo: " + err.Error())
 	}
 
+	streakService = services.NewStreakService()
+	topicResolver = services.NewTopicResolver(
+		repository.NewDynamoDBTopicRepository(sess, "Qriosity-Topics"),
+		repository.NewDynamoDBTopicAliasRepository(sess, "Qriosity-TopicAliases"),
+	)
+	leaderboardService = services.NewLeaderboardService(repository.NewDynamoDBLeaderboardRepository(sess, "Qriosity-Leaderboards"))
+	achievementService, err = services.NewAchievementService()
+	if err != nil {
+		log.Fatalf("Failed to load achievements: %v", err)
+	}
+	eventPublisher = services.NewEventPublisher(
+		repository.NewDynamoDBOutboxRepository(sess, "Qriosity-Outbox"),
+		services.NewActivitySubscribers(userRepository, achievementService, leaderboardService),
+	)
+	cursorCodec, err = pagination.NewCodecFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure cursors: %v", err)
+	}
+
 	authService = *services.NewCognitoAuthService(
 		os.Getenv("COGNITO_APP_CLIENT_ID"),
 		os.Getenv("COGNITO_USER_POOL_ID"),
@@ -63,6 +85,14 @@ func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, er
 			return handleSendFeedback(ctx, event.Arguments)
 		case "updateUser":
 			return utils.SecureResolver(ctx, event, handleUpdateUser)
+		case "addFriend":
+			return utils.SecureSelfResolver(ctx, event, handleAddFriend)
+		case "acceptFriend":
+			return utils.SecureSelfResolver(ctx, event, handleAcceptFriend)
+		case "declineFriend":
+			return utils.SecureSelfResolver(ctx, event, handleDeclineFriend)
+		case "removeFriend":
+			return utils.SecureSelfResolver(ctx, event, handleRemoveFriend)
 		}
 	}
 
@@ -72,6 +102,13 @@ func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, er
 var (
 	userRepository repository.IUserRepository
 	authService    services.CognitoAuthService
+	streakService  *services.StreakService
+
+	leaderboardService *services.LeaderboardService
+	achievementService *services.AchievementService
+	eventPublisher     events.Publisher
+	cursorCodec        *pagination.Codec
+	topicResolver      *services.TopicResolver
 )
 
 type LoginArguments struct {
@@ -122,6 +159,11 @@ func handleRegister(ctx context.Context, args json.RawMessage) (json.RawMessage,
 		return nil, err
 	}
 
+	topics, err := topicResolver.Resolve(ctx, registerArgs.Topics)
+	if err != nil {
+		return nil, err
+	}
+
 	cognitoResponse, err := authService.SignUp(registerArgs.Email, registerArgs.Username, registerArgs.Password)
 	if err != nil {
 		return nil, err
@@ -134,7 +176,7 @@ func handleRegister(ctx context.Context, args json.RawMessage) (json.RawMessage,
 		Name:                    *registeredUsername,
 		Role:                    0,
 		Email:                   registerArgs.Email,
-		Topics:                  registerArgs.Topics,
+		Topics:                  topics,
 		DailyChallengeAvailable: true,
 	}
 
@@ -143,6 +185,15 @@ func handleRegister(ctx context.Context, args json.RawMessage) (json.RawMessage,
 		return nil, err
 	}
 
+	err = eventPublisher.Publish(ctx, events.UserRegistered{
+		UserID: user.Name,
+		Email:  user.Email,
+		Topics: user.Topics,
+	})
+	if err != nil {
+		log.Printf("Error publishing registration of %s: %v", user.Name, err)
+	}
+
 	response, err := json.Marshal(map[string]string{"username": *registeredUsername})
 	if err != nil {
 		return nil, err
@@ -152,9 +203,27 @@ func handleRegister(ctx context.Context, args json.RawMessage) (json.RawMessage,
 }
 
 func handleGetUsers(ctx context.Context, message json.RawMessage) (json.RawMessage, error) {
-	users := userRepository.GetUsers()
+	var arguments pagination.Arguments
+	if err := json.Unmarshal(message, &arguments); err != nil {
+		return nil, err
+	}
+
+	request, err := cursorCodec.Request("users", arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := userRepository.ListUsers(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, "users", request, page)
+	if err != nil {
+		return nil, err
+	}
 
-	response, err := json.Marshal(users)
+	response, err := json.Marshal(connection)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +277,16 @@ func handleUpdateUser(ctx context.Context, args json.RawMessage) (json.RawMessag
 		return nil, err
 	}
 
+	// Privacy settings are only changed when sent, a missing flag must not opt the user back in
+	var privacyArgs struct {
+		Input struct {
+			LeaderboardOptOut *bool `json:"leaderboardOptOut"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(args, &privacyArgs); err != nil {
+		return nil, err
+	}
+
 	// First fetch the user
 	user, err := userRepository.GetUserByName(userEditArgs.Input.Name)
 	if err != nil {
@@ -215,10 +294,30 @@ func handleUpdateUser(ctx context.Context, args json.RawMessage) (json.RawMessag
 	}
 
 	// Updatable fields
-	user.Topics = userEditArgs.Input.Topics
+	user.Topics, err = topicResolver.Resolve(ctx, userEditArgs.Input.Topics)
+	if err != nil {
+		return nil, err
+	}
 	user.Role = userEditArgs.Input.Role
 	user.Username = userEditArgs.Input.Username
 
+	if userEditArgs.Input.Timezone != "" {
+		if _, err := time.LoadLocation(userEditArgs.Input.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %s", userEditArgs.Input.Timezone)
+		}
+		user.Timezone = userEditArgs.Input.Timezone
+	}
+
+	if optOut := privacyArgs.Input.LeaderboardOptOut; optOut != nil && *optOut != user.LeaderboardOptOut {
+		if *optOut {
+			if err := leaderboardService.OptOut(ctx, user); err != nil {
+				return nil, err
+			}
+		} else {
+			user.LeaderboardOptOut = false
+		}
+	}
+
 	_, err = userRepository.UpsertUser(*user)
 	if err != nil {
 		return nil, err
@@ -247,6 +346,10 @@ func handleGetUserByName(ctx context.Context, args json.RawMessage) (json.RawMes
 
 	fmt.Printf("User %+v\n", user)
 
+	// A streak that can no longer be continued is shown as lost
+	user.DailyChallengeStreak = streakService.Current(user)
+	user.Level = achievementService.Level(user.XP)
+
 	response, err := json.Marshal(user)
 	if err != nil {
 		return nil, err
@@ -287,3 +390,159 @@ func handleSendFeedback(ctx context.Context, args json.RawMessage) (json.RawMess
 
 	return response, nil
 }
+
+type FriendArguments struct {
+	UserID string `json:"userId"`
+	Friend string `json:"friend"`
+}
+
+// handleAddFriend asks the friend to be friends. When the friend already asked the user, the
+// request is accepted instead
+func handleAddFriend(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var friendArgs FriendArguments
+	if err := json.Unmarshal(args, &friendArgs); err != nil {
+		return nil, err
+	}
+
+	if friendArgs.UserID == friendArgs.Friend {
+		return nil, errors.New("users cannot befriend themselves")
+	}
+
+	user, err := userRepository.GetUserByName(friendArgs.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	friend, err := userRepository.GetUserByName(friendArgs.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case contains(user.Friends, friend.Name):
+	case contains(user.FriendRequests, friend.Name):
+		if err := befriend(user, friend); err != nil {
+			return nil, err
+		}
+	case !contains(friend.FriendRequests, user.Name):
+		friend.FriendRequests = append(friend.FriendRequests, user.Name)
+		if _, err := userRepository.UpsertUser(*friend); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.RawMessage(`{"success": true}`), nil
+}
+
+func handleAcceptFriend(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var friendArgs FriendArguments
+	if err := json.Unmarshal(args, &friendArgs); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(friendArgs.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !contains(user.FriendRequests, friendArgs.Friend) {
+		return nil, errors.New("no friend request from this user")
+	}
+
+	friend, err := userRepository.GetUserByName(friendArgs.Friend)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := befriend(user, friend); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(`{"success": true}`), nil
+}
+
+func handleDeclineFriend(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var friendArgs FriendArguments
+	if err := json.Unmarshal(args, &friendArgs); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(friendArgs.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.FriendRequests = without(user.FriendRequests, friendArgs.Friend)
+	if _, err := userRepository.UpsertUser(*user); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(`{"success": true}`), nil
+}
+
+func handleRemoveFriend(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var friendArgs FriendArguments
+	if err := json.Unmarshal(args, &friendArgs); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(friendArgs.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Friends = without(user.Friends, friendArgs.Friend)
+	if _, err := userRepository.UpsertUser(*user); err != nil {
+		return nil, err
+	}
+
+	// A friend who deleted their account has nothing left to update
+	friend, err := userRepository.GetUserByName(friendArgs.Friend)
+	if err != nil {
+		return json.RawMessage(`{"success": true}`), nil
+	}
+
+	friend.Friends = without(friend.Friends, user.Name)
+	if _, err := userRepository.UpsertUser(*friend); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(`{"success": true}`), nil
+}
+
+// befriend turns the friend's pending request to the user into a friendship on both sides
+func befriend(user, friend *domain.User) error {
+	user.FriendRequests = without(user.FriendRequests, friend.Name)
+	friend.FriendRequests = without(friend.FriendRequests, user.Name)
+	if !contains(user.Friends, friend.Name) {
+		user.Friends = append(user.Friends, friend.Name)
+	}
+	if !contains(friend.Friends, user.Name) {
+		friend.Friends = append(friend.Friends, user.Name)
+	}
+
+	if _, err := userRepository.UpsertUser(*user); err != nil {
+		return err
+	}
+	_, err := userRepository.UpsertUser(*friend)
+	return err
+}
+
+func contains(names []string, name string) bool {
+	for _, other := range names {
+		if other == name {
+			return true
+		}
+	}
+	return false
+}
+
+func without(names []string, name string) []string {
+	kept := make([]string, 0, len(names))
+	for _, other := range names {
+		if other != name {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
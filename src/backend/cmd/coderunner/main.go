@@ -0,0 +1,30 @@
+// Command coderunner runs learners' programs for code challenges, invoked by the daily lambda.
+//
+// It ships as a container image (see the Dockerfile) with the python and node runtimes, and must
+// be deployed isolated from everything else:
+//   - an execution role without any policy attached, so the credentials in its environment
+//     reach nothing
+//   - attached to private subnets without a NAT gateway or internet route, with a security
+//     group allowing no egress, so programs cannot reach the network
+//   - a reserved concurrency, so a flood of submissions cannot starve the other lambdas
+package main
+
+import (
+	"backend/internal/services"
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"time"
+)
+
+var runner = services.NewLocalCodeRunner()
+
+func Handler(ctx context.Context, request services.CodeRunRequest) (*services.RunResult, error) {
+	return runner.Run(ctx, request.Language, request.Code, request.Input, services.RunLimits{
+		Time:     time.Duration(request.TimeLimitMs) * time.Millisecond,
+		MemoryMb: request.MemoryLimitMb,
+	})
+}
+
+func main() {
+	lambda.Start(Handler)
+}
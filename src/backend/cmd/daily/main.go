@@ -1,17 +1,22 @@
 package main
 
 import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
 	"backend/internal/repository"
 	"backend/internal/services"
 	"backend/internal/utils"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/uuid"
 	"log"
-	"math/rand"
 	"os"
 	"time"
 )
@@ -29,25 +34,102 @@ func main() {
 		panic("Couldn't connect to dynamo: " + err.Error())
 	}
 
+	challengeAttemptRepository = repository.NewDynamoDBChallengeAttemptRepository(sess, "Qriosity-ChallengeAttempts")
+	reviewCardRepository = repository.NewDynamoDBReviewCardRepository(sess, "Qriosity-ReviewCards")
+	reviewScheduler = services.NewReviewScheduler()
+	topicResolver = services.NewTopicResolver(
+		repository.NewDynamoDBTopicRepository(sess, "Qriosity-Topics"),
+		repository.NewDynamoDBTopicAliasRepository(sess, "Qriosity-TopicAliases"),
+	)
+
+	achievementService, err = services.NewAchievementService()
+	if err != nil {
+		log.Fatalf("Failed to load achievements: %v", err)
+	}
+	leaderboardService := services.NewLeaderboardService(repository.NewDynamoDBLeaderboardRepository(sess, "Qriosity-Leaderboards"))
+	xapiSubscriber, err = services.NewXAPISubscriberFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure the LRS: %v", err)
+	}
+	eventPublisher = services.NewEventPublisher(
+		repository.NewDynamoDBOutboxRepository(sess, "Qriosity-Outbox"),
+		services.NewActivitySubscribers(userRepository, achievementService, leaderboardService),
+		services.NewWebhookService(
+			repository.NewDynamoDBWebhookRepository(sess, "Qriosity-Webhooks"),
+			repository.NewDynamoDBWebhookDeliveryRepository(sess, "Qriosity-WebhookDeliveries"),
+		),
+		xapiSubscriber,
+	)
+	questionBankService = services.NewQuestionBankService(
+		repository.NewDynamoDBQuestionRepository(sess, "Qriosity-Questions"),
+		repository.NewDynamoDBSeenQuestionRepository(sess, "Qriosity-SeenQuestions"),
+		topicResolver,
+	)
+	streakService = services.NewStreakService()
+	skillService = services.NewSkillService()
+	// Code runs in the isolated code runner lambda, the local runner is for development only.
+	// Without either, code challenges cannot be answered
+	var codeRunner services.ICodeRunner
+	if function := os.Getenv("CODE_RUNNER_FUNCTION"); function != "" {
+		codeRunner = services.NewLambdaCodeRunner(sess, function)
+	} else if os.Getenv("ALLOW_LOCAL_CODE_RUNNER") == "true" {
+		codeRunner = services.NewLocalCodeRunner()
+	}
+	challengeGrader = services.NewChallengeGrader(codeRunner)
 	dailyChallengeService = services.NewDailyChallengeService()
+	if endpoint := os.Getenv("LM_STUDIO_ENDPOINT"); endpoint != "" {
+		dailyChallengeService = services.NewLMStudioService(endpoint)
+	}
+
+	// Feedback is only streamed when the AppSync endpoint for the subscription is configured
+	if os.Getenv("APPSYNC_ENDPOINT") != "" {
+		feedbackPublisher = services.NewAppSyncFeedbackPublisher(sess)
+	}
+
+	cursorCodec, err = pagination.NewCodecFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure cursors: %v", err)
+	}
 
 	lambda.Start(Handler)
 }
 
 func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	// Statements buffered while handling the request must reach the LRS before the lambda
+	// freezes, failed batches are logged by the emitter
+	defer xapiSubscriber.Flush(ctx)
 
 	if err := utils.CheckAuthorization(ctx, event); err != nil {
 		return nil, err
 	}
 
 	switch event.TypeName {
+	case "Subscription":
+		switch event.FieldName {
+		case "onChallengeFeedback":
+			return handleOnChallengeFeedback(ctx, event)
+		}
 	case "Query":
-		if event.FieldName == "dailyChallenge" {
+		switch event.FieldName {
+		case "dailyChallenge":
 			return handleDailyChallengeQuery(ctx, event.Arguments)
+		case "dailyChallengeHistory":
+			return handleDailyChallengeHistory(ctx, event.Arguments)
+		case "dailyChallengeStats":
+			return handleDailyChallengeStats(ctx, event.Arguments)
+		case "reviewQueue":
+			return handleReviewQueue(ctx, event.Arguments)
 		}
 	case "Mutation":
-		if event.FieldName == "dailyChallenge" {
+		switch event.FieldName {
+		case "dailyChallenge":
 			return handleDailyChallengeMutation(ctx, event.Arguments)
+		case "addQuestion":
+			return handleAddQuestion(ctx, event)
+		case "reviewCard":
+			return handleReviewCard(ctx, event.Arguments)
+		case "quizQuestionMissed":
+			return handleQuizQuestionMissed(ctx, event.Arguments)
 		}
 	}
 
@@ -57,16 +139,33 @@ func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, er
 var (
 	userRepository        repository.IUserRepository
 	dailyChallengeService services.IDailyChallengeService
+	feedbackPublisher     services.IChallengeFeedbackPublisher
+	questionBankService   *services.QuestionBankService
+	streakService         *services.StreakService
+	skillService          *services.SkillService
+	challengeGrader       *services.ChallengeGrader
+	achievementService    *services.AchievementService
+
+	challengeAttemptRepository repository.IChallengeAttemptRepository
+	reviewCardRepository       repository.IReviewCardRepository
+	reviewScheduler            *services.ReviewScheduler
+	topicResolver              *services.TopicResolver
+	eventPublisher             events.Publisher
+	xapiSubscriber             *services.XAPISubscriber
+	cursorCodec                *pagination.Codec
 )
 
 type QueryArguments struct {
 	UserID string `json:"userId"`
 }
 
+// MutationArguments carry the answer to a challenge. The question, topic and rubric of bank
+// questions are read on the server, the free text question is only used without a questionId
 type MutationArguments struct {
-	Username string `json:"username"`
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
+	Username   string `json:"username"`
+	QuestionID string `json:"questionId"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
 }
 
 func handleDailyChallengeQuery(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
@@ -81,17 +180,49 @@ func handleDailyChallengeQuery(ctx context.Context, args json.RawMessage) (json.
 		return nil, err
 	}
 
-	i := rand.Int() % len(user.Topics)
-	problem, err := dailyChallengeService.GetQuestion(user.Topics[i])
+	// Favour the learner's weaker topics at the level they are currently at
+	topic, err := skillService.PickTopic(user)
 	if err != nil {
 		return nil, err
 	}
+	difficulty := skillService.TargetDifficulty(user, topic)
 
-	if problem.Categories == nil {
-		problem.Categories = make([]string, 0)
+	// The bank files questions under the topic the learner's name for it resolves to
+	topic, err = canonicalTopic(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve from the question bank first and only pay for a new question when it ran dry
+	question, err := questionBankService.Draw(ctx, user, topic, difficulty)
+	if err != nil {
+		return nil, err
+	}
+
+	if question == nil {
+		problem, err := dailyChallengeService.GetQuestion(topic, difficulty)
+		if err != nil {
+			return nil, err
+		}
+
+		question, _, err = questionBankService.Add(ctx, &domain.Question{
+			Topic:      topic,
+			Difficulty: difficulty,
+			Question:   problem.Question,
+			Type:       problem.Type,
+			Rubric:     problem.Rubric,
+			Source:     "generated",
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	response, err := json.Marshal(problem)
+	if err := questionBankService.MarkSeen(ctx, user.Name, question.ID); err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(question.Problem())
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +236,42 @@ func handleDailyChallengeMutation(ctx context.Context, args json.RawMessage) (js
 		return nil, err
 	}
 
-	response, err := dailyChallengeService.RateQuestion(mutationArgs.Question, mutationArgs.Answer)
+	// Questions from the bank are graded with their own rubric and count towards their topic,
+	// free text questions get the default rubric and no topic
+	var question *domain.Question
+	var rubric *domain.Rubric
+	var topic string
+	difficulty := constants.DifficultyIntermediate
+	if mutationArgs.QuestionID != "" {
+		var err error
+		question, err = questionBankService.GetByID(ctx, mutationArgs.QuestionID)
+		if err != nil {
+			return nil, err
+		}
+		mutationArgs.Question = question.Question
+		topic = question.Topic
+		difficulty = question.Difficulty
+		if question.Rubric != nil {
+			rubric = question.Rubric
+		}
+	}
+
+	// Hand the insight to onChallengeFeedback subscribers. Only LM Studio streams it while the model
+	// writes it, the AI_API_URL service answers in one response and its insight comes as one message
+	stream := services.NewFeedbackStream(ctx, feedbackPublisher, mutationArgs.Username)
+	defer stream.Close()
+
+	var response *domain.ChallengeResponse
+	var err error
+	if question != nil && services.IsDeterministic(question.Type) {
+		// Multiple choice, short answer and code challenges never reach the model
+		response, err = challengeGrader.Grade(ctx, question, mutationArgs.Answer)
+		if err == nil {
+			stream.Write(response.Insight)
+		}
+	} else {
+		response, err = dailyChallengeService.RateQuestionStream(mutationArgs.Question, mutationArgs.Answer, rubric, stream.Write)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -115,23 +281,73 @@ func handleDailyChallengeMutation(ctx context.Context, args json.RawMessage) (js
 		return nil, err
 	}
 
+	// The attempt is saved before the user, so a failure in between never spends a challenge
+	// that is missing from the history
+	attempt := &domain.ChallengeAttempt{
+		ID:         uuid.NewString(),
+		Username:   user.Name,
+		QuestionID: mutationArgs.QuestionID,
+		Topic:      topic,
+		Question:   response.Question,
+		Answer:     response.Answer,
+		Rating:     response.Rating,
+		Insight:    response.Insight,
+		Scores:     response.Scores,
+		CreatedAt:  time.Now(),
+	}
+	if err := challengeAttemptRepository.Insert(ctx, attempt); err != nil {
+		return nil, err
+	}
+
 	user.DailyChallengesRemaining -= 1
 
 	if user.DailyChallengesRemaining == 0 {
 		user.DailyChallengeAvailable = false
 	}
 
-	if response.Rating > 5 && user.LastDailyChallenge.Day() == time.Now().Day()-1 {
-		user.DailyChallengeStreak += 1
-		user.LastDailyChallenge = time.Now()
+	if response.Rating > constants.StreakMinimumRating {
+		streakService.Record(user)
 	}
 
+	if topic != "" {
+		skillService.UpdateSkill(user, topic, difficulty, response.Rating)
+	}
+
+	// Achievements are awarded with the answer rather than by a subscriber, so it can show them
+	response.Achievements = achievementService.Apply(user, services.AchievementEvent{
+		Type: constants.EventChallengeAnswered,
+		Values: map[string]int{
+			"rating": response.Rating,
+			"streak": user.DailyChallengeStreak,
+		},
+	})
+
 	_, err = userRepository.UpsertUser(*user)
 	if err != nil {
 		return nil, err
 	}
 
 	response.Left = user.DailyChallengesRemaining
+	stream.Finish(response)
+
+	// The answer is already graded and saved, a failing subscriber is not worth failing the request
+	err = eventPublisher.Publish(ctx, events.ChallengeAnswered{
+		UserID:     user.Name,
+		QuestionID: mutationArgs.QuestionID,
+		Topic:      topic,
+		Rating:     response.Rating,
+		Streak:     user.DailyChallengeStreak,
+	})
+	if err != nil {
+		log.Printf("Error publishing challenge answered by %s: %v", user.Name, err)
+	}
+
+	// Poorly answered challenges come back for review, the insight explains what was missed
+	if response.Rating < constants.ReviewRatingThreshold {
+		if _, err := scheduleReview(ctx, constants.ReviewSourceChallenge, user.Name, mutationArgs.QuestionID, response.Question, response.Insight, topic); err != nil {
+			return nil, err
+		}
+	}
 
 	resp, err := json.Marshal(response)
 	if err != nil {
@@ -139,3 +355,283 @@ func handleDailyChallengeMutation(ctx context.Context, args json.RawMessage) (js
 	}
 	return resp, nil
 }
+
+// handleOnChallengeFeedback runs when a client subscribes, only letting users follow the
+// feedback on their own answers
+func handleOnChallengeFeedback(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	caller, err := utils.CallerUsername(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Username != caller {
+		return nil, errors.New("user is not authorized to follow the challenge feedback of another user")
+	}
+
+	// Subscription resolvers return null, the messages come from publishChallengeFeedback
+	return json.RawMessage("null"), nil
+}
+
+func handleAddQuestion(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input struct {
+		Input domain.Question `json:"input"`
+	}
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	caller, err := utils.CallerUsername(event)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(caller)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role != constants.CreatorRole && user.Role != constants.AdminRole {
+		return nil, errors.New("user is not authorized to add questions")
+	}
+
+	question := input.Input
+	question.ID = ""
+	question.Source = user.Name
+	question.CreatedAt = time.Time{}
+	if question.Type == "" {
+		question.Type = constants.ChallengeTypeOpen
+	}
+
+	if err := services.ValidateQuestion(&question); err != nil {
+		return nil, err
+	}
+
+	stored, added, err := questionBankService.Add(ctx, &question)
+	if err != nil {
+		return nil, err
+	}
+
+	if !added {
+		return nil, fmt.Errorf("question is too similar to existing question %s", stored.ID)
+	}
+
+	response, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleDailyChallengeHistory(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		UserID string `json:"userId"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	// Cursors are bound to the user, one user's cannot page through another's history
+	list := "challengeHistory:" + input.UserID
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := challengeAttemptRepository.GetByUser(ctx, input.UserID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleDailyChallengeStats(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var queryArgs QueryArguments
+	if err := json.Unmarshal(args, &queryArgs); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(queryArgs.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := challengeAttemptRepository.GetAllByUser(ctx, user.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Days are counted in the learner's own timezone
+	stats := services.ComputeChallengeStats(attempts, services.UserLocation(user))
+
+	response, err := json.Marshal(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// scheduleReview adds a card for a missed question, or counts a lapse when the user already had one
+func scheduleReview(ctx context.Context, source, username, questionID, question, answer, topic string) (*domain.ReviewCard, error) {
+	topic, err := canonicalTopic(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := reviewCardRepository.Get(ctx, username, services.CardID(questionID, question))
+	if err != nil {
+		return nil, err
+	}
+
+	if card == nil {
+		card = reviewScheduler.NewCard(username, source, questionID, question, answer, topic)
+	} else {
+		card.Answer = answer
+		if err := reviewScheduler.Review(card, services.MinReviewGrade); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := reviewCardRepository.Upsert(ctx, card); err != nil {
+		return nil, err
+	}
+
+	return card, nil
+}
+
+func handleReviewQueue(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		UserID string `json:"userId"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := "reviewQueue:" + user.Name
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything due before midnight in the learner's timezone belongs to today's queue
+	page, err := reviewCardRepository.GetDue(ctx, user.Name, reviewScheduler.EndOfToday(services.UserLocation(user)), request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleReviewCard(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		UserID string `json:"userId"`
+		CardID string `json:"cardId"`
+		Grade  int    `json:"grade"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	card, err := reviewCardRepository.Get(ctx, input.UserID, input.CardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if card == nil {
+		return nil, errors.New("review card not found")
+	}
+
+	if err := reviewScheduler.Review(card, input.Grade); err != nil {
+		return nil, err
+	}
+
+	if err := reviewCardRepository.Upsert(ctx, card); err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(card)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleQuizQuestionMissed(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		UserID string `json:"userId"`
+		Topic  string `json:"topic"`
+		Quiz   struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			Question string `json:"question"`
+			Answer   string `json:"answer"`
+		} `json:"quiz"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	// Quiz ids are prefixed so they never collide with question bank ids
+	questionID := constants.ReviewSourceQuiz + "#" + input.Quiz.ID
+	card, err := scheduleReview(ctx, constants.ReviewSourceQuiz, input.UserID, questionID, input.Quiz.Question, input.Quiz.Answer, input.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(card)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// canonicalTopic resolves a topic through its aliases without creating it, empty staying empty
+func canonicalTopic(ctx context.Context, topic string) (string, error) {
+	if topic == "" {
+		return "", nil
+	}
+
+	topics, err := topicResolver.Canonical(ctx, []string{topic})
+	if err != nil || len(topics) == 0 {
+		return topic, err
+	}
+	return topics[0], nil
+}
@@ -3,30 +3,47 @@ package main
 import (
 	"backend/internal/constants"
 	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
 	"backend/internal/repository"
+	"backend/internal/search"
 	"backend/internal/services"
 	"backend/internal/utils"
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/google/uuid"
 	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 var (
-	userRepository    repository.IUserRepository
-	topicRepository   repository.ITopicRepository
-	courseRepository  repository.ICourseRepository
-	roadmapRepository repository.IRoadmapRepository
-	roadmapService    services.IRoadmapService
+	userRepository        repository.IUserRepository
+	topicRepository       repository.ITopicRepository
+	courseRepository      repository.ICourseRepository
+	roadmapRepository     repository.IRoadmapRepository
+	completionRepository  repository.IRoadmapCompletionRepository
+	roadmapViewRepository repository.IRoadmapViewRepository
+	roadmapService        services.IRoadmapService
+	enrichmentService     services.ICourseEnrichmentService
+
+	leaderboardService    *services.LeaderboardService
+	webhookService        *services.WebhookService
+	searchService         *services.SearchService
+	rankingPager          *services.RankingPager
+	feedService           *services.FeedService
+	recommendationService *services.RecommendationService
+	trendingService       *services.TrendingService
+	topicService          *services.TopicService
+	eventPublisher        events.Publisher
+	xapiSubscriber        *services.XAPISubscriber
+	cursorCodec           *pagination.Codec
 )
 
 func main() {
@@ -38,15 +55,75 @@ func main() {
 	}
 
 	topicRepository = repository.NewDynamoDBTopicRepository(sess, "Qriosity-Topics")
-	courseRepository = repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses")
-	roadmapRepository = repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", topicRepository)
+	topicEdgeRepository := repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges")
+	facetCountRepository := repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts")
+	courseRepository = repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses", topicEdgeRepository, facetCountRepository)
+	roadmapRepository = repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", topicEdgeRepository, facetCountRepository)
 	userRepository, _ = repository.NewDynamoDBUserRepository(sess, "Qriosity-Users")
+	completionRepository = repository.NewDynamoDBRoadmapCompletionRepository(sess, "Qriosity-RoadmapCompletions")
+	roadmapViewRepository = repository.NewDynamoDBRoadmapViewRepository(sess, "Qriosity-RoadmapViews")
 	roadmapService = services.NewRoadmapService()
+	enrichmentService = services.NewCourseEnrichmentService()
+	leaderboardService = services.NewLeaderboardService(repository.NewDynamoDBLeaderboardRepository(sess, "Qriosity-Leaderboards"))
+	searchIndex, err := search.NewIndexFromEnv(sess)
+	if err != nil {
+		log.Fatalf("Failed to configure search: %v", err)
+	}
+	searchService = services.NewSearchService(searchIndex, courseRepository, roadmapRepository)
+	topicResolver := services.NewTopicResolver(topicRepository, repository.NewDynamoDBTopicAliasRepository(sess, "Qriosity-TopicAliases"))
+	feedService = services.NewFeedService(courseRepository, roadmapRepository, topicResolver)
+	rankingPager = services.NewRankingPager(repository.NewDynamoDBRankingSnapshotRepository(sess, "Qriosity-RankingSnapshots"))
+	recommendationService = services.NewRecommendationService(
+		userRepository,
+		roadmapRepository,
+		repository.NewDynamoDBRoadmapSimilarityRepository(sess, "Qriosity-RoadmapSimilarities"),
+	)
+
+	achievementService, err := services.NewAchievementService()
+	if err != nil {
+		log.Fatalf("Failed to load achievements: %v", err)
+	}
+	webhookService = services.NewWebhookService(
+		repository.NewDynamoDBWebhookRepository(sess, "Qriosity-Webhooks"),
+		repository.NewDynamoDBWebhookDeliveryRepository(sess, "Qriosity-WebhookDeliveries"),
+	)
+	xapiSubscriber, err = services.NewXAPISubscriberFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure the LRS: %v", err)
+	}
+	trendingService = services.NewTrendingService(
+		repository.NewDynamoDBRoadmapActivityRepository(sess, "Qriosity-RoadmapActivity"),
+		roadmapRepository,
+		topicEdgeRepository,
+	)
+	eventPublisher = services.NewEventPublisher(
+		repository.NewDynamoDBOutboxRepository(sess, "Qriosity-Outbox"),
+		services.NewActivitySubscribers(userRepository, achievementService, leaderboardService),
+		webhookService,
+		xapiSubscriber,
+		trendingService,
+		services.NewUserTopicMerger(userRepository),
+	)
+	topicService = services.NewTopicService(
+		topicResolver,
+		roadmapRepository,
+		courseRepository,
+		eventPublisher,
+		searchService,
+	)
+
+	cursorCodec, err = pagination.NewCodecFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure cursors: %v", err)
+	}
 
 	lambda.Start(Handler)
 }
 
 func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	// Statements buffered while handling the request must reach the LRS before the lambda
+	// freezes, failed batches are logged by the emitter
+	defer xapiSubscriber.Flush(ctx)
 
 	// Check auth
 	if err := utils.CheckAuthorization(ctx, event); err != nil {
@@ -62,15 +139,41 @@ func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, er
 		case "getCourseById":
 			return handleGetCourseById(ctx, event.Arguments)
 		case "getAllTopics":
-			return handleGetAllTopics(ctx)
+			return handleGetAllTopics(ctx, event.Arguments)
 		case "getCourses":
 			return handleGetCourses(ctx, event.Arguments)
 		case "getRoadmaps":
-			return handleGetRoadmaps(ctx)
+			return handleGetRoadmaps(ctx, event.Arguments)
+		case "getCourseFacets":
+			return handleGetCourseFacets(ctx, event.Arguments)
+		case "getRoadmapFacets":
+			return handleGetRoadmapFacets(ctx, event.Arguments)
 		case "getRoadmapsByUser":
 			return handleGetRoadmapsByUser(ctx, event.Arguments)
 		case "getRoadmapFeed":
 			return handleGetRoadmapFeed(ctx, event.Arguments)
+		case "getCourseFeed":
+			return handleGetCourseFeed(ctx, event.Arguments)
+		case "similarRoadmaps":
+			return handleSimilarRoadmaps(ctx, event.Arguments)
+		case "trendingRoadmaps":
+			return handleTrendingRoadmaps(ctx, event.Arguments)
+		case "newRoadmaps":
+			return handleNewRoadmaps(ctx, event.Arguments)
+		case "topicRoadmaps":
+			return handleTopicRoadmaps(ctx, event.Arguments)
+		case "topicCourses":
+			return handleTopicCourses(ctx, event.Arguments)
+		case "getBrokenCourses":
+			return handleGetBrokenCourses(ctx, event)
+		case "leaderboard":
+			return handleLeaderboard(ctx, event.Arguments)
+		case "webhooks":
+			return handleWebhooks(ctx, event)
+		case "webhookDeliveries":
+			return handleWebhookDeliveries(ctx, event)
+		case "search":
+			return handleSearch(ctx, event.Arguments)
 		}
 	case "Mutation":
 		switch event.FieldName {
@@ -90,12 +193,33 @@ func Handler(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, er
 			return handleUserProgressedRoadmap(ctx, event.Arguments)
 		case "userUntrackingRoadmap":
 			return handleUserUntrackingRoadmap(ctx, event.Arguments)
+		case "registerWebhook":
+			return handleRegisterWebhook(ctx, event)
+		case "deleteWebhook":
+			return handleDeleteWebhook(ctx, event)
+		case "replayWebhookDelivery":
+			return handleReplayWebhookDelivery(ctx, event)
+		case "updateTopic":
+			return handleUpdateTopic(ctx, event)
+		case "mergeTopics":
+			return handleMergeTopics(ctx, event)
 		}
 	}
 
 	return nil, errors.New("unhandled operation")
 }
 
+// callerUser loads the user the request's token was issued to, for operations whose rights
+// must not depend on a userId argument the client chose
+func callerUser(event utils.AppSyncEvent) (*domain.User, error) {
+	caller, err := utils.CallerUsername(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return userRepository.GetUserByName(caller)
+}
+
 func handleUserUntrackingRoadmap(ctx context.Context, arguments json.RawMessage) (json.RawMessage, error) {
 	var input struct {
 		UserID    string `json:"userId"`
@@ -160,6 +284,48 @@ func handleUserProgressedRoadmap(ctx context.Context, arguments json.RawMessage)
 		return nil, err
 	}
 
+	roadmap, err := roadmapRepository.GetRoadmap(ctx, input.RoadmapID)
+	if err != nil {
+		log.Printf("Error fetching roadmap %s: %v", input.RoadmapID, err)
+		return json.RawMessage(`{"success": true}`), nil
+	}
+
+	progress = user.RoadmapsProgress[input.RoadmapID]
+	publish(ctx, events.RoadmapProgressed{
+		UserID:       user.Name,
+		RoadmapID:    roadmap.ID,
+		Progress:     progress,
+		TotalCourses: len(roadmap.CourseIDs),
+	})
+
+	// Progress counts the courses done in roadmap order
+	if progress <= len(roadmap.CourseIDs) {
+		publish(ctx, events.CourseCompleted{
+			UserID:    user.Name,
+			RoadmapID: roadmap.ID,
+			CourseID:  roadmap.CourseIDs[progress-1],
+		})
+	}
+
+	// The step that finishes the roadmap counts as a completion, further progress does not, and
+	// neither does finishing it again after tracking it anew
+	if len(roadmap.CourseIDs) > 0 && progress == len(roadmap.CourseIDs) {
+		first, err := completionRepository.Record(ctx, user.Name, roadmap.ID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			return json.RawMessage(`{"success": true}`), nil
+		}
+
+		publish(ctx, events.RoadmapCompleted{
+			UserID:    user.Name,
+			RoadmapID: roadmap.ID,
+			AuthorID:  roadmap.AuthorId,
+			Topics:    roadmap.Topics,
+		})
+	}
+
 	return json.RawMessage(`{"success": true}`), nil
 }
 
@@ -181,6 +347,19 @@ func handleCustomRoadmapRequested(ctx context.Context, arguments json.RawMessage
 		return nil, err
 	}
 
+	// Validate the suggested links and replace the model's guesses with the pages' own metadata
+	roadmap.Courses = enrichmentService.EnrichAll(ctx, roadmap.Courses)
+
+	// The model names topics freely, tag the roadmap and its courses with the existing ones
+	if roadmap.Topics, err = topicService.Resolve(ctx, roadmap.Topics); err != nil {
+		return nil, err
+	}
+	for i := range roadmap.Courses {
+		if roadmap.Courses[i].Topics, err = topicService.Resolve(ctx, roadmap.Courses[i].Topics); err != nil {
+			return nil, err
+		}
+	}
+
 	wg := sync.WaitGroup{}
 	wg.Add(1)
 	// In a go routine fetch the user, decrease its gen uses by 1 and insert it back
@@ -231,7 +410,10 @@ func handleCustomRoadmapRequested(ctx context.Context, arguments json.RawMessage
 
 			newCourse := course // Create a new instance of course
 			newCourse.ID = randomGuid.String()
-			newCourse.Author = "Qriosity-AI"
+			newCourse.CreatedAt = time.Now().UTC()
+			if newCourse.Author == "" {
+				newCourse.Author = "Qriosity-AI"
+			}
 			newCourses = append(newCourses, &newCourse)
 			allCourses = append(allCourses, newCourse)
 		} else {
@@ -258,6 +440,12 @@ func handleCustomRoadmapRequested(ctx context.Context, arguments json.RawMessage
 		if err := courseRepository.BulkInsert(ctx, newCourses); err != nil {
 			return nil, err
 		}
+
+		for _, course := range newCourses {
+			if err := searchService.IndexCourse(ctx, course); err != nil {
+				log.Printf("Error indexing course %s: %v", course.ID, err)
+			}
+		}
 	}
 
 	roadmap.Courses = allCourses
@@ -282,13 +470,28 @@ type CourseAddedToRoadmapArguments struct {
 	RoadmapID string `json:"roadmapId"`
 }
 
-func handleGetAllTopics(ctx context.Context) (json.RawMessage, error) {
-	topics, err := topicRepository.GetAllTopics(ctx)
+func handleGetAllTopics(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var arguments pagination.Arguments
+	if err := json.Unmarshal(args, &arguments); err != nil {
+		return nil, err
+	}
+
+	request, err := cursorCodec.Request("topics", arguments)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := json.Marshal(topics)
+	page, err := topicRepository.GetTopics(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, "topics", request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
 	if err != nil {
 		return nil, err
 	}
@@ -296,14 +499,17 @@ func handleGetAllTopics(ctx context.Context) (json.RawMessage, error) {
 	return response, nil
 }
 
+// sortedList names a listing in its cursors, cursors of one order cannot be used with another
+func sortedList(name string, order domain.SortOrder) string {
+	return fmt.Sprintf("%s:%s:%t", name, order.Field, order.Descending)
+}
+
 func handleGetCourses(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
 	var input struct {
-		UserID     string `json:"userId"`
-		Pagination struct {
-			Page             int    `json:"page"`
-			PerPage          int    `json:"perPage"`
-			LastEvaluatedKey string `json:"lastEvaluatedKey,omitempty"`
-		} `json:"pagination"`
+		UserID string `json:"userId"`
+		pagination.Arguments
+		Filters domain.CourseFilter `json:"filters"`
+		Sort    domain.SortOrder    `json:"sort"`
 	}
 
 	if err := json.Unmarshal(args, &input); err != nil {
@@ -311,89 +517,122 @@ func handleGetCourses(ctx context.Context, args json.RawMessage) (json.RawMessag
 		return nil, err
 	}
 
-	log.Printf("Input: %+v", input)
-
-	// Assuming LastEvaluatedKey is a base64 encoded string or a simple key
-	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
-	if input.Pagination.LastEvaluatedKey != "" {
-		// Decode the LastEvaluatedKey string back into the appropriate map structure
-		decodedKey, err := base64.StdEncoding.DecodeString(input.Pagination.LastEvaluatedKey)
-		if err != nil {
-			log.Printf("Error decoding LastEvaluatedKey: %v", err)
-			return nil, err
-		}
-		if err := json.Unmarshal(decodedKey, &lastEvaluatedKey); err != nil {
-			log.Printf("Error unmarshalling LastEvaluatedKey: %v", err)
-			return nil, err
-		}
-	} else {
-		lastEvaluatedKey = nil
+	list := sortedList("courses", input.Sort)
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a new pagination object
-	parsedPagination := utils.Pagination{
-		Page:             input.Pagination.Page,
-		PerPage:          input.Pagination.PerPage,
-		LastEvaluatedKey: lastEvaluatedKey,
+	if input.Filters.Topics, err = topicService.Canonical(ctx, input.Filters.Topics); err != nil {
+		return nil, err
 	}
 
-	// Pass the decoded LastEvaluatedKey to your repository function
-	courses, pagination, err := courseRepository.GetAllCourses(ctx, parsedPagination)
+	page, err := courseRepository.FindCourses(ctx, input.Filters, input.Sort, request)
 	if err != nil {
 		log.Printf("Error fetching courses: %v", err)
 		return nil, err
 	}
-	log.Printf("Fetched courses: %+v", courses)
-	log.Printf("Pagination: %+v", pagination)
 
-	// If there's a LastEvaluatedKey in the pagination, re-encode it as a string
-	var encodedLastEvaluatedKey string
-	if pagination.LastEvaluatedKey != nil {
-		marshaledKey, err := json.Marshal(pagination.LastEvaluatedKey)
-		if err != nil {
-			log.Printf("Error marshalling LastEvaluatedKey: %v", err)
-			return nil, err
-		}
-		encodedLastEvaluatedKey = base64.StdEncoding.EncodeToString(marshaledKey)
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
 	}
 
-	output := struct {
-		Courses    []*domain.Course `json:"courses"`
-		Pagination struct {
-			Page             int    `json:"page"`
-			PerPage          int    `json:"perPage"`
-			LastEvaluatedKey string `json:"lastEvaluatedKey,omitempty"`
-		} `json:"pagination"`
-	}{
-		Courses: courses,
-		Pagination: struct {
-			Page             int    `json:"page"`
-			PerPage          int    `json:"perPage"`
-			LastEvaluatedKey string `json:"lastEvaluatedKey,omitempty"`
-		}{
-			Page:             pagination.Page,
-			PerPage:          pagination.PerPage,
-			LastEvaluatedKey: encodedLastEvaluatedKey,
-		},
+	response, err := json.Marshal(connection)
+	if err != nil {
+		log.Printf("Error marshalling output: %v", err)
+		return nil, err
 	}
 
-	response, err := json.Marshal(output)
+	return response, nil
+}
+
+// handleGetCourseFacets counts over every matching course, pages of getCourses do not pay for it
+func handleGetCourseFacets(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Filters domain.CourseFilter `json:"filters"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	topics, err := topicService.Canonical(ctx, input.Filters.Topics)
+	if err != nil {
+		return nil, err
+	}
+	input.Filters.Topics = topics
+
+	facets, err := courseRepository.GetCourseFacets(ctx, input.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(facets)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleGetRoadmaps(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		pagination.Arguments
+		Filters domain.RoadmapFilter `json:"filters"`
+		Sort    domain.SortOrder     `json:"sort"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	list := sortedList("roadmaps", input.Sort)
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Filters.Topics, err = topicService.Canonical(ctx, input.Filters.Topics); err != nil {
+		return nil, err
+	}
+
+	page, err := roadmapRepository.FindRoadmaps(ctx, input.Filters, input.Sort, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
 	if err != nil {
-		log.Printf("Error marshalling output: %v", err)
 		return nil, err
 	}
-	log.Printf("Response: %s", response)
 
 	return response, nil
 }
 
-func handleGetRoadmaps(ctx context.Context) (json.RawMessage, error) {
-	roadmaps, err := roadmapRepository.GetAllRoadmaps(ctx)
+func handleGetRoadmapFacets(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Filters domain.RoadmapFilter `json:"filters"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	topics, err := topicService.Canonical(ctx, input.Filters.Topics)
 	if err != nil {
 		return nil, err
 	}
+	input.Filters.Topics = topics
 
-	response, err := json.Marshal(roadmaps)
+	facets, err := roadmapRepository.GetRoadmapFacets(ctx, input.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(facets)
 	if err != nil {
 		return nil, err
 	}
@@ -437,6 +676,7 @@ func handleUserLikedRoadmap(ctx context.Context, args json.RawMessage) (json.Raw
 
 	var user *domain.User
 	var roadmap *domain.Roadmap
+	alreadyLiked := false
 
 	wg.Add(2)
 
@@ -453,6 +693,7 @@ func handleUserLikedRoadmap(ctx context.Context, args json.RawMessage) (json.Raw
 		// Check if the user has already liked the roadmap
 		for _, roadmapID := range user.Roadmaps {
 			if roadmapID == input.RoadmapID {
+				alreadyLiked = true
 				return
 			}
 		}
@@ -498,6 +739,14 @@ func handleUserLikedRoadmap(ctx context.Context, args json.RawMessage) (json.Raw
 		}
 	}
 
+	if !alreadyLiked {
+		publish(ctx, events.RoadmapLiked{
+			UserID:    input.UserID,
+			RoadmapID: roadmap.ID,
+			AuthorID:  roadmap.AuthorId,
+		})
+	}
+
 	return json.RawMessage(`{"success": true}`), nil
 }
 
@@ -507,13 +756,21 @@ func handleAddTopics(ctx context.Context, args json.RawMessage) (json.RawMessage
 		return nil, err
 	}
 
-	var topics []*domain.Topic
-	for _, name := range addTopicsArgs.Names {
-		topics = append(topics, &domain.Topic{Name: name})
+	// Names resolving to an existing topic return it instead of creating a near duplicate
+	names, err := topicService.Resolve(ctx, addTopicsArgs.Names)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := topicRepository.Insert(ctx, topics); err != nil {
-		return nil, err
+	topics := make([]*domain.Topic, 0, len(names))
+	for _, name := range names {
+		topic, err := topicRepository.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if topic != nil {
+			topics = append(topics, topic)
+		}
 	}
 
 	response, err := json.Marshal(topics)
@@ -550,10 +807,20 @@ func handleUpsertCourse(ctx context.Context, args json.RawMessage) (json.RawMess
 		return nil, err
 	}
 
+	if course.Topics, err = topicService.Resolve(ctx, course.Topics); err != nil {
+		return nil, err
+	}
+
+	// The creation date only applies to new courses, the repository keeps the stored one
+	course.CreatedAt = time.Now().UTC()
 	if err := courseRepository.UpsertCourse(ctx, &course); err != nil {
 		return nil, err
 	}
 
+	if err := searchService.IndexCourse(ctx, &course); err != nil {
+		log.Printf("Error indexing course %s: %v", course.ID, err)
+	}
+
 	response, err := json.Marshal(course)
 	if err != nil {
 		return nil, err
@@ -587,6 +854,10 @@ func handleUpsertRoadmap(ctx context.Context, args json.RawMessage) (json.RawMes
 		return nil, err
 	}
 
+	if roadmap.Topics, err = topicService.Resolve(ctx, roadmap.Topics); err != nil {
+		return nil, err
+	}
+
 	user, err := userRepository.GetUserByName(roadmap.AuthorId)
 	if err != nil {
 		return nil, err
@@ -598,6 +869,21 @@ func handleUpsertRoadmap(ctx context.Context, args json.RawMessage) (json.RawMes
 		return nil, errors.New("user is not authorized to create roadmaps")
 	}
 
+	// Updates of an existing roadmap are not a new creation
+	isNew := true
+	for _, roadmapID := range user.RoadmapsCreated {
+		if roadmapID == roadmap.ID {
+			isNew = false
+			break
+		}
+	}
+
+	// The creation date is kept across edits, the feed ranks newer roadmaps higher
+	roadmap.CreatedAt = time.Now().UTC()
+	if existing, err := roadmapRepository.GetRoadmap(ctx, roadmap.ID); err == nil && !existing.CreatedAt.IsZero() {
+		roadmap.CreatedAt = existing.CreatedAt
+	}
+
 	user.RoadmapsCreated = append(user.RoadmapsCreated, roadmap.ID)
 
 	if _, err := userRepository.UpsertUser(*user); err != nil {
@@ -608,6 +894,18 @@ func handleUpsertRoadmap(ctx context.Context, args json.RawMessage) (json.RawMes
 		return nil, err
 	}
 
+	if err := searchService.IndexRoadmap(ctx, &roadmap); err != nil {
+		log.Printf("Error indexing roadmap %s: %v", roadmap.ID, err)
+	}
+
+	if isNew {
+		publish(ctx, events.RoadmapCreated{
+			UserID:    user.Name,
+			RoadmapID: roadmap.ID,
+			Topics:    roadmap.Topics,
+		})
+	}
+
 	response, err := json.Marshal(roadmap)
 	if err != nil {
 		return nil, err
@@ -681,6 +979,18 @@ func handleGetRoadmapById(ctx context.Context, args json.RawMessage) (json.RawMe
 		}
 	}
 
+	// Trending counts a learner's views of a roadmap once a day, however often they open it
+	firstView, err := roadmapViewRepository.MarkViewed(ctx, user.Name, roadmap.ID, time.Now().UTC().Format(time.DateOnly))
+	if err != nil {
+		log.Printf("handleGetRoadmapById: error recording view of %s: %v", roadmap.ID, err)
+	}
+	if firstView {
+		publish(ctx, events.RoadmapViewed{
+			UserID:    user.Name,
+			RoadmapID: roadmap.ID,
+		})
+	}
+
 	response, err := json.Marshal(roadmap)
 	if err != nil {
 		return nil, err
@@ -712,17 +1022,29 @@ func handleGetCourseById(ctx context.Context, args json.RawMessage) (json.RawMes
 func handleGetRoadmapsByUser(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
 	var input struct {
 		UserID string `json:"userId"`
+		pagination.Arguments
 	}
 	if err := json.Unmarshal(args, &input); err != nil {
 		return nil, err
 	}
 
+	list := "roadmapsByUser:" + input.UserID
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
 	roadmaps, err := roadmapRepository.GetRoadmapsByUser(ctx, input.UserID, userRepository)
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := json.Marshal(roadmaps)
+	connection, err := pagination.NewConnection(cursorCodec, list, request, pagination.SliceByOffset(roadmaps, request))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
 	if err != nil {
 		return nil, err
 	}
@@ -730,69 +1052,648 @@ func handleGetRoadmapsByUser(ctx context.Context, args json.RawMessage) (json.Ra
 	return response, nil
 }
 
-func handleGetRoadmapFeed(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
-	log.Println("handleGetRoadmapFeed: start")
-
+func handleGetCourseFeed(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
 	var input struct {
 		UserID string `json:"userId"`
+		pagination.Arguments
 	}
 	if err := json.Unmarshal(args, &input); err != nil {
-		log.Printf("handleGetRoadmapFeed: error unmarshalling input: %v", err)
 		return nil, err
 	}
-	log.Printf("handleGetRoadmapFeed: unmarshalled input: %+v", input)
 
-	// Fetch the user by ID
+	list := "courseFeed:" + input.UserID
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := userRepository.GetUserByName(input.UserID)
 	if err != nil {
-		log.Printf("handleGetRoadmapFeed: error fetching user: %v", err)
+		log.Printf("handleGetCourseFeed: error fetching user: %v", err)
+		return nil, err
+	}
+
+	page, err := services.PageRanking(ctx, rankingPager, request, func(ctx context.Context) ([]*domain.Course, error) {
+		return feedService.CourseFeed(ctx, user)
+	}, courseID, courseRepository.GetByIDs)
+	if err != nil {
+		log.Printf("handleGetCourseFeed: error ranking courses: %v", err)
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleSimilarRoadmaps(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		ID    string `json:"id"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	roadmaps, err := recommendationService.SimilarRoadmaps(ctx, input.ID, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(roadmaps)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleTrendingRoadmaps(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Topic  string `json:"topic"`
+		Window string `json:"window"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
 		return nil, err
 	}
-	log.Printf("handleGetRoadmapFeed: fetched user: %+v", user)
 
-	// Create a set of the user's topics
-	userTopics := user.Topics
-	log.Printf("handleGetRoadmapFeed: user topics: %+v", userTopics)
+	// Scores move as activity comes in, later pages are cut from the ranking of the first
+	list := "trendingRoadmaps:" + input.Topic + ":" + input.Window
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
 
-	// Fetch roadmaps by each topic and ensure no duplicates
-	roadmapMap := make(map[string]domain.Roadmap)
-	for _, topic := range userTopics {
-		roadmaps, err := roadmapRepository.GetByTopic(ctx, topic)
+	page, err := services.PageRanking(ctx, rankingPager, request, func(ctx context.Context) ([]*domain.Roadmap, error) {
+		topic, err := canonicalTopic(ctx, input.Topic)
 		if err != nil {
-			log.Printf("handleGetRoadmapFeed: error fetching roadmaps for topic %s: %v", topic, err)
 			return nil, err
 		}
-		log.Printf("handleGetRoadmapFeed: fetched roadmaps for topic %s: %+v", topic, roadmaps)
-		for _, roadmap := range roadmaps {
-			roadmapMap[roadmap.ID] = *roadmap
-		}
+		return trendingService.Trending(ctx, topic, input.Window)
+	}, roadmapID, roadmapRepository.GetByIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Convert map to slice
-	var filteredRoadmaps []domain.Roadmap
-	for _, roadmap := range roadmapMap {
-		filteredRoadmaps = append(filteredRoadmaps, roadmap)
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
 	}
-	log.Printf("handleGetRoadmapFeed: filtered roadmaps: %+v", filteredRoadmaps)
 
-	// Mark roadmaps liked by the user
-	for i, roadmap := range filteredRoadmaps {
-		for _, roadmapID := range user.Roadmaps {
-			if roadmap.ID == roadmapID {
-				filteredRoadmaps[i].Liked = true
-				break
-			}
-		}
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleNewRoadmaps(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Topic string `json:"topic"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
 	}
-	log.Printf("handleGetRoadmapFeed: marked liked roadmaps: %+v", filteredRoadmaps)
 
-	response, err := json.Marshal(filteredRoadmaps)
+	list := "newRoadmaps:" + input.Topic
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	topic, err := canonicalTopic(ctx, input.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := trendingService.Newest(ctx, topic, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleTopicRoadmaps(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Topic string `json:"topic"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	topic, err := topicService.Lookup(ctx, input.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	list := "topicRoadmaps:" + topic.Name
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := roadmapRepository.FindByTopic(ctx, topic.Name, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleTopicCourses(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Topic string `json:"topic"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	topic, err := topicService.Lookup(ctx, input.Topic)
+	if err != nil {
+		return nil, err
+	}
+
+	list := "topicCourses:" + topic.Name
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := courseRepository.FindByTopic(ctx, topic.Name, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleUpdateTopic(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input struct {
+		Input domain.Topic `json:"input"`
+	}
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role != constants.AdminRole {
+		return nil, errors.New("user is not authorized to edit topics")
+	}
+
+	topic, err := topicService.UpdateTopic(ctx, input.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleMergeTopics(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input struct {
+		Sources []string `json:"sources"`
+		Target  string   `json:"target"`
+	}
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role != constants.AdminRole {
+		return nil, errors.New("user is not authorized to merge topics")
+	}
+
+	merge, err := topicService.MergeTopics(ctx, input.Sources, input.Target)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Merged topics %v into %s: %d roadmaps and %d courses rewritten", merge.Merged, merge.Target, merge.Roadmaps, merge.Courses)
+
+	response, err := json.Marshal(merge)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleGetRoadmapFeed(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	log.Println("handleGetRoadmapFeed: start")
+
+	var input struct {
+		UserID string `json:"userId"`
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		log.Printf("handleGetRoadmapFeed: error unmarshalling input: %v", err)
+		return nil, err
+	}
+
+	list := "roadmapFeed:" + input.UserID
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("handleGetRoadmapFeed: unmarshalled input: %+v", input)
+
+	// Fetch the user by ID
+	user, err := userRepository.GetUserByName(input.UserID)
+	if err != nil {
+		log.Printf("handleGetRoadmapFeed: error fetching user: %v", err)
+		return nil, err
+	}
+	log.Printf("handleGetRoadmapFeed: fetched user: %+v", user)
+
+	// Ranked on the first page only, later pages are cut from its snapshot
+	page, err := services.PageRanking(ctx, rankingPager, request, func(ctx context.Context) ([]*domain.Roadmap, error) {
+		return feedService.RoadmapFeed(ctx, user)
+	}, roadmapID, roadmapRepository.GetByIDs)
+	if err != nil {
+		log.Printf("handleGetRoadmapFeed: error ranking roadmaps: %v", err)
+		return nil, err
+	}
+	log.Printf("handleGetRoadmapFeed: paged %d roadmaps", len(page.Items))
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		log.Printf("handleGetRoadmapFeed: error building connection: %v", err)
+		return nil, err
+	}
+
+	output := struct {
+		*pagination.Connection[*domain.Roadmap]
+		AlsoFollowed []*domain.Roadmap `json:"alsoFollowed"`
+	}{
+		Connection: connection,
+	}
+
+	// The "learners like you also followed" section heads the feed, it only comes with the first page
+	if request.After.IsZero() {
+		output.AlsoFollowed, err = recommendationService.AlsoFollowed(ctx, user, services.DefaultRecommendations)
+		if err != nil {
+			log.Printf("handleGetRoadmapFeed: error fetching recommendations: %v", err)
+		}
+	}
+
+	response, err := json.Marshal(output)
+	if err != nil {
+		log.Printf("handleGetRoadmapFeed: error marshalling response: %v", err)
+		return nil, err
+	}
+	log.Printf("handleGetRoadmapFeed: marshalled response: %s", response)
+
+	log.Println("handleGetRoadmapFeed: end")
+	return response, nil
+}
+
+func handleGetBrokenCourses(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input struct {
+		pagination.Arguments
+	}
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	// What the caller sees depends on their role, so they are read from the token
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	list := "brokenCourses:" + user.Name
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	var page pagination.Page[*domain.Course]
+	switch user.Role {
+	case constants.AdminRole:
+		// Admins see every broken course in the catalog
+		page, err = courseRepository.GetBrokenCourses(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+	case constants.CreatorRole, constants.ApprenticeRole:
+		// Creators only see the dead steps of their own roadmaps, in the order they created them
+		courses := make([]*domain.Course, 0)
+		seen := make(map[string]bool)
+		for _, roadmapID := range user.RoadmapsCreated {
+			roadmap, err := roadmapRepository.GetRoadmap(ctx, roadmapID)
+			if err != nil {
+				log.Printf("handleGetBrokenCourses: error fetching roadmap %s: %v", roadmapID, err)
+				continue
+			}
+			for i := range roadmap.Courses {
+				course := roadmap.Courses[i]
+				if course.Broken && !seen[course.ID] {
+					seen[course.ID] = true
+					courses = append(courses, &course)
+				}
+			}
+		}
+		page = pagination.SliceByOffset(courses, request)
+	default:
+		return nil, errors.New("user is not authorized to view broken courses")
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleLeaderboard(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		UserID string `json:"userId"`
+		Metric string `json:"metric"`
+		Period string `json:"period"`
+		Scope  string `json:"scope"`
+		Topic  string `json:"topic"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := userRepository.GetUserByName(input.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := leaderboardService.Leaderboard(ctx, user, input.Metric, input.Scope, input.Topic, input.Period, input.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleSearch(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+	var input struct {
+		Query   string `json:"query"`
+		Filters struct {
+			Kinds      []string `json:"kinds"`
+			Topics     []string `json:"topics"`
+			Difficulty string   `json:"difficulty"`
+		} `json:"filters"`
+		Limit int `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &input); err != nil {
+		return nil, err
+	}
+
+	topics, err := topicService.Canonical(ctx, input.Filters.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := searchService.Search(ctx, search.Query{
+		Text:       input.Query,
+		Kinds:      input.Filters.Kinds,
+		Topics:     topics,
+		Difficulty: input.Filters.Difficulty,
+		Limit:      input.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func courseID(course *domain.Course) string {
+	return course.ID
+}
+
+func roadmapID(roadmap *domain.Roadmap) string {
+	return roadmap.ID
+}
+
+// canonicalTopic resolves a topic argument through its aliases, empty staying empty
+func canonicalTopic(ctx context.Context, topic string) (string, error) {
+	if topic == "" {
+		return "", nil
+	}
+
+	topics, err := topicService.Canonical(ctx, []string{topic})
+	if err != nil || len(topics) == 0 {
+		return topic, err
+	}
+	return topics[0], nil
+}
+
+// publish hands the event to its subscribers. The request already succeeded, so failures are only logged
+func publish(ctx context.Context, event events.Event) {
+	if err := eventPublisher.Publish(ctx, event); err != nil {
+		log.Printf("Error publishing %s: %v", event.EventName(), err)
+	}
+}
+
+// WebhookArguments are the arguments of the webhook operations, which have no userId: webhooks
+// always belong to the caller
+type WebhookArguments struct {
+	WebhookID  string   `json:"webhookId"`
+	DeliveryID string   `json:"deliveryId"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	pagination.Arguments
+}
+
+func handleRegisterWebhook(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input WebhookArguments
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := webhookService.Create(ctx, user, input.URL, input.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleWebhooks(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input WebhookArguments
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := webhookService.List(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(webhooks)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleDeleteWebhook(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input WebhookArguments
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := webhookService.Delete(ctx, user, input.WebhookID); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(`{"success": true}`), nil
+}
+
+func handleWebhookDeliveries(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input WebhookArguments
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	list := "webhookDeliveries:" + input.WebhookID
+	request, err := cursorCodec.Request(list, input.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := webhookService.Deliveries(ctx, user, input.WebhookID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	connection, err := pagination.NewConnection(cursorCodec, list, request, page)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(connection)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func handleReplayWebhookDelivery(ctx context.Context, event utils.AppSyncEvent) (json.RawMessage, error) {
+	var input WebhookArguments
+	if err := json.Unmarshal(event.Arguments, &input); err != nil {
+		return nil, err
+	}
+
+	user, err := callerUser(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := webhookService.Replay(ctx, user, input.WebhookID, input.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := json.Marshal(delivery)
 	if err != nil {
-		log.Printf("handleGetRoadmapFeed: error marshalling response: %v", err)
 		return nil, err
 	}
-	log.Printf("handleGetRoadmapFeed: marshalled response: %s", response)
 
-	log.Println("handleGetRoadmapFeed: end")
 	return response, nil
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"backend/internal/services"
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultConcurrency = 10
+	pageSize           = 100
+	checkpointJob      = "linkcheck"
+	// No page is started closer than this to the deadline, it leaves time to check the pages
+	// already fed to the workers and save where the walk stopped
+	deadlineMargin = 2 * time.Minute
+)
+
+var (
+	courseRepository     repository.ICourseRepository
+	checkpointRepository repository.IJobCheckpointRepository
+	linkChecker          services.ILinkChecker
+	concurrency          int
+)
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REPO_AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session: %v", err)
+	}
+
+	courseRepository = repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses", repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges"), repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts"))
+	checkpointRepository = repository.NewDynamoDBJobCheckpointRepository(sess, "Qriosity-JobCheckpoints")
+	linkChecker = services.NewCourseEnrichmentService()
+
+	concurrency = defaultConcurrency
+	if value, err := strconv.Atoi(os.Getenv("LINKCHECK_CONCURRENCY")); err == nil && value > 0 {
+		concurrency = value
+	}
+
+	lambda.Start(Handler)
+}
+
+// Handler walks the course catalog and records the link status of every course. It is meant to
+// be triggered on a schedule: a run stops before its deadline and saves where it stopped, the next
+// one resumes from there and starts over once the whole catalog was checked
+func Handler(ctx context.Context) error {
+	var checked, broken int64
+
+	position, err := checkpointRepository.Get(ctx, checkpointJob)
+	if err != nil {
+		return err
+	}
+
+	// Bounded pool of workers fed page by page
+	jobs := make(chan *domain.Course)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for course := range jobs {
+				if checkCourse(ctx, course) {
+					atomic.AddInt64(&broken, 1)
+				}
+				atomic.AddInt64(&checked, 1)
+			}
+		}()
+	}
+
+	request := pagination.Request{First: pageSize, After: position}
+	var walkErr error
+	finished := false
+	for {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < deadlineMargin {
+			break
+		}
+
+		page, err := courseRepository.GetAllCourses(ctx, request)
+		if err != nil {
+			walkErr = err
+			break
+		}
+
+		for _, course := range page.Items {
+			jobs <- course
+		}
+
+		if !page.HasNextPage {
+			finished = true
+			break
+		}
+		request.After = page.NextPosition()
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	// Every page fed to the workers has been checked, the next run starts after the last one
+	next := request.After
+	if finished {
+		next = pagination.Position{}
+	}
+	if err := checkpointRepository.Save(ctx, checkpointJob, next); err != nil {
+		walkErr = errors.Join(walkErr, err)
+	}
+
+	log.Printf("Link check stopped: %d courses checked, %d broken, catalog finished: %v", checked, broken, finished)
+	return walkErr
+}
+
+// checkCourse records the link status of a single course and reports whether it is broken
+func checkCourse(ctx context.Context, course *domain.Course) bool {
+	statusCode, err := linkChecker.CheckLink(ctx, course.URL)
+	isBroken := err != nil
+	if isBroken {
+		log.Printf("Course %s has a broken link: %v", course.ID, err)
+	}
+
+	if err := courseRepository.UpdateLinkStatus(ctx, course.ID, statusCode, isBroken, time.Now()); err != nil {
+		log.Printf("Failed to record link status for course %s: %v", course.ID, err)
+	}
+
+	return isBroken
+}
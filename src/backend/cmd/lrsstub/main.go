@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// lrsstub is a minimal Learning Record Store for local testing. It keeps the statements it is
+// sent in memory and lists them back, point XAPI_LRS_ENDPOINT at http://localhost:8089/xapi
+func main() {
+	addr := flag.String("addr", ":8089", "address to listen on")
+	flag.Parse()
+
+	var (
+		mu         sync.Mutex
+		statements []json.RawMessage
+	)
+
+	http.HandleFunc("/xapi/statements", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			if r.Header.Get("X-Experience-API-Version") == "" {
+				http.Error(w, "missing X-Experience-API-Version header", http.StatusBadRequest)
+				return
+			}
+
+			var batch []json.RawMessage
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			ids := make([]string, 0, len(batch))
+			for _, statement := range batch {
+				var header struct {
+					ID string `json:"id"`
+				}
+				_ = json.Unmarshal(statement, &header)
+				ids = append(ids, header.ID)
+			}
+
+			mu.Lock()
+			statements = append(statements, batch...)
+			mu.Unlock()
+
+			log.Printf("Stored %d statements", len(batch))
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(ids)
+		case http.MethodGet:
+			mu.Lock()
+			body, err := json.Marshal(map[string]interface{}{"statements": statements})
+			mu.Unlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(body)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	log.Printf("Stub LRS listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
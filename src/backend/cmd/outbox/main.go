@@ -0,0 +1,95 @@
+package main
+
+import (
+	"backend/internal/events"
+	"backend/internal/repository"
+	"backend/internal/services"
+	"context"
+	"errors"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"os"
+	"strconv"
+)
+
+const defaultBatchSize = 100
+
+var (
+	relay          *events.OutboxRelay
+	webhookService *services.WebhookService
+	batchSize      int
+)
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REPO_AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session: %v", err)
+	}
+
+	userRepository, err := repository.NewDynamoDBUserRepository(sess, "Qriosity-Users")
+	if err != nil {
+		log.Fatalf("Couldn't connect to dynamo: %v", err)
+	}
+
+	achievementService, err := services.NewAchievementService()
+	if err != nil {
+		log.Fatalf("Failed to load achievements: %v", err)
+	}
+
+	// The subscribers run here when the other lambdas publish with EVENT_BUS=outbox
+	bus := events.NewInProcessBus()
+	services.NewActivitySubscribers(
+		userRepository,
+		achievementService,
+		services.NewLeaderboardService(repository.NewDynamoDBLeaderboardRepository(sess, "Qriosity-Leaderboards")),
+	).Register(bus)
+	webhookService = services.NewWebhookService(
+		repository.NewDynamoDBWebhookRepository(sess, "Qriosity-Webhooks"),
+		repository.NewDynamoDBWebhookDeliveryRepository(sess, "Qriosity-WebhookDeliveries"),
+	)
+	webhookService.Register(bus)
+	services.NewUserTopicMerger(userRepository).Register(bus)
+
+	topicEdgeRepository := repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges")
+	services.NewTrendingService(
+		repository.NewDynamoDBRoadmapActivityRepository(sess, "Qriosity-RoadmapActivity"),
+		repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", topicEdgeRepository, repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts")),
+		topicEdgeRepository,
+	).Register(bus)
+
+	xapiSubscriber, err := services.NewXAPISubscriberFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure the LRS: %v", err)
+	}
+	xapiSubscriber.SendImmediately().Register(bus)
+
+	relay = events.NewOutboxRelay(
+		repository.NewDynamoDBOutboxRepository(sess, "Qriosity-Outbox"),
+		bus,
+		repository.NewDynamoDBEventDeliveryRepository(sess, "Qriosity-EventDeliveries"),
+	)
+
+	batchSize = defaultBatchSize
+	if value, err := strconv.Atoi(os.Getenv("OUTBOX_BATCH_SIZE")); err == nil && value > 0 {
+		batchSize = value
+	}
+
+	lambda.Start(Handler)
+}
+
+// Handler delivers one batch of pending events, then sends the webhook deliveries queued by
+// every lambda. It is meant to be triggered on a schedule, failed events wait for the next run
+// before being retried
+func Handler(ctx context.Context) error {
+	delivered, err := relay.Deliver(ctx, batchSize)
+	log.Printf("Delivered %d outbox events", delivered)
+
+	sent, webhookErr := webhookService.SendPending(ctx, batchSize)
+	log.Printf("Sent %d webhook deliveries", sent)
+
+	return errors.Join(err, webhookErr)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"backend/internal/repository"
+	"backend/internal/services"
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"os"
+)
+
+var recommendationService *services.RecommendationService
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REPO_AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session: %v", err)
+	}
+
+	userRepository, err := repository.NewDynamoDBUserRepository(sess, "Qriosity-Users")
+	if err != nil {
+		log.Fatalf("Failed to create user repository: %v", err)
+	}
+	recommendationService = services.NewRecommendationService(
+		userRepository,
+		repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges"), repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts")),
+		repository.NewDynamoDBRoadmapSimilarityRepository(sess, "Qriosity-RoadmapSimilarities"),
+	)
+
+	lambda.Start(Handler)
+}
+
+// Handler recomputes the similar roadmaps of every roadmap from the likes and progress of all
+// learners. It is meant to be triggered on a schedule, nightly is plenty
+func Handler(ctx context.Context) error {
+	written, err := recommendationService.ComputeSimilarities(ctx)
+	log.Printf("Wrote similar roadmaps for %d roadmaps", written)
+	return err
+}
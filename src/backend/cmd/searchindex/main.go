@@ -0,0 +1,59 @@
+package main
+
+import (
+	"backend/internal/repository"
+	"backend/internal/search"
+	"backend/internal/services"
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"os"
+)
+
+var (
+	index         search.Index
+	searchService *services.SearchService
+)
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REPO_AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session: %v", err)
+	}
+
+	if os.Getenv("SEARCH_ENDPOINT") == "" {
+		log.Fatalf("SEARCH_ENDPOINT is required, the memory index is filled by the learning lambda itself")
+	}
+
+	topicEdgeRepository := repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges")
+	facetCountRepository := repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts")
+	index, err = search.NewIndexFromEnv(sess)
+	if err != nil {
+		log.Fatalf("Failed to configure search: %v", err)
+	}
+	searchService = services.NewSearchService(
+		index,
+		repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses", topicEdgeRepository, facetCountRepository),
+		repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", topicEdgeRepository, facetCountRepository),
+	)
+
+	lambda.Start(Handler)
+}
+
+// Handler creates the search index if needed and writes every course and roadmap to it. It is
+// run by hand, once before the first deploy with search and after mapping changes
+func Handler(ctx context.Context) error {
+	if cluster, ok := index.(*search.OpenSearchIndex); ok {
+		if err := cluster.EnsureIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	indexed, err := searchService.Reindex(ctx)
+	log.Printf("Indexed %d courses and roadmaps", indexed)
+	return err
+}
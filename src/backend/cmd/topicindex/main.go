@@ -0,0 +1,112 @@
+package main
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"backend/internal/services"
+	"context"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"log"
+	"os"
+)
+
+const coursePageSize = 100
+
+var (
+	courseRepository    repository.ICourseRepository
+	roadmapRepository   repository.IRoadmapRepository
+	topicRepository     repository.ITopicRepository
+	topicEdgeRepository repository.ITopicEdgeRepository
+	topicResolver       *services.TopicResolver
+)
+
+func main() {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REPO_AWS_REGION")),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session: %v", err)
+	}
+
+	topicRepository = repository.NewDynamoDBTopicRepository(sess, "Qriosity-Topics")
+	topicResolver = services.NewTopicResolver(topicRepository, repository.NewDynamoDBTopicAliasRepository(sess, "Qriosity-TopicAliases"))
+	topicEdgeRepository = repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges")
+	facetCountRepository := repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts")
+	courseRepository = repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses", topicEdgeRepository, facetCountRepository)
+	roadmapRepository = repository.NewDynamoDBRoadmapRepository(sess, "Qriosity-Roadmaps", topicEdgeRepository, facetCountRepository)
+
+	lambda.Start(Handler)
+}
+
+// Handler writes the topic edge of every topic of every roadmap and course, saves each item
+// again so it gets the attributes of the listing indexes and is counted in the facet counts, and
+// records the aliases of every topic. It is run by hand, once to fill the edge, alias and facet
+// count tables and the indexes from items saved before they existed. Items are counted once, so
+// a failed run can be started again
+func Handler(ctx context.Context) error {
+	edges := 0
+	defer func() { log.Printf("Wrote %d topic edges", edges) }()
+
+	roadmaps, err := roadmapRepository.GetAllRoadmaps(ctx)
+	if err != nil {
+		return err
+	}
+	for _, roadmap := range roadmaps {
+		// Roadmaps saved before topics were resolved may name topics that do not exist yet,
+		// resolving creates them with their aliases
+		if roadmap.Topics, err = topicResolver.Resolve(ctx, roadmap.Topics); err != nil {
+			return err
+		}
+		if err := roadmapRepository.UpsertRoadmap(ctx, roadmap); err != nil {
+			return err
+		}
+		for _, topic := range roadmap.Topics {
+			if err := topicEdgeRepository.Add(ctx, &domain.TopicEdge{Topic: topic, Kind: constants.TopicItemRoadmap, ItemID: roadmap.ID}); err != nil {
+				return err
+			}
+			edges++
+		}
+	}
+
+	request := pagination.Request{First: coursePageSize}
+	for {
+		page, err := courseRepository.GetAllCourses(ctx, request)
+		if err != nil {
+			return err
+		}
+		for _, course := range page.Items {
+			if err := courseRepository.UpsertCourse(ctx, course); err != nil {
+				return err
+			}
+			for _, topic := range course.Topics {
+				if err := topicEdgeRepository.Add(ctx, &domain.TopicEdge{Topic: topic, Kind: constants.TopicItemCourse, ItemID: course.ID}); err != nil {
+					return err
+				}
+				edges++
+			}
+		}
+
+		if !page.HasNextPage {
+			break
+		}
+		request.After = page.NextPosition()
+	}
+
+	// Topics are read last, so those created while resolving the roadmaps' topics are included
+	topics, err := topicRepository.GetAllTopics(ctx)
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if err := topicResolver.RecordAliases(ctx, topic); err != nil {
+			return err
+		}
+	}
+	log.Printf("Recorded the aliases of %d topics", len(topics))
+
+	return nil
+}
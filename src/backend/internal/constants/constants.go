@@ -1,5 +1,12 @@
 package constants
 
+const (
+	StudentRole    = 0
+	ApprenticeRole = 1
+	CreatorRole    = 2
+	AdminRole      = 3
+)
+
 const (
 	StudentDailyChallenges    = 1
 	ApprenticeDailyChallenges = 5
@@ -15,3 +22,102 @@ const (
 	StudentProgressTracking    = 1
 	ApprenticeProgressTracking = 5
 )
+
+const (
+	DifficultyBeginner     = "Beginner"
+	DifficultyIntermediate = "Intermediate"
+	DifficultyAdvanced     = "Advanced"
+)
+
+const (
+	// Only answers rated above this keep the daily challenge streak going
+	StreakMinimumRating = 5
+
+	StreakFreezeInterval = 7
+	MaxStreakFreezes     = 2
+)
+
+const (
+	ChallengeTypeOpen           = "open"
+	ChallengeTypeMultipleChoice = "multiple_choice"
+	ChallengeTypeShortAnswer    = "short_answer"
+	ChallengeTypeCode           = "code"
+)
+
+const (
+	// Challenges rated below this become review cards
+	ReviewRatingThreshold = 7
+
+	ReviewSourceChallenge = "challenge"
+	ReviewSourceQuiz      = "quiz"
+)
+
+const (
+	LeaderboardStreak      = "streak"
+	LeaderboardChallenge   = "challenge"
+	LeaderboardCompletions = "completions"
+
+	LeaderboardWeekly  = "weekly"
+	LeaderboardMonthly = "monthly"
+	LeaderboardAllTime = "allTime"
+
+	LeaderboardGlobal  = "global"
+	LeaderboardTopic   = "topic"
+	LeaderboardFriends = "friends"
+)
+
+// Kinds of items a topic tags
+const (
+	TopicItemRoadmap = "roadmap"
+	TopicItemCourse  = "course"
+)
+
+// Windows of the trending roadmaps listing
+const (
+	TrendingDaily   = "daily"
+	TrendingWeekly  = "weekly"
+	TrendingMonthly = "monthly"
+)
+
+// Event types the achievements config declares rules for
+const (
+	EventChallengeAnswered = "challengeAnswered"
+	EventRoadmapCompleted  = "roadmapCompleted"
+	EventRoadmapCreated    = "roadmapCreated"
+	EventRoadmapLiked      = "roadmapLiked"
+)
+
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed"
+
+	// Messages are given up on after this many failed deliveries
+	OutboxMaxAttempts = 5
+)
+
+// Events integrators can subscribe webhooks to
+const (
+	WebhookRoadmapCompleted  = "roadmap.completed"
+	WebhookRoadmapPublished  = "roadmap.published"
+	WebhookChallengeAnswered = "challenge.answered"
+)
+
+// Webhook deliveries are queued as pending and sent by the outbox lambda, which claims them
+// as sending until it saves the outcome
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySending   = "sending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+const (
+	SortTitle    = "title"
+	SortDuration = "duration"
+	SortLikes    = "likes"
+)
+
+// DurationFacetBuckets are the lower bounds in hours of the duration facet, each bucket ends
+// where the next one starts
+var DurationFacetBuckets = []int{0, 1, 5, 20}
@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+	"unicode"
+)
 
 type Content interface {
 	IsContent()
@@ -11,35 +15,321 @@ type AuthPayload struct {
 }
 
 type ChallengeResponse struct {
-	UserID   string `json:"userId"`
-	Question string `json:"question"`
-	Answer   string `json:"answer"`
+	UserID   string           `json:"userId"`
+	Question string           `json:"question"`
+	Answer   string           `json:"answer"`
+	Rating   int              `json:"rating"`
+	Insight  string           `json:"insight"`
+	Left     int              `json:"left"`
+	Scores   []CriterionScore `json:"scores"`
+
+	// Achievements unlocked by this answer
+	Achievements []Achievement `json:"achievements,omitempty"`
+}
+
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight"`
+}
+
+// Rubric describes how answers to a question are graded
+type Rubric struct {
+	Criteria        []RubricCriterion `json:"criteria"`
+	ReferenceAnswer string            `json:"referenceAnswer"`
+}
+
+type CriterionScore struct {
+	Criterion     string `json:"criterion"`
+	Score         int    `json:"score"`
+	Justification string `json:"justification"`
+}
+
+// ChallengeFeedback is one message of the daily challenge feedback. Every message carries the
+// next insight tokens, all of them at once when the grader does not stream; the last one has
+// Done set along with the final rating
+type ChallengeFeedback struct {
+	Username string `json:"username"`
+	Sequence int    `json:"sequence"`
+	Tokens   string `json:"tokens"`
+	Done     bool   `json:"done"`
 	Rating   int    `json:"rating"`
-	Insight  string `json:"insight"`
 	Left     int    `json:"left"`
 }
 
+// ChallengeAttempt is a graded daily challenge answer kept for the learner's history
+type ChallengeAttempt struct {
+	ID         string           `json:"id"`
+	Username   string           `json:"username"`
+	QuestionID string           `json:"questionId,omitempty"`
+	Topic      string           `json:"topic"`
+	Question   string           `json:"question"`
+	Answer     string           `json:"answer"`
+	Rating     int              `json:"rating"`
+	Insight    string           `json:"insight"`
+	Scores     []CriterionScore `json:"scores"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+type TopicChallengeStats struct {
+	Topic         string  `json:"topic"`
+	Attempts      int     `json:"attempts"`
+	AverageRating float64 `json:"averageRating"`
+}
+
+type DailyChallengeActivity struct {
+	Date          string  `json:"date"`
+	Attempts      int     `json:"attempts"`
+	AverageRating float64 `json:"averageRating"`
+}
+
+type ChallengeStats struct {
+	TotalAttempts int                      `json:"totalAttempts"`
+	AverageRating float64                  `json:"averageRating"`
+	Topics        []TopicChallengeStats    `json:"topics"`
+	Timeline      []DailyChallengeActivity `json:"timeline"`
+}
+
+// ReviewCard is a question scheduled for spaced repetition review
+type ReviewCard struct {
+	Username       string    `json:"username"`
+	CardID         string    `json:"cardId"`
+	Source         string    `json:"source"` // "challenge" or "quiz"
+	QuestionID     string    `json:"questionId,omitempty"`
+	Question       string    `json:"question"`
+	Answer         string    `json:"answer"`
+	Topic          string    `json:"topic"`
+	EaseFactor     float64   `json:"easeFactor"`
+	IntervalDays   int       `json:"intervalDays"`
+	Repetitions    int       `json:"repetitions"`
+	Lapses         int       `json:"lapses"`
+	Due            time.Time `json:"due"`
+	LastReviewedAt time.Time `json:"lastReviewedAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type Achievement struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	UnlockedAt  time.Time `json:"unlockedAt"`
+}
+
+// LeaderboardEntry is a user's score on a board, boards are keyed by metric, scope and period
+type LeaderboardEntry struct {
+	Board       string    `json:"board"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName"`
+	Score       int       `json:"score"`
+	Rank        int       `json:"rank,omitempty"` // Only set when the board is read
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// RankingSnapshot holds the ids of a ranking as its first page saw them, later pages of the
+// ranking are cut from it
+type RankingSnapshot struct {
+	ID  string   `json:"id"`
+	IDs []string `json:"ids"`
+	// Unix seconds, the TTL attribute of the table
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// OutboxMessage is a domain event waiting to be delivered to its subscribers
+type OutboxMessage struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Payload     string    `json:"payload"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+	// Unix time after which DynamoDB deletes delivered messages
+	ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+// Webhook is an integrator's endpoint notified of the events it subscribed to
+type Webhook struct {
+	ID      string   `json:"id"`
+	OwnerID string   `json:"ownerId"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	// Global webhooks, registered by admins, receive events about all content. The others
+	// only receive events about their owner's roadmaps
+	Global bool `json:"global"`
+	// Key of the HMAC-SHA256 signature sent with every delivery
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is one event sent to a webhook, including every retry
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhookId"`
+	Event     string `json:"event"`
+	Payload   string `json:"payload"`
+	// Pending deliveries are waiting to be sent, sending ones are claimed by an outbox run,
+	// delivered and failed ones are done
+	Status     string    `json:"status"`
+	Success    bool      `json:"success"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"statusCode"`
+	Error      string    `json:"error,omitempty"`
+	ReplayOf   string    `json:"replayOf,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Unix time the claim of the outbox run sending the delivery expires at, other runs may
+	// take over a delivery whose claim expired
+	ClaimExpiresAt int64 `json:"claimExpiresAt,omitempty"`
+}
+
 type Mutation struct {
 }
 
 type Problem struct {
-	Question   string   `json:"question"`
-	Categories []string `json:"categories"`
-	Type       string   `json:"type"`
+	ID         string     `json:"id,omitempty"`
+	Question   string     `json:"question"`
+	Categories []string   `json:"categories"`
+	Type       string     `json:"type"`
+	Difficulty string     `json:"difficulty,omitempty"`
+	Rubric     *Rubric    `json:"rubric,omitempty"`
+	Choices    []string   `json:"choices,omitempty"`
+	Language   string     `json:"language,omitempty"`
+	TestCases  []TestCase `json:"testCases,omitempty"`
+}
+
+type TestCase struct {
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expectedOutput"`
+	Hidden         bool   `json:"hidden"`
+}
+
+// Question is a daily challenge question kept in the question bank
+type Question struct {
+	ID         string    `json:"id"`
+	Topic      string    `json:"topic"`
+	Difficulty string    `json:"difficulty"`
+	Question   string    `json:"question"`
+	Type       string    `json:"type"`
+	Rubric     *Rubric   `json:"rubric,omitempty"`
+	Source     string    `json:"source"` // "generated" or the name of the creator who wrote it
+	CreatedAt  time.Time `json:"createdAt"`
+	// Random key given on insert, questions are drawn in its order
+	DrawKey string `json:"drawKey,omitempty"`
+
+	// Multiple choice
+	Choices       []string `json:"choices,omitempty"`
+	CorrectChoice int      `json:"correctChoice"`
+
+	// Short answer
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"`
+
+	// Code
+	Language      string     `json:"language,omitempty"`
+	TestCases     []TestCase `json:"testCases,omitempty"`
+	TimeLimitMs   int        `json:"timeLimitMs,omitempty"`
+	MemoryLimitMb int        `json:"memoryLimitMb,omitempty"`
+}
+
+// Problem is the question as shown to the learner, without answers or hidden test cases
+func (q *Question) Problem() Problem {
+	// The reference answer stays on the server
+	var rubric *Rubric
+	if q.Rubric != nil {
+		rubric = &Rubric{Criteria: q.Rubric.Criteria}
+	}
+
+	testCases := make([]TestCase, 0, len(q.TestCases))
+	for _, testCase := range q.TestCases {
+		if !testCase.Hidden {
+			testCases = append(testCases, testCase)
+		}
+	}
+
+	return Problem{
+		ID:         q.ID,
+		Question:   q.Question,
+		Categories: []string{q.Topic},
+		Type:       q.Type,
+		Difficulty: q.Difficulty,
+		Rubric:     rubric,
+		Choices:    q.Choices,
+		Language:   q.Language,
+		TestCases:  testCases,
+	}
 }
 
 type ProblemInput struct {
 	Question   string   `json:"question"`
 	Categories []string `json:"categories"`
 	Type       string   `json:"type"`
+	Rubric     *Rubric  `json:"rubric,omitempty"`
 }
 
 type Query struct {
 }
 
+// Topic is a subject roadmaps, courses and learners are tagged with. They refer to it by Name,
+// its slug and the slugs of its aliases all resolve to it
 type Topic struct {
-	Name       string   `json:"name"`
-	RoadmapIds []string `json:"roadmapIds"`
+	Name        string   `json:"name"`
+	Slug        string   `json:"slug"`
+	DisplayName string   `json:"displayName"`
+	Parent      string   `json:"parent,omitempty"` // Name of the broader topic
+	Aliases     []string `json:"aliases"`
+	Description string   `json:"description"`
+}
+
+func NewTopic(name string) *Topic {
+	return &Topic{
+		Name:        name,
+		Slug:        TopicSlug(name),
+		DisplayName: name,
+		Aliases:     []string{},
+	}
+}
+
+// TopicEdge tags a roadmap or a course with a topic, one item per pair
+type TopicEdge struct {
+	Topic   string    `json:"topic"`
+	Item    string    `json:"item"` // kind:id
+	Kind    string    `json:"kind"`
+	ItemID  string    `json:"itemId"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// TopicAlias points a slug to the name of the topic it resolves to
+type TopicAlias struct {
+	Alias string `json:"alias"`
+	Topic string `json:"topic"`
+}
+
+// TopicMerge sums up what merging topics into another rewrote
+type TopicMerge struct {
+	Target   string   `json:"target"`
+	Merged   []string `json:"merged"`
+	Roadmaps int      `json:"roadmaps"`
+	Courses  int      `json:"courses"`
+}
+
+var topicSlugSymbols = strings.NewReplacer("+", " plus ", "#", " sharp ")
+
+// TopicSlug lowercases the name and joins its words with dashes, so "Machine Learning" and
+// "machine_learning" share a slug. Symbols become words to keep C, C++ and C# apart
+func TopicSlug(name string) string {
+	var slug strings.Builder
+	separated := false
+	for _, r := range strings.ToLower(topicSlugSymbols.Replace(name)) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separated = true
+			continue
+		}
+		if separated && slug.Len() > 0 {
+			slug.WriteByte('-')
+		}
+		separated = false
+		slug.WriteRune(r)
+	}
+	return slug.String()
 }
 
 type User struct {
@@ -55,8 +345,24 @@ type User struct {
 	RoadmapsCreated          []string  `json:"roadmapsCreated"`
 	LastDailyChallenge       time.Time `json:"lastDailyChallenge"`
 	DailyChallengeStreak     int       `json:"dailyChallengeStreak"`
+	LongestStreak            int       `json:"longestDailyChallengeStreak"`
+	StreakFreezes            int       `json:"streakFreezes"`
+	Timezone                 string    `json:"timezone"`
 	RoadmapsViewed           int       `json:"roadmapsViewed"`
 	CreationsRemaining       int       `json:"creationsRemaining"`
+	// Friendships are mutual, a request becomes one once the requested user accepts it
+	Friends           []string `json:"friends"`
+	FriendRequests    []string `json:"friendRequests"`
+	LeaderboardOptOut bool     `json:"leaderboardOptOut"`
+	XP                int      `json:"xp"`
+	Level             int      `json:"level"`
+
+	Achievements []Achievement `json:"achievements"`
+	// Progress towards achievements, e.g. the number of challenges rated 8 or more
+	AchievementCounters map[string]int `json:"achievementCounters"`
+
+	// Elo-style skill estimate per topic, updated from challenge ratings
+	TopicSkills map[string]float64 `json:"topicSkills"`
 
 	// string separated by comma in the format (roadmap_id, progress)
 	RoadmapsProgress map[string]int `json:"roadmapProgress"`
@@ -74,6 +380,14 @@ type Course struct {
 	Author      string   `json:"author"`
 	Duration    int      `json:"duration"`
 	Language    string   `json:"language"`
+	ImageURL    string   `json:"imageUrl"`
+	// Zero for courses added before it was recorded
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Filled in by the link checker
+	Broken         bool      `json:"broken"`
+	LinkStatusCode int       `json:"linkStatusCode"`
+	LinkCheckedAt  time.Time `json:"linkCheckedAt"`
 }
 
 type Roadmap struct {
@@ -92,4 +406,88 @@ type Roadmap struct {
 	ImageURL    string   `json:"imageUrl"`
 	Description string   `json:"description"`
 	Verified    bool     `json:"verified"`
+
+	// Zero for roadmaps published before it was recorded
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RoadmapSimilarity lists the roadmaps most often followed by the learners of a roadmap, most similar first
+type RoadmapSimilarity struct {
+	RoadmapID  string           `json:"roadmapId"`
+	Similar    []SimilarRoadmap `json:"similar"`
+	ComputedAt time.Time        `json:"computedAt"`
+}
+
+type SimilarRoadmap struct {
+	RoadmapID string  `json:"roadmapId"`
+	Score     float64 `json:"score"`
+}
+
+// RoadmapActivity counts what learners did with a roadmap over one UTC day
+type RoadmapActivity struct {
+	Day       string `json:"day"` // 2006-01-02
+	RoadmapID string `json:"roadmapId"`
+	Likes     int    `json:"likes"`
+	Views     int    `json:"views"`
+	Progress  int    `json:"progress"`
+}
+
+// CourseFilter narrows a course listing, zero values match everything. Durations are in hours
+type CourseFilter struct {
+	Difficulty  string   `json:"difficulty"`
+	IsFree      *bool    `json:"isFree"`
+	Language    string   `json:"language"`
+	Source      string   `json:"source"`
+	Topics      []string `json:"topics"` // Courses with any of the topics
+	MinDuration int      `json:"minDuration"`
+	MaxDuration int      `json:"maxDuration"`
+}
+
+type RoadmapFilter struct {
+	Difficulty string   `json:"difficulty"`
+	Topics     []string `json:"topics"` // Roadmaps with any of the topics
+	AuthorID   string   `json:"authorId"`
+	Verified   *bool    `json:"verified"`
+}
+
+// SortOrder orders a listing by one of its fields, an empty field keeps the table order
+type SortOrder struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"descending"`
+}
+
+// FacetCount is how many items of a listing have the value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// CourseFacets are counted over the courses matching the filter, not only the current page
+type CourseFacets struct {
+	Total      int          `json:"total"`
+	Difficulty []FacetCount `json:"difficulty"`
+	IsFree     []FacetCount `json:"isFree"`
+	Language   []FacetCount `json:"language"`
+	Source     []FacetCount `json:"source"`
+	Topics     []FacetCount `json:"topics"`
+	Duration   []FacetCount `json:"duration"`
+	// Partial facets stopped counting before the last matching course
+	Partial bool `json:"partial"`
+}
+
+type RoadmapFacets struct {
+	Total      int          `json:"total"`
+	Difficulty []FacetCount `json:"difficulty"`
+	Topics     []FacetCount `json:"topics"`
+	Verified   []FacetCount `json:"verified"`
+	// Partial facets stopped counting before the last matching roadmap
+	Partial bool `json:"partial"`
+}
+
+// SearchResult is a course or roadmap matching a search, only the one named by Kind is set
+type SearchResult struct {
+	Kind    string   `json:"kind"`
+	Score   float64  `json:"score"`
+	Course  *Course  `json:"course,omitempty"`
+	Roadmap *Roadmap `json:"roadmap,omitempty"`
 }
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type Handler func(ctx context.Context, event Event) error
+
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Subscriber takes the handlers of an event. The handler name tells deliveries of the same
+// message apart, it must be unique among the handlers of the event and stable across deploys
+type Subscriber interface {
+	Subscribe(event, handlerName string, handler Handler)
+}
+
+// DeliveryLedger remembers the handlers that already handled a message, so a message delivered
+// again only reaches the handlers that failed
+type DeliveryLedger interface {
+	Delivered(ctx context.Context, messageID string) (map[string]bool, error)
+	MarkDelivered(ctx context.Context, messageID, handlerName string) error
+}
+
+type subscription struct {
+	name    string
+	handler Handler
+}
+
+// InProcessBus dispatches every event synchronously to the handlers subscribed to it, in
+// subscription order. A failing handler does not stop the others, their errors are joined
+type InProcessBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]subscription
+}
+
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{handlers: make(map[string][]subscription)}
+}
+
+func (b *InProcessBus) Subscribe(event, handlerName string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[event] = append(b.handlers[event], subscription{name: handlerName, handler: handler})
+}
+
+// Publish dispatches a new occurrence of the event to every handler
+func (b *InProcessBus) Publish(ctx context.Context, event Event) error {
+	ctx = WithMessage(ctx, Message{ID: uuid.NewString(), OccurredAt: time.Now().UTC()})
+	return b.dispatch(ctx, event, nil, nil)
+}
+
+// Deliver dispatches a stored message to the handlers the ledger has not recorded yet, and
+// records each handler that succeeds
+func (b *InProcessBus) Deliver(ctx context.Context, message Message, event Event, ledger DeliveryLedger) error {
+	delivered, err := ledger.Delivered(ctx, message.ID)
+	if err != nil {
+		return err
+	}
+
+	ctx = WithMessage(ctx, message)
+	return b.dispatch(ctx, event, delivered, func(name string) error {
+		return ledger.MarkDelivered(ctx, message.ID, name)
+	})
+}
+
+func (b *InProcessBus) dispatch(ctx context.Context, event Event, skip map[string]bool, done func(name string) error) error {
+	b.mu.RLock()
+	subscriptions := b.handlers[event.EventName()]
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		if skip[subscription.name] {
+			continue
+		}
+
+		if err := subscription.handler(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s handler %s: %w", event.EventName(), subscription.name, err))
+			continue
+		}
+
+		if done != nil {
+			if err := done(subscription.name); err != nil {
+				errs = append(errs, fmt.Errorf("%s handler %s: recording delivery: %w", event.EventName(), subscription.name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
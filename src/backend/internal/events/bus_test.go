@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type memoryLedger map[string]map[string]bool
+
+func (l memoryLedger) Delivered(ctx context.Context, messageID string) (map[string]bool, error) {
+	return l[messageID], nil
+}
+
+func (l memoryLedger) MarkDelivered(ctx context.Context, messageID, handlerName string) error {
+	if l[messageID] == nil {
+		l[messageID] = make(map[string]bool)
+	}
+	l[messageID][handlerName] = true
+	return nil
+}
+
+func TestDeliverOnlyRetriesFailedHandlers(t *testing.T) {
+	calls := map[string]int{}
+	failing := true
+
+	bus := NewInProcessBus()
+	bus.Subscribe(RoadmapLikedEvent, "achievements", func(ctx context.Context, event Event) error {
+		calls["achievements"]++
+		return nil
+	})
+	bus.Subscribe(RoadmapLikedEvent, "webhooks", func(ctx context.Context, event Event) error {
+		calls["webhooks"]++
+		if failing {
+			return errors.New("endpoint down")
+		}
+		return nil
+	})
+
+	ledger := memoryLedger{}
+	message := Message{ID: "message-1", OccurredAt: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	event := RoadmapLiked{UserID: "ana", RoadmapID: "roadmap-1"}
+
+	if err := bus.Deliver(context.Background(), message, event, ledger); err == nil {
+		t.Fatal("got no error, want the failing handler's")
+	}
+
+	failing = false
+	if err := bus.Deliver(context.Background(), message, event, ledger); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if err := bus.Deliver(context.Background(), message, event, ledger); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	if calls["achievements"] != 1 || calls["webhooks"] != 2 {
+		t.Errorf("got calls %v, want achievements once and webhooks twice", calls)
+	}
+}
+
+func TestHandlersSeeTheMessage(t *testing.T) {
+	var seen []Message
+
+	bus := NewInProcessBus()
+	bus.Subscribe(RoadmapLikedEvent, "recorder", func(ctx context.Context, event Event) error {
+		message, ok := MessageFrom(ctx)
+		if !ok {
+			t.Error("handler context has no message")
+		}
+		seen = append(seen, message)
+		return nil
+	})
+
+	stored := Message{ID: "message-1", OccurredAt: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+	if err := bus.Deliver(context.Background(), stored, RoadmapLiked{}, memoryLedger{}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if err := bus.Publish(context.Background(), RoadmapLiked{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("got %d messages, want 2", len(seen))
+	}
+	if seen[0] != stored {
+		t.Errorf("delivered message: got %+v, want %+v", seen[0], stored)
+	}
+	if seen[1].ID == "" || seen[1].ID == stored.ID || seen[1].OccurredAt.IsZero() {
+		t.Errorf("published message: got %+v, want a new id and time", seen[1])
+	}
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	RoadmapLikedEvent      = "RoadmapLiked"
+	RoadmapCreatedEvent    = "RoadmapCreated"
+	RoadmapCompletedEvent  = "RoadmapCompleted"
+	RoadmapProgressedEvent = "RoadmapProgressed"
+	CourseCompletedEvent   = "CourseCompleted"
+	ChallengeAnsweredEvent = "ChallengeAnswered"
+	UserRegisteredEvent    = "UserRegistered"
+	RoadmapViewedEvent     = "RoadmapViewed"
+	TopicsMergedEvent      = "TopicsMerged"
+)
+
+// Event is something that happened to a user or to content. Events are immutable facts,
+// subscribers must not expect them to carry more than their own fields
+type Event interface {
+	EventName() string
+}
+
+type RoadmapLiked struct {
+	UserID    string `json:"userId"`
+	RoadmapID string `json:"roadmapId"`
+	AuthorID  string `json:"authorId"`
+}
+
+type RoadmapCreated struct {
+	UserID    string   `json:"userId"`
+	RoadmapID string   `json:"roadmapId"`
+	Topics    []string `json:"topics"`
+}
+
+type RoadmapCompleted struct {
+	UserID    string   `json:"userId"`
+	RoadmapID string   `json:"roadmapId"`
+	AuthorID  string   `json:"authorId"`
+	Topics    []string `json:"topics"`
+}
+
+// RoadmapProgressed is a step forward on a tracked roadmap, Progress is the number of courses done
+type RoadmapProgressed struct {
+	UserID       string `json:"userId"`
+	RoadmapID    string `json:"roadmapId"`
+	Progress     int    `json:"progress"`
+	TotalCourses int    `json:"totalCourses"`
+}
+
+// CourseCompleted is the course of a roadmap the user just went through
+type CourseCompleted struct {
+	UserID    string `json:"userId"`
+	RoadmapID string `json:"roadmapId"`
+	CourseID  string `json:"courseId"`
+}
+
+type ChallengeAnswered struct {
+	UserID     string `json:"userId"`
+	QuestionID string `json:"questionId,omitempty"`
+	Topic      string `json:"topic"`
+	Rating     int    `json:"rating"`
+	// Streak is the user's daily challenge streak once the answer was counted
+	Streak int `json:"streak"`
+}
+
+type UserRegistered struct {
+	UserID string   `json:"userId"`
+	Email  string   `json:"email"`
+	Topics []string `json:"topics"`
+}
+
+// RoadmapViewed is a learner opening a roadmap
+type RoadmapViewed struct {
+	UserID    string `json:"userId"`
+	RoadmapID string `json:"roadmapId"`
+}
+
+// TopicsMerged is topics folded into a target, the users referring to the sources are moved
+// over by its subscriber rather than while merging
+type TopicsMerged struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
+func (RoadmapLiked) EventName() string      { return RoadmapLikedEvent }
+func (RoadmapCreated) EventName() string    { return RoadmapCreatedEvent }
+func (RoadmapCompleted) EventName() string  { return RoadmapCompletedEvent }
+func (RoadmapProgressed) EventName() string { return RoadmapProgressedEvent }
+func (CourseCompleted) EventName() string   { return CourseCompletedEvent }
+func (ChallengeAnswered) EventName() string { return ChallengeAnsweredEvent }
+func (UserRegistered) EventName() string    { return UserRegisteredEvent }
+func (RoadmapViewed) EventName() string     { return RoadmapViewedEvent }
+func (TopicsMerged) EventName() string      { return TopicsMergedEvent }
+
+var decoders = map[string]func(payload []byte) (Event, error){
+	RoadmapLikedEvent:      decodeAs[RoadmapLiked],
+	RoadmapCreatedEvent:    decodeAs[RoadmapCreated],
+	RoadmapCompletedEvent:  decodeAs[RoadmapCompleted],
+	RoadmapProgressedEvent: decodeAs[RoadmapProgressed],
+	CourseCompletedEvent:   decodeAs[CourseCompleted],
+	ChallengeAnsweredEvent: decodeAs[ChallengeAnswered],
+	UserRegisteredEvent:    decodeAs[UserRegistered],
+	RoadmapViewedEvent:     decodeAs[RoadmapViewed],
+	TopicsMergedEvent:      decodeAs[TopicsMerged],
+}
+
+func decodeAs[T Event](payload []byte) (Event, error) {
+	var event T
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Decode turns a stored payload back into its typed event
+func Decode(name string, payload []byte) (Event, error) {
+	decode, ok := decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %s", name)
+	}
+	return decode(payload)
+}
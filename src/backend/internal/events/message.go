@@ -0,0 +1,25 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// Message identifies one occurrence of an event. Handlers read it from their context to tell
+// occurrences apart, e.g. to derive idempotency keys, and to date what the event records
+type Message struct {
+	ID         string
+	OccurredAt time.Time
+}
+
+type messageKey struct{}
+
+func WithMessage(ctx context.Context, message Message) context.Context {
+	return context.WithValue(ctx, messageKey{}, message)
+}
+
+// MessageFrom returns the message being handled, false outside of a handler
+func MessageFrom(ctx context.Context) (Message, bool) {
+	message, ok := ctx.Value(messageKey{}).(Message)
+	return message, ok
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/repository"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// Delivered messages are kept for a week for troubleshooting
+const outboxRetention = 7 * 24 * time.Hour
+
+// OutboxPublisher stores events in the outbox table instead of dispatching them, an OutboxRelay
+// delivers them later. Handlers only pay for one write and never fail on a subscriber
+type OutboxPublisher struct {
+	repo repository.IOutboxRepository
+}
+
+func NewOutboxPublisher(repo repository.IOutboxRepository) *OutboxPublisher {
+	return &OutboxPublisher{repo: repo}
+}
+
+func (p *OutboxPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.repo.Insert(ctx, &domain.OutboxMessage{
+		ID:        uuid.NewString(),
+		Type:      event.EventName(),
+		Payload:   string(payload),
+		Status:    constants.OutboxPending,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// OutboxRelay hands pending outbox messages to a bus. A message whose handlers failed is retried
+// on the next run, the ledger keeps the handlers that succeeded from seeing it again. Delivery is
+// still at least once per handler, a handler whose success could not be recorded runs again
+type OutboxRelay struct {
+	repo   repository.IOutboxRepository
+	bus    *InProcessBus
+	ledger DeliveryLedger
+}
+
+func NewOutboxRelay(repo repository.IOutboxRepository, bus *InProcessBus, ledger DeliveryLedger) *OutboxRelay {
+	return &OutboxRelay{repo: repo, bus: bus, ledger: ledger}
+}
+
+// Deliver publishes up to limit pending messages and returns how many were delivered
+func (r *OutboxRelay) Deliver(ctx context.Context, limit int) (int, error) {
+	messages, err := r.repo.GetPending(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, message := range messages {
+		err := r.deliver(ctx, message)
+		if err == nil {
+			message.Status = constants.OutboxDelivered
+			message.DeliveredAt = time.Now().UTC()
+			message.ExpiresAt = message.DeliveredAt.Add(outboxRetention).Unix()
+			message.LastError = ""
+			delivered++
+		} else {
+			message.Attempts++
+			message.LastError = err.Error()
+			if message.Attempts >= constants.OutboxMaxAttempts {
+				log.Printf("Giving up on outbox message %s (%s): %v", message.ID, message.Type, err)
+				message.Status = constants.OutboxFailed
+			}
+		}
+
+		if err := r.repo.Update(ctx, message); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+func (r *OutboxRelay) deliver(ctx context.Context, message *domain.OutboxMessage) error {
+	event, err := Decode(message.Type, []byte(message.Payload))
+	if err != nil {
+		return err
+	}
+
+	return r.bus.Deliver(ctx, Message{ID: message.ID, OccurredAt: message.CreatedAt}, event, r.ledger)
+}
@@ -0,0 +1,53 @@
+package pagination
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+type Edge[T any] struct {
+	Node   T      `json:"node"`
+	Cursor string `json:"cursor"`
+}
+
+// Connection is a Relay connection over a page of a listing
+type Connection[T any] struct {
+	Edges      []Edge[T] `json:"edges"`
+	PageInfo   PageInfo  `json:"pageInfo"`
+	TotalCount *int      `json:"totalCount"`
+}
+
+func NewConnection[T any](codec *Codec, list string, request Request, page Page[T]) (*Connection[T], error) {
+	connection := &Connection[T]{
+		Edges: make([]Edge[T], len(page.Items)),
+		PageInfo: PageInfo{
+			HasNextPage:     page.HasNextPage,
+			HasPreviousPage: !request.After.IsZero(),
+		},
+		TotalCount: page.TotalCount,
+	}
+
+	for i, item := range page.Items {
+		cursor, err := codec.Encode(list, page.Positions[i])
+		if err != nil {
+			return nil, err
+		}
+		connection.Edges[i] = Edge[T]{Node: item, Cursor: cursor}
+	}
+
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	if page.HasNextPage && !page.EndPosition.IsZero() {
+		cursor, err := codec.Encode(list, page.EndPosition)
+		if err != nil {
+			return nil, err
+		}
+		connection.PageInfo.EndCursor = &cursor
+	}
+
+	return connection, nil
+}
@@ -0,0 +1,106 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+// CursorVersion is bumped whenever the cursor payload changes, older cursors are then rejected
+const CursorVersion = 2
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrExpiredCursor is returned for a cursor into a ranking snapshot that is gone, the
+	// listing has to be started again from the first page
+	ErrExpiredCursor = errors.New("cursor expired, start again from the first page")
+)
+
+// Position is where a listing continues from: right after the item with the key for listings
+// read in key order, or at the offset for listings ordered in memory. Rankings computed per
+// request are cut from the snapshot saved with their first page
+type Position struct {
+	Key      map[string]string `json:"k,omitempty"`
+	Offset   int               `json:"o,omitempty"`
+	Snapshot string            `json:"s,omitempty"`
+}
+
+func (p Position) IsZero() bool {
+	return len(p.Key) == 0 && p.Offset == 0 && p.Snapshot == ""
+}
+
+type cursorPayload struct {
+	Version int    `json:"v"`
+	List    string `json:"l"`
+	Position
+}
+
+// Codec turns positions into opaque cursors. Cursors are signed so clients cannot forge keys,
+// and carry the listing they belong to so one cannot be replayed on another
+type Codec struct {
+	secret []byte
+}
+
+func NewCodec(secret []byte) *Codec {
+	return &Codec{secret: secret}
+}
+
+func NewCodecFromEnv() (*Codec, error) {
+	secret := os.Getenv("CURSOR_SECRET")
+	if secret == "" {
+		return nil, errors.New("CURSOR_SECRET is not set")
+	}
+	return NewCodec([]byte(secret)), nil
+}
+
+func (c *Codec) Encode(list string, position Position) (string, error) {
+	payload, err := json.Marshal(cursorPayload{Version: CursorVersion, List: list, Position: position})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(encoded)), nil
+}
+
+// Decode checks the cursor was issued for the listing, an empty cursor is the first page
+func (c *Codec) Decode(list, cursor string) (Position, error) {
+	if cursor == "" {
+		return Position{}, nil
+	}
+
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return Position{}, ErrInvalidCursor
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(encoded)) {
+		return Position{}, ErrInvalidCursor
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Position{}, ErrInvalidCursor
+	}
+
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return Position{}, ErrInvalidCursor
+	}
+	if payload.Version != CursorVersion || payload.List != list {
+		return Position{}, ErrInvalidCursor
+	}
+
+	return payload.Position, nil
+}
+
+func (c *Codec) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,87 @@
+package pagination
+
+const (
+	DefaultFirst = 20
+	MaxFirst     = 100
+)
+
+// Arguments are the Relay pagination arguments of a list field
+type Arguments struct {
+	First int    `json:"first"`
+	After string `json:"after"`
+}
+
+// Request asks for the first items of a listing after a position
+type Request struct {
+	First int
+	After Position
+}
+
+// Request decodes the arguments of a list field into a request on the listing
+func (c *Codec) Request(list string, arguments Arguments) (Request, error) {
+	after, err := c.Decode(list, arguments.After)
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{First: arguments.First, After: after}, nil
+}
+
+// Limit is the page size asked for, within bounds
+func (r Request) Limit() int {
+	if r.First <= 0 {
+		return DefaultFirst
+	}
+	if r.First > MaxFirst {
+		return MaxFirst
+	}
+	return r.First
+}
+
+// Page is a slice of a listing, Positions[i] is where the listing continues after Items[i]
+type Page[T any] struct {
+	Items       []T
+	Positions   []Position
+	HasNextPage bool
+	// EndPosition is where the next page starts when reading stopped past the last item, as a
+	// filtered read does when it reaches its budget. The page may then hold no item at all
+	EndPosition Position
+	// TotalCount is only set when the backend knows it without reading further
+	TotalCount *int
+}
+
+// NextPosition is where the page after this one starts
+func (p Page[T]) NextPosition() Position {
+	if !p.EndPosition.IsZero() {
+		return p.EndPosition
+	}
+	if len(p.Positions) == 0 {
+		return Position{}
+	}
+	return p.Positions[len(p.Positions)-1]
+}
+
+// SliceByOffset pages a listing that is already ordered in memory and stays in the same order
+// between requests. Rankings recomputed per request page through a snapshot instead
+func SliceByOffset[T any](items []T, request Request) Page[T] {
+	start := request.After.Offset
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + request.Limit()
+	if end > len(items) {
+		end = len(items)
+	}
+
+	positions := make([]Position, 0, end-start)
+	for i := start; i < end; i++ {
+		positions = append(positions, Position{Offset: i + 1})
+	}
+
+	total := len(items)
+	return Page[T]{
+		Items:       items[start:end],
+		Positions:   positions,
+		HasNextPage: end < len(items),
+		TotalCount:  &total,
+	}
+}
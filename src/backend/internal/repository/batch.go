@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"sync"
+	"time"
+)
+
+const (
+	// BatchGetItem reads at most 100 keys per call and BatchWriteItem writes at most 25 items
+	batchGetLimit   = 100
+	batchWriteLimit = 25
+
+	// Chunks sent at the same time, enough to be quick without eating the table's capacity
+	batchConcurrency = 4
+
+	// Unprocessed keys and items are sent again after a backoff doubling from the base, and
+	// given up on after the last attempt
+	batchMaxAttempts = 8
+	batchBaseBackoff = 50 * time.Millisecond
+	batchMaxBackoff  = 2 * time.Second
+)
+
+// idKeys builds the keys of a table keyed by id, without duplicates, which BatchGetItem rejects
+func idKeys(ids []string) []map[string]*dynamodb.AttributeValue {
+	seen := make(map[string]bool, len(ids))
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		})
+	}
+	return keys
+}
+
+// getByIDs batch reads the items of a table keyed by id, in the order of the ids. Items that do
+// not exist are left out
+func getByIDs[T any](ctx context.Context, db *dynamodb.DynamoDB, tableName string, ids []string) ([]T, error) {
+	items, err := itemsByID(ctx, db, tableName, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]map[string]*dynamodb.AttributeValue, 0, len(items))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if item, ok := items[id]; ok && !seen[id] {
+			seen[id] = true
+			ordered = append(ordered, item)
+		}
+	}
+
+	decoded := make([]T, 0, len(ordered))
+	if err := dynamodbattribute.UnmarshalListOfMaps(ordered, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// batchGetItems reads the items with the keys from the table, in no particular order. Items that
+// do not exist are left out. Keys must not repeat
+func batchGetItems(ctx context.Context, db *dynamodb.DynamoDB, tableName string, keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	var mu sync.Mutex
+	items := make([]map[string]*dynamodb.AttributeValue, 0, len(keys))
+
+	err := forEachChunk(ctx, len(keys), batchGetLimit, func(ctx context.Context, start, end int) error {
+		requestItems := map[string]*dynamodb.KeysAndAttributes{
+			tableName: {Keys: keys[start:end]},
+		}
+		for attempt := 1; ; attempt++ {
+			result, err := db.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems})
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			items = append(items, result.Responses[tableName]...)
+			mu.Unlock()
+
+			requestItems = result.UnprocessedKeys
+			if len(requestItems) == 0 {
+				return nil
+			}
+			if attempt == batchMaxAttempts {
+				return fmt.Errorf("%d keys of %s left unprocessed after %d attempts", len(requestItems[tableName].Keys), tableName, attempt)
+			}
+			if err := batchBackoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// batchWriteItems runs the put and delete requests against the table
+func batchWriteItems(ctx context.Context, db *dynamodb.DynamoDB, tableName string, requests []*dynamodb.WriteRequest) error {
+	return forEachChunk(ctx, len(requests), batchWriteLimit, func(ctx context.Context, start, end int) error {
+		requestItems := map[string][]*dynamodb.WriteRequest{
+			tableName: requests[start:end],
+		}
+		for attempt := 1; ; attempt++ {
+			result, err := db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{RequestItems: requestItems})
+			if err != nil {
+				return err
+			}
+
+			requestItems = result.UnprocessedItems
+			if len(requestItems) == 0 {
+				return nil
+			}
+			if attempt == batchMaxAttempts {
+				return fmt.Errorf("%d writes to %s left unprocessed after %d attempts", len(requestItems[tableName]), tableName, attempt)
+			}
+			if err := batchBackoff(ctx, attempt); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// forEachChunk calls fn with the bounds of every chunk of size out of total, a few chunks at a
+// time. The first error cancels the chunks still running and is returned
+func forEachChunk(ctx context.Context, total, size int, fn func(ctx context.Context, start, end int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, batchConcurrency)
+	for start := 0; start < total; start += size {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(ctx, start, end); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, min(start+size, total))
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// batchBackoff waits before the next attempt, or until the context is done
+func batchBackoff(ctx context.Context, attempt int) error {
+	wait := min(batchBaseBackoff<<(attempt-1), batchMaxBackoff)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// sortableTimeLayout formats UTC times with a fixed width, so they sort as strings in time order.
+// time.Time still reads them back from JSON
+const sortableTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// DynamoDBChallengeAttemptRepository stores attempts with username as partition key and createdAt as sort key
+type DynamoDBChallengeAttemptRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBChallengeAttemptRepository(sess *session.Session, tableName string) *DynamoDBChallengeAttemptRepository {
+	return &DynamoDBChallengeAttemptRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBChallengeAttemptRepository) Insert(ctx context.Context, attempt *domain.ChallengeAttempt) error {
+	attempt.CreatedAt = attempt.CreatedAt.UTC()
+
+	item, err := dynamodbattribute.MarshalMap(attempt)
+	if err != nil {
+		return err
+	}
+	// RFC3339Nano drops trailing zeros of the fraction, which breaks the lexicographic order of the sort key
+	item["createdAt"] = &dynamodb.AttributeValue{S: aws.String(attempt.CreatedAt.Format(sortableTimeLayout))}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// GetByUser returns a page of the user's attempts, newest first
+func (r *DynamoDBChallengeAttemptRepository) GetByUser(ctx context.Context, username string, request pagination.Request) (pagination.Page[*domain.ChallengeAttempt], error) {
+	input := r.queryByUser(username)
+	input.ScanIndexForward = aws.Bool(false)
+
+	raw, err := queryPage(ctx, r.db, input, request, "username", "createdAt")
+	if err != nil {
+		return pagination.Page[*domain.ChallengeAttempt]{}, err
+	}
+	return decodePage[*domain.ChallengeAttempt](raw)
+}
+
+func (r *DynamoDBChallengeAttemptRepository) GetAllByUser(ctx context.Context, username string) ([]*domain.ChallengeAttempt, error) {
+	attempts := make([]*domain.ChallengeAttempt, 0)
+	var unmarshalErr error
+	err := r.db.QueryPagesWithContext(ctx, r.queryByUser(username), func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageAttempts []*domain.ChallengeAttempt
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageAttempts); unmarshalErr != nil {
+			return false
+		}
+		attempts = append(attempts, pageAttempts...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return attempts, nil
+}
+
+func (r *DynamoDBChallengeAttemptRepository) queryByUser(username string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("username = :username"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":username": {S: aws.String(username)},
+		},
+	}
+}
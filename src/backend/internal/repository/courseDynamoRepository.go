@@ -1,8 +1,9 @@
 package repository
 
 import (
+	"backend/internal/constants"
 	"backend/internal/domain"
-	"backend/internal/utils"
+	"backend/internal/pagination"
 	"context"
 	"errors"
 	"fmt"
@@ -10,64 +11,147 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// DynamoDBCourseRepository stores courses by id, with a url-index GSI, the sparse broken-index
+// GSI (linkState, linkCheckedAt) and the title-index and duration-index listing GSIs
+// (listing, sortTitle) and (listing, sortDuration) that sorted listings page through. Writes keep
+// the topic edges and facet counts of the course in step with it
 type DynamoDBCourseRepository struct {
 	db        *dynamodb.DynamoDB
+	edges     ITopicEdgeRepository
+	facets    *DynamoDBFacetCountRepository
+	items     topicItemTable
 	tableName string
 }
 
-func NewDynamoDBCourseRepository(sess *session.Session, tableName string) *DynamoDBCourseRepository {
+func NewDynamoDBCourseRepository(sess *session.Session, tableName string, edges ITopicEdgeRepository, facets *DynamoDBFacetCountRepository) *DynamoDBCourseRepository {
+	db := dynamodb.New(sess)
 	return &DynamoDBCourseRepository{
-		db:        dynamodb.New(sess),
+		db:     db,
+		edges:  edges,
+		facets: facets,
+		items: topicItemTable{
+			db:              db,
+			tableName:       tableName,
+			kind:            constants.TopicItemCourse,
+			edges:           edges,
+			facets:          facets,
+			facetAttributes: []string{"difficulty", "isFree", "language", "source", "topics", "duration"},
+			facetValues:     courseFacetValues,
+		},
 		tableName: tableName,
 	}
 }
 
-func (r *DynamoDBCourseRepository) GetAllCourses(ctx context.Context, pagination utils.Pagination) ([]*domain.Course, *utils.Pagination, error) {
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
-		Limit:     aws.Int64(int64(pagination.PerPage)),
+// courseFacetValues are the facet values a stored course is counted under
+func courseFacetValues(item map[string]*dynamodb.AttributeValue) ([]string, error) {
+	var course domain.Course
+	if err := dynamodbattribute.UnmarshalMap(item, &course); err != nil {
+		return nil, err
 	}
 
-	// Set the ExclusiveStartKey if this is not the first page
-	if pagination.LastEvaluatedKey != nil {
-		input.ExclusiveStartKey = pagination.LastEvaluatedKey
+	values := []string{facetValue("isFree", strconv.FormatBool(course.IsFree))}
+	for facet, value := range map[string]string{
+		"difficulty": course.Difficulty,
+		"language":   course.Language,
+		"source":     course.Source,
+		"duration":   durationBucket(course.Duration),
+	} {
+		if value != "" {
+			values = append(values, facetValue(facet, value))
+		}
+	}
+	for _, topic := range course.Topics {
+		values = append(values, facetValue("topics", topic))
 	}
+	return values, nil
+}
 
-	result, err := r.db.ScanWithContext(ctx, input)
-	if err != nil {
-		return nil, nil, err
+// GetAllCourses reads the course table a page at a time, in key order
+func (r *DynamoDBCourseRepository) GetAllCourses(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Course], error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
 	}
 
-	var courses []*domain.Course
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &courses)
+	raw, err := scanPage(ctx, r.db, input, request, "id")
 	if err != nil {
-		return nil, nil, err
+		return pagination.Page[*domain.Course]{}, err
 	}
+	return decodePage[*domain.Course](raw)
+}
 
-	// Log size
-	fmt.Println("Size of courses: ", len(courses))
-
-	// Update the pagination LastEvaluatedKey
-	pagination.LastEvaluatedKey = result.LastEvaluatedKey
-
-	return courses, &pagination, nil
+// courseEditableAttributes are the attributes an upsert writes. The link check fields belong to
+// the link checker and survive edits
+var courseEditableAttributes = []string{
+	"title", "url", "description", "source", "difficulty", "topics",
+	"isFree", "author", "duration", "language", "imageUrl",
 }
 
+// UpsertCourse saves the editable fields of the course, with its facet counts, and moves its topic
+// edges to its current topics. The creation date of a stored course is kept, and the course is
+// refreshed with the stored item, link check included
 func (r *DynamoDBCourseRepository) UpsertCourse(ctx context.Context, course *domain.Course) error {
 	item, err := dynamodbattribute.MarshalMap(course)
 	if err != nil {
 		return err
 	}
+	item[facetsCountedAttribute] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
 
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+	names := map[string]*string{"#createdAt": aws.String("createdAt")}
+	values := map[string]*dynamodb.AttributeValue{":createdAt": item["createdAt"]}
+	assignments := make([]string, 0, len(courseEditableAttributes)+1)
+	for i, attribute := range courseEditableAttributes {
+		name, value := fmt.Sprintf("#a%d", i), fmt.Sprintf(":a%d", i)
+		names[name] = aws.String(attribute)
+		values[value] = item[attribute]
+		assignments = append(assignments, name+" = "+value)
+	}
+	assignments = append(assignments, "#createdAt = if_not_exists(#createdAt, :createdAt)")
+	names["#facetsCounted"] = aws.String(facetsCountedAttribute)
+	values[":facetsCounted"] = item[facetsCountedAttribute]
+	assignments = append(assignments, "#facetsCounted = :facetsCounted")
+	for attribute, value := range courseListingAttributes(course) {
+		names["#"+attribute] = aws.String(attribute)
+		values[":"+attribute] = value
+		assignments = append(assignments, "#"+attribute+" = :"+attribute)
 	}
 
-	_, err = r.db.PutItemWithContext(ctx, input)
-	return err
+	err = r.items.write(ctx, course.ID, func(stored storedTopicState) (*dynamodb.TransactWriteItem, map[string]*dynamodb.AttributeValue, error) {
+		write := &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(r.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(course.ID)},
+				},
+				UpdateExpression:          aws.String("SET " + strings.Join(assignments, ", ")),
+				ConditionExpression:       aws.String(stored.condition(names, values)),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		}
+		return write, item, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Transactions return no attributes, the stored course is read back
+	result, err := r.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(course.ID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	return dynamodbattribute.UnmarshalMap(result.Item, course)
 }
 
 func (r *DynamoDBCourseRepository) GetCourseByID(ctx context.Context, courseID string) (*domain.Course, error) {
@@ -96,6 +180,11 @@ func (r *DynamoDBCourseRepository) GetCourseByID(ctx context.Context, courseID s
 	return &course, nil
 }
 
+// GetByIDs batch reads the courses in the order of the ids, leaving out deleted ones
+func (r *DynamoDBCourseRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.Course, error) {
+	return getByIDs[*domain.Course](ctx, r.db, r.tableName, ids)
+}
+
 func (r *DynamoDBCourseRepository) GetBulkByUrl(ctx context.Context, urls []string) ([]*domain.Course, error) {
 	courses := make([]*domain.Course, 0)
 
@@ -129,30 +218,229 @@ func (r *DynamoDBCourseRepository) GetBulkByUrl(ctx context.Context, urls []stri
 	return courses, nil
 }
 
+// BulkInsert saves the courses one at a time: each is written in a transaction with its facet
+// counts, which a batch write cannot hold
 func (r *DynamoDBCourseRepository) BulkInsert(ctx context.Context, courses []*domain.Course) error {
-	writeRequests := make([]*dynamodb.WriteRequest, len(courses))
-	for i, course := range courses {
-		item, err := dynamodbattribute.MarshalMap(course)
+	for _, course := range courses {
+		if err := r.UpsertCourse(ctx, course); err != nil {
+			return fmt.Errorf("failed to insert course %s: %w", course.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateLinkStatus records a link check. Broken courses also get linkState, the partition key of
+// the sparse broken-index GSI, which healthy courses do not have
+func (r *DynamoDBCourseRepository) UpdateLinkStatus(ctx context.Context, courseID string, statusCode int, broken bool, checkedAt time.Time) error {
+	checkedAtValue, err := dynamodbattribute.Marshal(checkedAt)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]*dynamodb.AttributeValue{
+		":broken":    {BOOL: aws.Bool(broken)},
+		":code":      {N: aws.String(fmt.Sprint(statusCode))},
+		":checkedAt": checkedAtValue,
+	}
+	expression := "SET broken = :broken, linkStatusCode = :code, linkCheckedAt = :checkedAt"
+	if broken {
+		expression += ", linkState = :linkState"
+		values[":linkState"] = &dynamodb.AttributeValue{S: aws.String(linkStateBroken)}
+	} else {
+		expression += " REMOVE linkState"
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(courseID)},
+		},
+		UpdateExpression:          aws.String(expression),
+		ConditionExpression:       aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: values,
+	}
+
+	_, err = r.db.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// linkStateBroken is the only linkState value, so the broken-index holds just the broken courses
+const linkStateBroken = "broken"
+
+// GetBrokenCourses reads the broken courses from the sparse broken-index GSI (partition key
+// linkState, sort key linkCheckedAt), oldest check first
+func (r *DynamoDBCourseRepository) GetBrokenCourses(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Course], error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("broken-index"),
+		KeyConditionExpression: aws.String("linkState = :linkState"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":linkState": {S: aws.String(linkStateBroken)},
+		},
+	}
+
+	raw, err := queryPage(ctx, r.db, input, request, "id", "linkState", "linkCheckedAt")
+	if err != nil {
+		return pagination.Page[*domain.Course]{}, err
+	}
+	return decodePage[*domain.Course](raw)
+}
+
+func courseCondition(filter domain.CourseFilter) *conditionBuilder {
+	condition := newConditionBuilder()
+	condition.equals("difficulty", filter.Difficulty)
+	condition.boolean("isFree", filter.IsFree)
+	condition.equals("language", filter.Language)
+	condition.equals("source", filter.Source)
+	condition.containsAny("topics", filter.Topics)
+	condition.atLeast("duration", filter.MinDuration)
+	condition.atMost("duration", filter.MaxDuration)
+	return condition
+}
+
+// FindCourses lists the courses matching the filter, DynamoDB applying it as the items are
+// read. Sorted listings page through the listing index of the field. A page evaluates a bounded
+// number of items, so a selective filter may return a short or empty page with a next cursor.
+// An unsorted filter on one topic alone reads the topic's edges instead
+func (r *DynamoDBCourseRepository) FindCourses(ctx context.Context, filter domain.CourseFilter, order domain.SortOrder, request pagination.Request) (pagination.Page[*domain.Course], error) {
+	if order.Field == "" && len(filter.Topics) == 1 && reflect.DeepEqual(filter, domain.CourseFilter{Topics: filter.Topics}) {
+		return r.FindByTopic(ctx, filter.Topics[0], request)
+	}
+
+	var raw *rawPage
+	var err error
+	if order.Field == "" {
+		input := &dynamodb.ScanInput{
+			TableName: aws.String(r.tableName),
+		}
+		courseCondition(filter).apply(input)
+		raw, err = scanPage(ctx, r.db, input, request, "id")
+	} else {
+		index, ok := courseListingIndexes[order.Field]
+		if !ok {
+			return pagination.Page[*domain.Course]{}, fmt.Errorf("courses cannot be sorted by %s", order.Field)
+		}
+		raw, err = listingPage(ctx, r.db, r.tableName, index, order.Descending, courseCondition(filter), request)
+	}
+	if err != nil {
+		return pagination.Page[*domain.Course]{}, err
+	}
+	return decodePage[*domain.Course](raw)
+}
+
+// courseFacetScope is the facet count scope of a filter on at most one value, which facets are
+// kept for on every write
+func courseFacetScope(filter domain.CourseFilter) (string, bool) {
+	var values []string
+	if filter.Difficulty != "" {
+		values = append(values, facetValue("difficulty", filter.Difficulty))
+	}
+	if filter.IsFree != nil {
+		values = append(values, facetValue("isFree", strconv.FormatBool(*filter.IsFree)))
+	}
+	if filter.Language != "" {
+		values = append(values, facetValue("language", filter.Language))
+	}
+	if filter.Source != "" {
+		values = append(values, facetValue("source", filter.Source))
+	}
+	for _, topic := range filter.Topics {
+		values = append(values, facetValue("topics", topic))
+	}
+	if len(values) > 1 || filter.MinDuration > 0 || filter.MaxDuration > 0 {
+		return "", false
+	}
+	if len(values) == 0 {
+		return facetScope(constants.TopicItemCourse, ""), true
+	}
+	return facetScope(constants.TopicItemCourse, values[0]), true
+}
+
+// GetCourseFacets reads the counts kept on write for the whole catalog or a single filter value.
+// Other filters are counted over at most maxFacetEvaluated courses, the facets being partial
+// when more were left
+func (r *DynamoDBCourseRepository) GetCourseFacets(ctx context.Context, filter domain.CourseFilter) (*domain.CourseFacets, error) {
+	if scope, ok := courseFacetScope(filter); ok {
+		counts, err := r.facets.Get(ctx, scope)
 		if err != nil {
-			return fmt.Errorf("failed to marshal course: %w", err)
+			return nil, err
 		}
-		writeRequests[i] = &dynamodb.WriteRequest{
-			PutRequest: &dynamodb.PutRequest{
-				Item: item,
-			},
+		return &domain.CourseFacets{
+			Total:      counts[facetTotal],
+			Difficulty: facetCounts(counts, "difficulty"),
+			IsFree:     facetCounts(counts, "isFree"),
+			Language:   facetCounts(counts, "language"),
+			Source:     facetCounts(counts, "source"),
+			Topics:     facetCounts(counts, "topics"),
+			Duration:   facetCounts(counts, "duration"),
+		}, nil
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}
+	condition := courseCondition(filter)
+	condition.project(input, "difficulty", "isFree", "language", "source", "topics", "duration")
+	condition.apply(input)
+
+	items, partial, err := scanBounded(ctx, r.db, input, maxFacetEvaluated)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := facetCounter{}
+	for _, item := range items {
+		values, err := courseFacetValues(item)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			counts.add(value)
 		}
 	}
 
-	batchWriteInput := &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]*dynamodb.WriteRequest{
-			r.tableName: writeRequests,
-		},
+	return &domain.CourseFacets{
+		Total:      len(items),
+		Difficulty: facetCounts(counts, "difficulty"),
+		IsFree:     facetCounts(counts, "isFree"),
+		Language:   facetCounts(counts, "language"),
+		Source:     facetCounts(counts, "source"),
+		Topics:     facetCounts(counts, "topics"),
+		Duration:   facetCounts(counts, "duration"),
+		Partial:    partial,
+	}, nil
+}
+
+// durationBucket labels the duration facet bucket of a course, such as "1-5" or "20+" hours
+func durationBucket(hours int) string {
+	buckets := constants.DurationFacetBuckets
+	for i := len(buckets) - 1; i >= 0; i-- {
+		if hours < buckets[i] {
+			continue
+		}
+		if i == len(buckets)-1 {
+			return fmt.Sprintf("%d+", buckets[i])
+		}
+		return fmt.Sprintf("%d-%d", buckets[i], buckets[i+1])
 	}
+	return ""
+}
 
-	_, err := r.db.BatchWriteItemWithContext(ctx, batchWriteInput)
+// GetByTopics reads every course tagged with any of the topics, through their edges
+func (r *DynamoDBCourseRepository) GetByTopics(ctx context.Context, topics []string) ([]*domain.Course, error) {
+	ids, err := edgeItemIDs(ctx, r.edges, constants.TopicItemCourse, topics)
 	if err != nil {
-		return fmt.Errorf("failed to batch write items: %w", err)
+		return nil, err
 	}
+	return r.GetByIDs(ctx, ids)
+}
 
-	return nil
+// FindByTopic pages through the courses tagged with the topic
+func (r *DynamoDBCourseRepository) FindByTopic(ctx context.Context, topic string, request pagination.Request) (pagination.Page[*domain.Course], error) {
+	edges, err := r.edges.GetByTopic(ctx, topic, constants.TopicItemCourse, request)
+	if err != nil {
+		return pagination.Page[*domain.Course]{}, err
+	}
+	return pageByEdges[*domain.Course](ctx, r.db, r.tableName, edges)
 }
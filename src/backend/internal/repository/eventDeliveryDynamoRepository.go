@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"time"
+)
+
+// Deliveries are remembered well past the last retry of their outbox message
+const eventDeliveryRetention = 30 * 24 * time.Hour
+
+// DynamoDBEventDeliveryRepository records the handlers that handled an outbox message, with
+// messageId as partition key and handler as sort key, and an expiresAt TTL attribute
+type DynamoDBEventDeliveryRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBEventDeliveryRepository(sess *session.Session, tableName string) *DynamoDBEventDeliveryRepository {
+	return &DynamoDBEventDeliveryRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+// Delivered returns the names of the handlers that handled the message
+func (r *DynamoDBEventDeliveryRepository) Delivered(ctx context.Context, messageID string) (map[string]bool, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("messageId = :messageId"),
+		ProjectionExpression:   aws.String("handler"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":messageId": {S: aws.String(messageID)},
+		},
+	}
+
+	delivered := make(map[string]bool)
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if handler, ok := item["handler"]; ok && handler.S != nil {
+				delivered[*handler.S] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return delivered, nil
+}
+
+func (r *DynamoDBEventDeliveryRepository) MarkDelivered(ctx context.Context, messageID, handler string) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"messageId": {S: aws.String(messageID)},
+			"handler":   {S: aws.String(handler)},
+			"expiresAt": {N: aws.String(fmt.Sprint(time.Now().Add(eventDeliveryRetention).Unix()))},
+		},
+	}
+
+	_, err := r.db.PutItemWithContext(ctx, input)
+	return err
+}
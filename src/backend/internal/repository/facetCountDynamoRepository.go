@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Facet counts are kept per scope: the whole listing ("course") and every single filter value
+// ("course|difficulty=beginner"). Each scope counts its items as facetTotal and every facet
+// value ("topics=Go") they have
+const (
+	facetTotal          = "total"
+	facetScopeSeparator = "|"
+	// A transaction holds at most 100 writes, the item's and those of its scopes
+	maxFacetScopeWrites = 99
+	// Filters combining several values are counted over at most this many items
+	maxFacetEvaluated = 5000
+)
+
+// DynamoDBFacetCountRepository stores the facet counts of the course and roadmap listings, one
+// item per scope with scope as partition key and a number attribute per counted value. Counts are
+// updated in the transaction writing the course or roadmap, so they follow every write exactly
+type DynamoDBFacetCountRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBFacetCountRepository(sess *session.Session, tableName string) *DynamoDBFacetCountRepository {
+	return &DynamoDBFacetCountRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func facetValue(facet, value string) string {
+	return facet + "=" + value
+}
+
+func facetScope(listing, value string) string {
+	if value == "" {
+		return listing
+	}
+	return listing + facetScopeSeparator + value
+}
+
+// Get returns the counts of a scope by value, empty for a scope no item is in
+func (r *DynamoDBFacetCountRepository) Get(ctx context.Context, scope string) (map[string]int, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"scope": {S: aws.String(scope)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(result.Item))
+	for name, value := range result.Item {
+		if value.N == nil {
+			continue
+		}
+		count, err := strconv.Atoi(*value.N)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			counts[name] = count
+		}
+	}
+	return counts, nil
+}
+
+// updates are the writes moving an item of the listing from its previous facet values to its
+// current ones, in every scope of either. Nil values stand for an item that does not exist
+func (r *DynamoDBFacetCountRepository) updates(listing string, previous, current []string) ([]*dynamodb.TransactWriteItem, error) {
+	deltas := make(map[string]map[string]int)
+	apply := func(values []string, delta int) {
+		if values == nil {
+			return
+		}
+		values = uniqueValues(values)
+		for _, scopeValue := range append([]string{""}, values...) {
+			scope := facetScope(listing, scopeValue)
+			if deltas[scope] == nil {
+				deltas[scope] = make(map[string]int)
+			}
+			deltas[scope][facetTotal] += delta
+			for _, value := range values {
+				deltas[scope][value] += delta
+			}
+		}
+	}
+	apply(previous, -1)
+	apply(current, 1)
+
+	scopes := make([]string, 0, len(deltas))
+	for scope, counts := range deltas {
+		for _, delta := range counts {
+			if delta != 0 {
+				scopes = append(scopes, scope)
+				break
+			}
+		}
+	}
+	if len(scopes) > maxFacetScopeWrites {
+		return nil, fmt.Errorf("item is counted in %d facet scopes, at most %d are updated with it", len(scopes), maxFacetScopeWrites)
+	}
+	sort.Strings(scopes)
+
+	writes := make([]*dynamodb.TransactWriteItem, 0, len(scopes))
+	for _, scope := range scopes {
+		var additions []string
+		names := make(map[string]*string)
+		values := make(map[string]*dynamodb.AttributeValue)
+		for value, delta := range deltas[scope] {
+			if delta == 0 {
+				continue
+			}
+			i := len(additions)
+			names[fmt.Sprintf("#c%d", i)] = aws.String(value)
+			values[fmt.Sprintf(":c%d", i)] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(delta))}
+			additions = append(additions, fmt.Sprintf("#c%d :c%d", i, i))
+		}
+
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(r.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"scope": {S: aws.String(scope)},
+				},
+				UpdateExpression:          aws.String("ADD " + strings.Join(additions, ", ")),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		})
+	}
+	return writes, nil
+}
+
+func uniqueValues(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// facetCounts turns the counts of a scope into the counts of one facet, most common first
+func facetCounts(counts map[string]int, facet string) []domain.FacetCount {
+	counter := facetCounter{}
+	prefix := facetValue(facet, "")
+	for value, count := range counts {
+		if strings.HasPrefix(value, prefix) {
+			counter[strings.TrimPrefix(value, prefix)] += count
+		}
+	}
+	return counter.counts()
+}
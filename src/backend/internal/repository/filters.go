@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// conditionBuilder collects the clauses of a filter expression, every attribute and value goes
+// through a placeholder so reserved words such as "language" or "source" are safe
+type conditionBuilder struct {
+	clauses []string
+	names   map[string]*string
+	values  map[string]*dynamodb.AttributeValue
+}
+
+func newConditionBuilder() *conditionBuilder {
+	return &conditionBuilder{
+		names:  make(map[string]*string),
+		values: make(map[string]*dynamodb.AttributeValue),
+	}
+}
+
+func (b *conditionBuilder) name(attribute string) string {
+	placeholder := "#" + attribute
+	b.names[placeholder] = aws.String(attribute)
+	return placeholder
+}
+
+func (b *conditionBuilder) value(value *dynamodb.AttributeValue) string {
+	placeholder := fmt.Sprintf(":f%d", len(b.values))
+	b.values[placeholder] = value
+	return placeholder
+}
+
+func (b *conditionBuilder) equals(attribute, value string) {
+	if value == "" {
+		return
+	}
+	b.clauses = append(b.clauses, b.name(attribute)+" = "+b.value(&dynamodb.AttributeValue{S: aws.String(value)}))
+}
+
+// boolean treats a missing attribute as false, older items were written without it
+func (b *conditionBuilder) boolean(attribute string, value *bool) {
+	if value == nil {
+		return
+	}
+
+	name := b.name(attribute)
+	clause := name + " = " + b.value(&dynamodb.AttributeValue{BOOL: value})
+	if !*value {
+		clause = "(attribute_not_exists(" + name + ") OR " + clause + ")"
+	}
+	b.clauses = append(b.clauses, clause)
+}
+
+func (b *conditionBuilder) atLeast(attribute string, value int) {
+	if value <= 0 {
+		return
+	}
+	b.clauses = append(b.clauses, b.name(attribute)+" >= "+b.value(&dynamodb.AttributeValue{N: aws.String(strconv.Itoa(value))}))
+}
+
+func (b *conditionBuilder) atMost(attribute string, value int) {
+	if value <= 0 {
+		return
+	}
+	b.clauses = append(b.clauses, b.name(attribute)+" <= "+b.value(&dynamodb.AttributeValue{N: aws.String(strconv.Itoa(value))}))
+}
+
+// containsAny matches list attributes holding at least one of the values
+func (b *conditionBuilder) containsAny(attribute string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	name := b.name(attribute)
+	alternatives := make([]string, len(values))
+	for i, value := range values {
+		alternatives[i] = "contains(" + name + ", " + b.value(&dynamodb.AttributeValue{S: aws.String(value)}) + ")"
+	}
+	b.clauses = append(b.clauses, "("+strings.Join(alternatives, " OR ")+")")
+}
+
+// project adds a projection of the attributes to the scan, through the same placeholders
+func (b *conditionBuilder) project(input *dynamodb.ScanInput, attributes ...string) {
+	names := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		names[i] = b.name(attribute)
+	}
+	input.ProjectionExpression = aws.String(strings.Join(names, ", "))
+	input.ExpressionAttributeNames = b.names
+}
+
+func (b *conditionBuilder) apply(input *dynamodb.ScanInput) {
+	if len(b.names) > 0 {
+		input.ExpressionAttributeNames = b.names
+	}
+	if len(b.clauses) == 0 {
+		return
+	}
+	input.FilterExpression = aws.String(strings.Join(b.clauses, " AND "))
+	input.ExpressionAttributeValues = b.values
+}
+
+// scanAll reads every item passing the filter of the input
+func scanAll(ctx context.Context, db *dynamodb.DynamoDB, input *dynamodb.ScanInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return items, err
+}
+
+// scanBounded reads the items passing the filter of the input until limit items were evaluated,
+// and reports whether the table had more
+func scanBounded(ctx context.Context, db *dynamodb.DynamoDB, input *dynamodb.ScanInput, limit int) ([]map[string]*dynamodb.AttributeValue, bool, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	evaluated := 0
+	more := false
+	err := db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		evaluated += int(aws.Int64Value(page.ScannedCount))
+		more = !lastPage && evaluated >= limit
+		return !more
+	})
+	return items, more, err
+}
+
+// facetCounter tallies the values of a facet, most common first
+type facetCounter map[string]int
+
+func (c facetCounter) add(value string) {
+	if value != "" {
+		c[value]++
+	}
+}
+
+func (c facetCounter) counts() []domain.FacetCount {
+	counts := make([]domain.FacetCount, 0, len(c))
+	for value, count := range c {
+		counts = append(counts, domain.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	return counts
+}
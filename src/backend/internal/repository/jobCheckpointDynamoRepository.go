@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"backend/internal/pagination"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"time"
+)
+
+// DynamoDBJobCheckpointRepository stores where each scheduled job that walks a table stopped,
+// one item per job with job as partition key, so the next run resumes from there
+type DynamoDBJobCheckpointRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+type jobCheckpoint struct {
+	Job       string              `json:"job"`
+	Position  pagination.Position `json:"position"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+}
+
+func NewDynamoDBJobCheckpointRepository(sess *session.Session, tableName string) *DynamoDBJobCheckpointRepository {
+	return &DynamoDBJobCheckpointRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+// Get returns the position the job stopped at, the zero position when it has none
+func (r *DynamoDBJobCheckpointRepository) Get(ctx context.Context, job string) (pagination.Position, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"job": {S: aws.String(job)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return pagination.Position{}, err
+	}
+
+	if result.Item == nil {
+		return pagination.Position{}, nil
+	}
+
+	var checkpoint jobCheckpoint
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &checkpoint); err != nil {
+		return pagination.Position{}, err
+	}
+
+	return checkpoint.Position, nil
+}
+
+// Save records the position the next run of the job starts from, the zero position starts over
+func (r *DynamoDBJobCheckpointRepository) Save(ctx context.Context, job string, position pagination.Position) error {
+	item, err := dynamodbattribute.MarshalMap(jobCheckpoint{
+		Job:       job,
+		Position:  position,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"time"
+)
+
+// DynamoDBLeaderboardRepository stores entries with board as partition key and username as sort key.
+// The score-index local secondary index sorts a board by score and the username-index global
+// secondary index finds every board a user is on
+type DynamoDBLeaderboardRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBLeaderboardRepository(sess *session.Session, tableName string) *DynamoDBLeaderboardRepository {
+	return &DynamoDBLeaderboardRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBLeaderboardRepository) key(board, username string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"board":    {S: aws.String(board)},
+		"username": {S: aws.String(username)},
+	}
+}
+
+// Increment adds delta to the user's score, creating the entry when needed
+func (r *DynamoDBLeaderboardRepository) Increment(ctx context.Context, board, username, displayName string, delta int) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(r.tableName),
+		Key:              r.key(board, username),
+		UpdateExpression: aws.String("ADD score :delta SET displayName = :displayName, updatedAt = :updatedAt"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":delta":       {N: aws.String(fmt.Sprint(delta))},
+			":displayName": {S: aws.String(displayName)},
+			":updatedAt":   {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+	}
+
+	_, err := r.db.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// SetMax raises the user's score to the given one, lower scores leave the entry untouched
+func (r *DynamoDBLeaderboardRepository) SetMax(ctx context.Context, board, username, displayName string, score int) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 r.key(board, username),
+		UpdateExpression:    aws.String("SET score = :score, displayName = :displayName, updatedAt = :updatedAt"),
+		ConditionExpression: aws.String("attribute_not_exists(score) OR score < :score"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":score":       {N: aws.String(fmt.Sprint(score))},
+			":displayName": {S: aws.String(displayName)},
+			":updatedAt":   {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+	}
+
+	_, err := r.db.UpdateItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+// GetTop returns the highest scores on the board, best first
+func (r *DynamoDBLeaderboardRepository) GetTop(ctx context.Context, board string, limit int) ([]*domain.LeaderboardEntry, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("score-index"),
+		KeyConditionExpression: aws.String("board = :board"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":board": {S: aws.String(board)},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(int64(limit)),
+	}
+
+	result, err := r.db.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*domain.LeaderboardEntry, 0)
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetEntries returns the entries the given users have on the board, users without one are left out
+func (r *DynamoDBLeaderboardRepository) GetEntries(ctx context.Context, board string, usernames []string) ([]*domain.LeaderboardEntry, error) {
+	entries := make([]*domain.LeaderboardEntry, 0)
+
+	seen := make(map[string]bool, len(usernames))
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(usernames))
+	for _, username := range usernames {
+		if !seen[username] {
+			seen[username] = true
+			keys = append(keys, r.key(board, username))
+		}
+	}
+
+	items, err := batchGetItems(ctx, r.db, r.tableName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dynamodbattribute.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// RemoveUser deletes every entry of the user on every board
+func (r *DynamoDBLeaderboardRepository) RemoveUser(ctx context.Context, username string) error {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("username-index"),
+		KeyConditionExpression: aws.String("username = :username"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":username": {S: aws.String(username)},
+		},
+		ProjectionExpression: aws.String("board"),
+	}
+
+	boards := make([]string, 0)
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if board, ok := item["board"]; ok && board.S != nil {
+				boards = append(boards, *board.S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, board := range boards {
+		_, err := r.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(r.tableName),
+			Key:       r.key(board, username),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
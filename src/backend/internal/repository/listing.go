@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sorted listings are read from GSIs with the listing attribute as partition key and a sortable
+// copy of the field as sort key. Numbers are zero padded so they sort as strings and pages keep
+// string positions. Items are spread over listingShards partitions by a hash of their id so no
+// partition takes every write, listings read all of them and merge them. Items written before
+// the shards existed hold listingPartition, the value of the first shard
+const (
+	listingPartition = "all"
+	listingShards    = 4
+)
+
+// listingShard is the listing partition of an item
+func listingShard(id string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return listingShardValue(int(hash.Sum32() % listingShards))
+}
+
+func listingShardValue(shard int) string {
+	if shard == 0 {
+		return listingPartition
+	}
+	return fmt.Sprintf("%s#%d", listingPartition, shard)
+}
+
+type listingIndex struct {
+	name      string
+	attribute string
+}
+
+var courseListingIndexes = map[string]listingIndex{
+	constants.SortTitle:    {name: "title-index", attribute: "sortTitle"},
+	constants.SortDuration: {name: "duration-index", attribute: "sortDuration"},
+}
+
+var roadmapListingIndexes = map[string]listingIndex{
+	constants.SortTitle: {name: "title-index", attribute: "sortTitle"},
+	constants.SortLikes: {name: "likes-index", attribute: "sortLikes"},
+}
+
+// newestRoadmapsIndex only holds published roadmaps with a creation date, the others are written
+// without its sort key
+var newestRoadmapsIndex = listingIndex{name: "newest-index", attribute: "publishedAt"}
+
+func sortableTitle(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
+
+func sortableNumber(n int) string {
+	return fmt.Sprintf("%010d", max(n, 0))
+}
+
+func sortableTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// courseListingAttributes are written with every course to place it in the listing indexes
+func courseListingAttributes(course *domain.Course) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"listing":      {S: aws.String(listingShard(course.ID))},
+		"sortTitle":    {S: aws.String(sortableTitle(course.Title))},
+		"sortDuration": {S: aws.String(sortableNumber(course.Duration))},
+	}
+}
+
+// roadmapListingAttributes are written with every roadmap to place it in the listing indexes
+func roadmapListingAttributes(roadmap *domain.Roadmap) map[string]*dynamodb.AttributeValue {
+	attributes := map[string]*dynamodb.AttributeValue{
+		"listing":   {S: aws.String(listingShard(roadmap.ID))},
+		"sortTitle": {S: aws.String(sortableTitle(roadmap.Title))},
+		"sortLikes": {S: aws.String(sortableNumber(roadmap.Likes))},
+	}
+	if !roadmap.IsCustom && !roadmap.CreatedAt.IsZero() {
+		attributes[newestRoadmapsIndex.attribute] = &dynamodb.AttributeValue{S: aws.String(sortableTime(roadmap.CreatedAt))}
+	}
+	return attributes
+}
+
+// listingPage reads a page of a sorted listing through its index, DynamoDB applying the filter
+// as the index is read. Every shard is read from its own position and the pages are merged on
+// the sort key. Positions hold the key of every shard as "<shard>:<key name>", and mark the
+// shards read to their end as "<shard>:done"
+func listingPage(ctx context.Context, db *dynamodb.DynamoDB, tableName string, index listingIndex, descending bool, condition *conditionBuilder, request pagination.Request) (*rawPage, error) {
+	keyNames := []string{"id", "listing", index.attribute}
+	shards := make([]*rawPage, listingShards)
+	errs := make([]error, listingShards)
+
+	var wg sync.WaitGroup
+	for shard := range shards {
+		if request.After.Key[shardKeyName(shard, "done")] != "" {
+			shards[shard] = &rawPage{}
+			continue
+		}
+
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			input := shardQuery(tableName, index, descending, condition, listingShardValue(shard))
+			shardRequest := pagination.Request{First: request.Limit(), After: shardPosition(request.After, shard)}
+			shards[shard], errs[shard] = queryPage(ctx, db, input, shardRequest, keyNames...)
+		}(shard)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return mergeShards(shards, request, index.attribute, descending), nil
+}
+
+// shardQuery is the query of one shard of a listing. Each shard gets its own copy of the names and
+// values of the condition, as the partition value differs
+func shardQuery(tableName string, index listingIndex, descending bool, condition *conditionBuilder, shard string) *dynamodb.QueryInput {
+	names := make(map[string]*string, len(condition.names)+1)
+	for name, value := range condition.names {
+		names[name] = value
+	}
+	values := make(map[string]*dynamodb.AttributeValue, len(condition.values)+1)
+	for name, value := range condition.values {
+		values[name] = value
+	}
+	names["#listing"] = aws.String("listing")
+	values[":listing"] = &dynamodb.AttributeValue{S: aws.String(shard)}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(tableName),
+		IndexName:                 aws.String(index.name),
+		ScanIndexForward:          aws.Bool(!descending),
+		KeyConditionExpression:    aws.String("#listing = :listing"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if len(condition.clauses) > 0 {
+		input.FilterExpression = aws.String(strings.Join(condition.clauses, " AND "))
+	}
+	return input
+}
+
+func shardKeyName(shard int, name string) string {
+	return fmt.Sprintf("%d:%s", shard, name)
+}
+
+// shardPosition is where the shard is read from in a listing position
+func shardPosition(position pagination.Position, shard int) pagination.Position {
+	prefix := shardKeyName(shard, "")
+	key := make(map[string]string)
+	for name, value := range position.Key {
+		if strings.HasPrefix(name, prefix) && name != shardKeyName(shard, "done") {
+			key[strings.TrimPrefix(name, prefix)] = value
+		}
+	}
+	return pagination.Position{Key: key}
+}
+
+// mergeShards takes the items of the shard pages in sort order. An item is only taken when no
+// shard with items left unread could hold one sorting before it, so pages follow each other in
+// order. Shards whose read stopped early bound the page at their end position
+func mergeShards(shards []*rawPage, request pagination.Request, attribute string, descending bool) *rawPage {
+	limit := request.Limit()
+	state := make(map[string]string, len(request.After.Key))
+	for name, value := range request.After.Key {
+		state[name] = value
+	}
+	next := make([]int, len(shards))
+
+	before := func(a, b map[string]*dynamodb.AttributeValue) bool {
+		sortA, sortB := aws.StringValue(a[attribute].S), aws.StringValue(b[attribute].S)
+		if sortA != sortB {
+			return (sortA < sortB) != descending
+		}
+		return aws.StringValue(a["id"].S) < aws.StringValue(b["id"].S)
+	}
+	// bound is the last item read from a shard that has more to read once its items are taken
+	bound := func(shard int) map[string]*dynamodb.AttributeValue {
+		page := shards[shard]
+		if next[shard] < len(page.items) || !page.hasNextPage {
+			return nil
+		}
+		if !page.endPosition.IsZero() {
+			return exclusiveStartKey(page.endPosition)
+		}
+		return page.items[len(page.items)-1]
+	}
+	advance := func(shard int, position pagination.Position) {
+		for name, value := range position.Key {
+			state[shardKeyName(shard, name)] = value
+		}
+		if next[shard] == len(shards[shard].items) && !shards[shard].hasNextPage {
+			state[shardKeyName(shard, "done")] = "true"
+		}
+	}
+
+	merged := &rawPage{}
+	for len(merged.items) < limit {
+		best := -1
+		for shard, page := range shards {
+			if next[shard] < len(page.items) && (best < 0 || before(page.items[next[shard]], shards[best].items[next[best]])) {
+				best = shard
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		item := shards[best].items[next[best]]
+		blocked := false
+		for shard := range shards {
+			if end := bound(shard); end != nil && before(end, item) {
+				blocked = true
+			}
+		}
+		if blocked {
+			break
+		}
+
+		position := shards[best].positions[next[best]]
+		next[best]++
+		advance(best, position)
+		merged.items = append(merged.items, item)
+		merged.positions = append(merged.positions, pagination.Position{Key: copyKey(state)})
+	}
+
+	// Shards whose items were all taken continue after their end position
+	for shard, page := range shards {
+		if next[shard] == len(page.items) && !page.endPosition.IsZero() {
+			advance(shard, page.endPosition)
+		}
+	}
+	for shard, page := range shards {
+		if next[shard] < len(page.items) || page.hasNextPage {
+			merged.hasNextPage = true
+		} else {
+			state[shardKeyName(shard, "done")] = "true"
+		}
+	}
+	if merged.hasNextPage && (len(merged.positions) == 0 || !sameKey(merged.positions[len(merged.positions)-1].Key, state)) {
+		merged.endPosition = pagination.Position{Key: copyKey(state)}
+	}
+
+	return merged
+}
+
+func copyKey(key map[string]string) map[string]string {
+	copied := make(map[string]string, len(key))
+	for name, value := range key {
+		copied[name] = value
+	}
+	return copied
+}
+
+func sameKey(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if b[name] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"backend/internal/pagination"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// findPage reads a collection in the order of a unique string field, continuing after the value
+// of the position. One document more than the page tells whether there is a next page
+func findPage[T any](ctx context.Context, collection *mongo.Collection, field string, request pagination.Request, name func(T) string) (pagination.Page[T], error) {
+	filter := bson.M{}
+	if after, ok := request.After.Key[field]; ok {
+		filter[field] = bson.M{"$gt": after}
+	}
+
+	limit := request.Limit()
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: field, Value: 1}}).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return pagination.Page[T]{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var items []T
+	if err := cursor.All(ctx, &items); err != nil {
+		return pagination.Page[T]{}, err
+	}
+
+	page := pagination.Page[T]{HasNextPage: len(items) > limit}
+	if page.HasNextPage {
+		items = items[:limit]
+	}
+	page.Items = items
+
+	page.Positions = make([]pagination.Position, len(items))
+	for i, item := range items {
+		page.Positions[i] = pagination.Position{Key: map[string]string{field: name(item)}}
+	}
+	return page, nil
+}
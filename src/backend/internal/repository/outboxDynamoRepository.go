@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DynamoDBOutboxRepository stores messages by id. The status-index global secondary index
+// (status, createdAt) lists pending messages oldest first, and the expiresAt TTL attribute
+// cleans up delivered ones
+type DynamoDBOutboxRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBOutboxRepository(sess *session.Session, tableName string) *DynamoDBOutboxRepository {
+	return &DynamoDBOutboxRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBOutboxRepository) Insert(ctx context.Context, message *domain.OutboxMessage) error {
+	item, err := dynamodbattribute.MarshalMap(message)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// GetPending returns up to limit undelivered messages, oldest first
+func (r *DynamoDBOutboxRepository) GetPending(ctx context.Context, limit int) ([]*domain.OutboxMessage, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("status-index"),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(constants.OutboxPending)},
+		},
+		Limit: aws.Int64(int64(limit)),
+	}
+
+	result, err := r.db.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*domain.OutboxMessage, 0)
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *DynamoDBOutboxRepository) Update(ctx context.Context, message *domain.OutboxMessage) error {
+	item, err := dynamodbattribute.MarshalMap(message)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"backend/internal/pagination"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type rawPage struct {
+	items       []map[string]*dynamodb.AttributeValue
+	positions   []pagination.Position
+	hasNextPage bool
+	endPosition pagination.Position
+}
+
+const (
+	// A filtered read evaluates at most this many items for a page. Reaching it ends the page with
+	// the matches found so far, possibly none, and an end position the next page reads on from
+	maxEvaluatedPerPage = 1000
+	// Items evaluated per request of a filtered read, few of them may match
+	filteredReadBatch = 200
+)
+
+// readPage collects the items of a page through fetch, which reads from the start key with a
+// limit and returns how many items it evaluated. Filters apply after the limit, so reading goes
+// on until one item more than the page is found: that item tells whether there is a next page
+// without handing out an empty one. Filtered reads stop at maxEvaluatedPerPage evaluated items
+func readPage(request pagination.Request, keyNames []string, filtered bool, fetch func(startKey map[string]*dynamodb.AttributeValue, limit int) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, int, error)) (*rawPage, error) {
+	limit := request.Limit()
+	startKey := exclusiveStartKey(request.After)
+
+	var items []map[string]*dynamodb.AttributeValue
+	evaluated := 0
+	var stoppedAt map[string]*dynamodb.AttributeValue
+	for {
+		want := limit + 1 - len(items)
+		if filtered {
+			want = min(max(want, filteredReadBatch), maxEvaluatedPerPage-evaluated)
+		}
+
+		fetched, lastKey, count, err := fetch(startKey, want)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, fetched...)
+		evaluated += count
+
+		if len(lastKey) == 0 || len(items) > limit {
+			break
+		}
+		if filtered && evaluated >= maxEvaluatedPerPage {
+			stoppedAt = lastKey
+			break
+		}
+		startKey = lastKey
+	}
+
+	page := &rawPage{hasNextPage: len(items) > limit}
+	if page.hasNextPage {
+		items = items[:limit]
+	}
+	page.items = items
+
+	page.positions = make([]pagination.Position, len(items))
+	for i, item := range items {
+		position, err := keyPosition(item, keyNames)
+		if err != nil {
+			return nil, err
+		}
+		page.positions[i] = position
+	}
+
+	if stoppedAt != nil {
+		position, err := keyPosition(stoppedAt, keyNames)
+		if err != nil {
+			return nil, err
+		}
+		page.hasNextPage = true
+		page.endPosition = position
+	}
+
+	return page, nil
+}
+
+func scanPage(ctx context.Context, db *dynamodb.DynamoDB, input *dynamodb.ScanInput, request pagination.Request, keyNames ...string) (*rawPage, error) {
+	return readPage(request, keyNames, input.FilterExpression != nil, func(startKey map[string]*dynamodb.AttributeValue, limit int) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, int, error) {
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int64(int64(limit))
+
+		result, err := db.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return result.Items, result.LastEvaluatedKey, int(aws.Int64Value(result.ScannedCount)), nil
+	})
+}
+
+func queryPage(ctx context.Context, db *dynamodb.DynamoDB, input *dynamodb.QueryInput, request pagination.Request, keyNames ...string) (*rawPage, error) {
+	return readPage(request, keyNames, input.FilterExpression != nil, func(startKey map[string]*dynamodb.AttributeValue, limit int) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, int, error) {
+		input.ExclusiveStartKey = startKey
+		input.Limit = aws.Int64(int64(limit))
+
+		result, err := db.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		return result.Items, result.LastEvaluatedKey, int(aws.Int64Value(result.ScannedCount)), nil
+	})
+}
+
+func decodePage[T any](raw *rawPage) (pagination.Page[T], error) {
+	var items []T
+	if err := dynamodbattribute.UnmarshalListOfMaps(raw.items, &items); err != nil {
+		return pagination.Page[T]{}, err
+	}
+
+	return pagination.Page[T]{
+		Items:       items,
+		Positions:   raw.positions,
+		HasNextPage: raw.hasNextPage,
+		EndPosition: raw.endPosition,
+	}, nil
+}
+
+// keyPosition is the position right after an item, read from its key. Only string keys are
+// paged, which all tables read this way have
+func keyPosition(item map[string]*dynamodb.AttributeValue, keyNames []string) (pagination.Position, error) {
+	key := make(map[string]string, len(keyNames))
+	for _, name := range keyNames {
+		value, ok := item[name]
+		if !ok || value.S == nil {
+			return pagination.Position{}, fmt.Errorf("item has no string key attribute %s", name)
+		}
+		key[name] = *value.S
+	}
+	return pagination.Position{Key: key}, nil
+}
+
+func exclusiveStartKey(position pagination.Position) map[string]*dynamodb.AttributeValue {
+	if len(position.Key) == 0 {
+		return nil
+	}
+
+	key := make(map[string]*dynamodb.AttributeValue, len(position.Key))
+	for name, value := range position.Key {
+		key[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+	return key
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// Pages of a topic read at most by one Sample or GetRecent call, questions of other difficulties
+// are filtered out of them
+const (
+	questionQueryPageSize = 100
+	questionQueryMaxPages = 4
+)
+
+// DynamoDBQuestionRepository stores questions keyed by id. The draw-index GSI (partition key
+// topic, sort key drawKey) orders a topic by a random key given on insert, and the
+// topic-created-index GSI (partition key topic, sort key createdAt) by age
+type DynamoDBQuestionRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBQuestionRepository(sess *session.Session, tableName string) *DynamoDBQuestionRepository {
+	return &DynamoDBQuestionRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBQuestionRepository) Insert(ctx context.Context, question *domain.Question) error {
+	question.DrawKey = uuid.NewString()
+
+	item, err := dynamodbattribute.MarshalMap(question)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+func (r *DynamoDBQuestionRepository) GetByID(ctx context.Context, questionID string) (*domain.Question, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(questionID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, errors.New("question not found")
+	}
+
+	var question domain.Question
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &question); err != nil {
+		return nil, err
+	}
+
+	return &question, nil
+}
+
+// Sample returns up to limit questions of the topic and difficulty in draw key order from start,
+// wrapping around to the first key, so a random start draws a random run of questions
+func (r *DynamoDBQuestionRepository) Sample(ctx context.Context, topic, difficulty, start string, limit int) ([]*domain.Question, error) {
+	input := r.topicQuery("draw-index", "drawKey >= :start", topic, difficulty)
+	input.ExpressionAttributeValues[":start"] = &dynamodb.AttributeValue{S: aws.String(start)}
+
+	questions, err := r.queryTopic(ctx, input, limit)
+	if err != nil || len(questions) >= limit {
+		return questions, err
+	}
+
+	wrapped := r.topicQuery("draw-index", "drawKey < :start", topic, difficulty)
+	wrapped.ExpressionAttributeValues[":start"] = &dynamodb.AttributeValue{S: aws.String(start)}
+
+	rest, err := r.queryTopic(ctx, wrapped, limit-len(questions))
+	if err != nil {
+		return nil, err
+	}
+	return append(questions, rest...), nil
+}
+
+// GetRecent returns up to limit of the newest questions of the topic and difficulty, newest first
+func (r *DynamoDBQuestionRepository) GetRecent(ctx context.Context, topic, difficulty string, limit int) ([]*domain.Question, error) {
+	input := r.topicQuery("topic-created-index", "", topic, difficulty)
+	input.ScanIndexForward = aws.Bool(false)
+	return r.queryTopic(ctx, input, limit)
+}
+
+func (r *DynamoDBQuestionRepository) topicQuery(indexName, sortCondition, topic, difficulty string) *dynamodb.QueryInput {
+	keyCondition := "topic = :topic"
+	if sortCondition != "" {
+		keyCondition += " AND " + sortCondition
+	}
+
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(indexName),
+		KeyConditionExpression: aws.String(keyCondition),
+		FilterExpression:       aws.String("difficulty = :difficulty"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":topic":      {S: aws.String(topic)},
+			":difficulty": {S: aws.String(difficulty)},
+		},
+		Limit: aws.Int64(questionQueryPageSize),
+	}
+}
+
+// queryTopic reads pages until it has limit questions, the topic is exhausted or it read
+// questionQueryMaxPages pages
+func (r *DynamoDBQuestionRepository) queryTopic(ctx context.Context, input *dynamodb.QueryInput, limit int) ([]*domain.Question, error) {
+	questions := make([]*domain.Question, 0, limit)
+	if limit <= 0 {
+		return questions, nil
+	}
+
+	pages := 0
+	var unmarshalErr error
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageQuestions []*domain.Question
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageQuestions); unmarshalErr != nil {
+			return false
+		}
+		questions = append(questions, pageQuestions...)
+		pages++
+		return len(questions) < limit && pages < questionQueryMaxPages
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	if len(questions) > limit {
+		questions = questions[:limit]
+	}
+	return questions, nil
+}
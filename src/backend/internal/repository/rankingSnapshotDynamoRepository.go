@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"time"
+)
+
+// DynamoDBRankingSnapshotRepository stores ranking snapshots by id, with an expiresAt TTL attribute
+type DynamoDBRankingSnapshotRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBRankingSnapshotRepository(sess *session.Session, tableName string) *DynamoDBRankingSnapshotRepository {
+	return &DynamoDBRankingSnapshotRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBRankingSnapshotRepository) Insert(ctx context.Context, snapshot *domain.RankingSnapshot) error {
+	item, err := dynamodbattribute.MarshalMap(snapshot)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// Get returns the snapshot, or nil when it expired. The TTL deletes items some time after they
+// expire, so the expiry is checked as well
+func (r *DynamoDBRankingSnapshotRepository) Get(ctx context.Context, snapshotID string) (*domain.RankingSnapshot, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(snapshotID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var snapshot domain.RankingSnapshot
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.ExpiresAt <= time.Now().Unix() {
+		return nil, nil
+	}
+
+	return &snapshot, nil
+}
@@ -2,35 +2,159 @@ package repository
 
 import (
 	"backend/internal/domain"
-	"backend/internal/utils"
+	"backend/internal/pagination"
 	"context"
+	"time"
 )
 
 type IUserRepository interface {
 	UpsertUser(user domain.User) (domain.User, error)
 	GetUsers() []*domain.User
+	ListUsers(ctx context.Context, request pagination.Request) (pagination.Page[*domain.User], error)
 	GetUserByName(name string) (*domain.User, error)
+	// UpdateAchievements saves the XP, level, achievements and achievement counters of the user only
+	UpdateAchievements(ctx context.Context, user *domain.User) error
+	// UpdateTopics saves the topics and topic skills of the user only, provided they still hold
+	// the values the user was read with. False means they changed since and nothing was written
+	UpdateTopics(ctx context.Context, user *domain.User, topics []string, skills map[string]float64) (bool, error)
 }
 
 type ITopicRepository interface {
 	Insert(ctx context.Context, names []*domain.Topic) error
 	GetAllTopics(ctx context.Context) ([]*domain.Topic, error)
+	GetTopics(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Topic], error)
 	GetTopicsByNames(ctx context.Context, names []string) ([]*domain.Topic, error)
+	Get(ctx context.Context, name string) (*domain.Topic, error)
+	Delete(ctx context.Context, name string) error
 	BulkWrite(ctx context.Context, topics []*domain.Topic) error
 }
 
+type ITopicEdgeRepository interface {
+	Add(ctx context.Context, edge *domain.TopicEdge) error
+	Remove(ctx context.Context, topic, kind, itemID string) error
+	GetByTopic(ctx context.Context, topic, kind string, request pagination.Request) (pagination.Page[*domain.TopicEdge], error)
+}
+
+type ITopicAliasRepository interface {
+	Put(ctx context.Context, alias *domain.TopicAlias) error
+	Get(ctx context.Context, alias string) (*domain.TopicAlias, error)
+	Delete(ctx context.Context, alias string) error
+}
+
 type ICourseRepository interface {
-	GetAllCourses(ctx context.Context, pagination utils.Pagination) ([]*domain.Course, *utils.Pagination, error)
+	GetAllCourses(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Course], error)
 	UpsertCourse(ctx context.Context, course *domain.Course) error
 	GetCourseByID(ctx context.Context, courseID string) (*domain.Course, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Course, error)
 	GetBulkByUrl(ctx context.Context, urls []string) ([]*domain.Course, error)
 	BulkInsert(ctx context.Context, courses []*domain.Course) error
+	UpdateLinkStatus(ctx context.Context, courseID string, statusCode int, broken bool, checkedAt time.Time) error
+	GetBrokenCourses(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Course], error)
+	FindCourses(ctx context.Context, filter domain.CourseFilter, order domain.SortOrder, request pagination.Request) (pagination.Page[*domain.Course], error)
+	GetCourseFacets(ctx context.Context, filter domain.CourseFilter) (*domain.CourseFacets, error)
+	GetByTopics(ctx context.Context, topics []string) ([]*domain.Course, error)
+	FindByTopic(ctx context.Context, topic string, request pagination.Request) (pagination.Page[*domain.Course], error)
 }
 
 type IRoadmapRepository interface {
 	GetAllRoadmaps(ctx context.Context) ([]*domain.Roadmap, error)
 	UpsertRoadmap(ctx context.Context, roadmap *domain.Roadmap) error
 	GetRoadmap(ctx context.Context, roadmapID string) (*domain.Roadmap, error)
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Roadmap, error)
 	GetRoadmapsByUser(ctx context.Context, userID string, userRepo IUserRepository) ([]*domain.Roadmap, error)
 	GetByTopic(ctx context.Context, topic string) ([]*domain.Roadmap, error)
+	GetByTopics(ctx context.Context, topics []string) ([]*domain.Roadmap, error)
+	FindByTopic(ctx context.Context, topic string, request pagination.Request) (pagination.Page[*domain.Roadmap], error)
+	FindRoadmaps(ctx context.Context, filter domain.RoadmapFilter, order domain.SortOrder, request pagination.Request) (pagination.Page[*domain.Roadmap], error)
+	FindNewest(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Roadmap], error)
+	GetRoadmapFacets(ctx context.Context, filter domain.RoadmapFilter) (*domain.RoadmapFacets, error)
+}
+
+type IRoadmapSimilarityRepository interface {
+	Upsert(ctx context.Context, similarity *domain.RoadmapSimilarity) error
+	Get(ctx context.Context, roadmapID string) (*domain.RoadmapSimilarity, error)
+}
+
+type IJobCheckpointRepository interface {
+	Get(ctx context.Context, job string) (pagination.Position, error)
+	Save(ctx context.Context, job string, position pagination.Position) error
+}
+
+type IRoadmapActivityRepository interface {
+	Increment(ctx context.Context, activity *domain.RoadmapActivity) error
+	GetDay(ctx context.Context, day string) ([]*domain.RoadmapActivity, error)
+}
+
+type IRoadmapCompletionRepository interface {
+	Record(ctx context.Context, userID, roadmapID string, completedAt time.Time) (bool, error)
+}
+
+type IRankingSnapshotRepository interface {
+	Insert(ctx context.Context, snapshot *domain.RankingSnapshot) error
+	// Get returns nil once the snapshot expired
+	Get(ctx context.Context, snapshotID string) (*domain.RankingSnapshot, error)
+}
+
+type IEventDeliveryRepository interface {
+	Delivered(ctx context.Context, messageID string) (map[string]bool, error)
+	MarkDelivered(ctx context.Context, messageID, handler string) error
+}
+
+type IQuestionRepository interface {
+	Insert(ctx context.Context, question *domain.Question) error
+	GetByID(ctx context.Context, questionID string) (*domain.Question, error)
+	Sample(ctx context.Context, topic, difficulty, start string, limit int) ([]*domain.Question, error)
+	GetRecent(ctx context.Context, topic, difficulty string, limit int) ([]*domain.Question, error)
+}
+
+type IRoadmapViewRepository interface {
+	MarkViewed(ctx context.Context, username, roadmapID, day string) (bool, error)
+}
+
+type ISeenQuestionRepository interface {
+	MarkSeen(ctx context.Context, username, questionID string) error
+	GetSeen(ctx context.Context, username string, questionIDs []string) (map[string]bool, error)
+}
+
+type IChallengeAttemptRepository interface {
+	Insert(ctx context.Context, attempt *domain.ChallengeAttempt) error
+	GetByUser(ctx context.Context, username string, request pagination.Request) (pagination.Page[*domain.ChallengeAttempt], error)
+	GetAllByUser(ctx context.Context, username string) ([]*domain.ChallengeAttempt, error)
+}
+
+type IReviewCardRepository interface {
+	Upsert(ctx context.Context, card *domain.ReviewCard) error
+	Get(ctx context.Context, username, cardID string) (*domain.ReviewCard, error)
+	GetDue(ctx context.Context, username string, before time.Time, request pagination.Request) (pagination.Page[*domain.ReviewCard], error)
+}
+
+type ILeaderboardRepository interface {
+	Increment(ctx context.Context, board, username, displayName string, delta int) error
+	SetMax(ctx context.Context, board, username, displayName string, score int) error
+	GetTop(ctx context.Context, board string, limit int) ([]*domain.LeaderboardEntry, error)
+	GetEntries(ctx context.Context, board string, usernames []string) ([]*domain.LeaderboardEntry, error)
+	RemoveUser(ctx context.Context, username string) error
+}
+
+type IOutboxRepository interface {
+	Insert(ctx context.Context, message *domain.OutboxMessage) error
+	GetPending(ctx context.Context, limit int) ([]*domain.OutboxMessage, error)
+	Update(ctx context.Context, message *domain.OutboxMessage) error
+}
+
+type IWebhookRepository interface {
+	Insert(ctx context.Context, webhook *domain.Webhook) error
+	Get(ctx context.Context, webhookID string) (*domain.Webhook, error)
+	GetByOwner(ctx context.Context, ownerID string) ([]*domain.Webhook, error)
+	GetByEvent(ctx context.Context, event string) ([]*domain.Webhook, error)
+	Delete(ctx context.Context, webhookID string) error
+}
+
+type IWebhookDeliveryRepository interface {
+	Insert(ctx context.Context, delivery *domain.WebhookDelivery) error
+	Get(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error)
+	GetByWebhook(ctx context.Context, webhookID string, request pagination.Request) (pagination.Page[*domain.WebhookDelivery], error)
+	GetPending(ctx context.Context, limit int) ([]*domain.WebhookDelivery, error)
+	Claim(ctx context.Context, delivery *domain.WebhookDelivery, until time.Time) (bool, error)
+	Update(ctx context.Context, delivery *domain.WebhookDelivery) error
 }
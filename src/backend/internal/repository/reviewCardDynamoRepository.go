@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"time"
+)
+
+// DynamoDBReviewCardRepository stores cards with username as partition key and cardId as sort key,
+// plus a due-index local secondary index sorting a user's cards by due date
+type DynamoDBReviewCardRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBReviewCardRepository(sess *session.Session, tableName string) *DynamoDBReviewCardRepository {
+	return &DynamoDBReviewCardRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBReviewCardRepository) Upsert(ctx context.Context, card *domain.ReviewCard) error {
+	card.Due = card.Due.UTC()
+
+	item, err := dynamodbattribute.MarshalMap(card)
+	if err != nil {
+		return err
+	}
+	// The due-index compares dates as strings, which takes a fixed width. Cards saved in
+	// RFC3339Nano before only sort out of place against others due within the same second
+	item["due"] = &dynamodb.AttributeValue{S: aws.String(sortableTime(card.Due))}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// Get returns the card, or nil when the user has no such card
+func (r *DynamoDBReviewCardRepository) Get(ctx context.Context, username, cardID string) (*domain.ReviewCard, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"username": {S: aws.String(username)},
+			"cardId":   {S: aws.String(cardID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var card domain.ReviewCard
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &card); err != nil {
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+// GetDue returns the user's cards due before the given time, the most overdue first
+func (r *DynamoDBReviewCardRepository) GetDue(ctx context.Context, username string, before time.Time, request pagination.Request) (pagination.Page[*domain.ReviewCard], error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("due-index"),
+		KeyConditionExpression: aws.String("username = :username AND due <= :before"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":username": {S: aws.String(username)},
+			":before":   {S: aws.String(sortableTime(before))},
+		},
+	}
+
+	raw, err := queryPage(ctx, r.db, input, request, "username", "cardId", "due")
+	if err != nil {
+		return pagination.Page[*domain.ReviewCard]{}, err
+	}
+	return decodePage[*domain.ReviewCard](raw)
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"time"
+)
+
+// Activity outlives the longest trending window by a month before the expiresAt TTL removes it
+const roadmapActivityRetention = 60 * 24 * time.Hour
+
+// DynamoDBRoadmapActivityRepository stores daily counters with day as partition key and roadmapId
+// as sort key, so a trending window reads one partition per day
+type DynamoDBRoadmapActivityRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBRoadmapActivityRepository(sess *session.Session, tableName string) *DynamoDBRoadmapActivityRepository {
+	return &DynamoDBRoadmapActivityRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+// Increment adds the activity's counters to the roadmap's counters of that day
+func (r *DynamoDBRoadmapActivityRepository) Increment(ctx context.Context, activity *domain.RoadmapActivity) error {
+	day, err := time.Parse(time.DateOnly, activity.Day)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"day":       {S: aws.String(activity.Day)},
+			"roadmapId": {S: aws.String(activity.RoadmapID)},
+		},
+		UpdateExpression: aws.String("ADD likes :likes, #views :views, progress :progress SET expiresAt = :expiresAt"),
+		ExpressionAttributeNames: map[string]*string{
+			"#views": aws.String("views"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":likes":     {N: aws.String(fmt.Sprint(activity.Likes))},
+			":views":     {N: aws.String(fmt.Sprint(activity.Views))},
+			":progress":  {N: aws.String(fmt.Sprint(activity.Progress))},
+			":expiresAt": {N: aws.String(fmt.Sprint(day.Add(roadmapActivityRetention).Unix()))},
+		},
+	}
+
+	_, err = r.db.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// GetDay returns the counters of every roadmap with activity on the day
+func (r *DynamoDBRoadmapActivityRepository) GetDay(ctx context.Context, day string) ([]*domain.RoadmapActivity, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("#day = :day"),
+		ExpressionAttributeNames: map[string]*string{
+			"#day": aws.String("day"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":day": {S: aws.String(day)},
+		},
+	}
+
+	var activity []*domain.RoadmapActivity
+	var unmarshalErr error
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []*domain.RoadmapActivity
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		activity = append(activity, items...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return activity, unmarshalErr
+}
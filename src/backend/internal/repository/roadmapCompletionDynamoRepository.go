@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"time"
+)
+
+// DynamoDBRoadmapCompletionRepository records which roadmaps each user completed, with userId as
+// partition key and roadmapId as sort key. Completions outlive the user's progress, which is
+// dropped when they stop tracking the roadmap
+type DynamoDBRoadmapCompletionRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBRoadmapCompletionRepository(sess *session.Session, tableName string) *DynamoDBRoadmapCompletionRepository {
+	return &DynamoDBRoadmapCompletionRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+// Record saves the completion and reports whether it is the user's first of the roadmap
+func (r *DynamoDBRoadmapCompletionRepository) Record(ctx context.Context, userID, roadmapID string, completedAt time.Time) (bool, error) {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"userId":      {S: aws.String(userID)},
+			"roadmapId":   {S: aws.String(roadmapID)},
+			"completedAt": {S: aws.String(completedAt.UTC().Format(time.RFC3339))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(roadmapId)"),
+	}
+
+	_, err := r.db.PutItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
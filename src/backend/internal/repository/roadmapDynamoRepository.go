@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"backend/internal/constants"
 	"backend/internal/domain"
+	"backend/internal/pagination"
 	"context"
 	"errors"
 	"fmt"
@@ -9,34 +11,71 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"reflect"
+	"strconv"
 )
 
+// DynamoDBRoadmapRepository stores roadmaps by id, with the title-index and likes-index listing
+// GSIs (listing, sortTitle) and (listing, sortLikes) that sorted listings page through, and the
+// newest-index GSI (listing, publishedAt) over the published roadmaps. Writes keep the topic
+// edges and facet counts of the roadmap in step with it
 type DynamoDBRoadmapRepository struct {
 	db        *dynamodb.DynamoDB
-	topicRepo ITopicRepository
+	edges     ITopicEdgeRepository
+	facets    *DynamoDBFacetCountRepository
+	items     topicItemTable
 	tableName string
 }
 
-func NewDynamoDBRoadmapRepository(sess *session.Session, tableName string, topicRepo ITopicRepository) *DynamoDBRoadmapRepository {
+func NewDynamoDBRoadmapRepository(sess *session.Session, tableName string, edges ITopicEdgeRepository, facets *DynamoDBFacetCountRepository) *DynamoDBRoadmapRepository {
+	db := dynamodb.New(sess)
 	return &DynamoDBRoadmapRepository{
-		db:        dynamodb.New(sess),
-		topicRepo: topicRepo,
+		db:     db,
+		edges:  edges,
+		facets: facets,
+		items: topicItemTable{
+			db:              db,
+			tableName:       tableName,
+			kind:            constants.TopicItemRoadmap,
+			edges:           edges,
+			facets:          facets,
+			facetAttributes: []string{"difficulty", "topics", "verified"},
+			facetValues:     roadmapFacetValues,
+		},
 		tableName: tableName,
 	}
 }
 
+// roadmapFacetValues are the facet values a stored roadmap is counted under
+func roadmapFacetValues(item map[string]*dynamodb.AttributeValue) ([]string, error) {
+	var roadmap domain.Roadmap
+	if err := dynamodbattribute.UnmarshalMap(item, &roadmap); err != nil {
+		return nil, err
+	}
+
+	values := []string{facetValue("verified", strconv.FormatBool(roadmap.Verified))}
+	if roadmap.Difficulty != "" {
+		values = append(values, facetValue("difficulty", roadmap.Difficulty))
+	}
+	for _, topic := range roadmap.Topics {
+		values = append(values, facetValue("topics", topic))
+	}
+	return values, nil
+}
+
 func (r *DynamoDBRoadmapRepository) GetAllRoadmaps(ctx context.Context) ([]*domain.Roadmap, error) {
 	input := &dynamodb.ScanInput{
 		TableName: aws.String(r.tableName),
 	}
 
-	result, err := r.db.ScanWithContext(ctx, input)
+	// A single scan stops at 1 MB, read every page
+	items, err := scanAll(ctx, r.db, input)
 	if err != nil {
 		return nil, err
 	}
 
 	var roadmaps []*domain.Roadmap
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &roadmaps)
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &roadmaps)
 	if err != nil {
 		return nil, err
 	}
@@ -44,55 +83,32 @@ func (r *DynamoDBRoadmapRepository) GetAllRoadmaps(ctx context.Context) ([]*doma
 	return roadmaps, nil
 }
 
+// UpsertRoadmap saves the roadmap, with its facet counts, and moves its topic edges to its current
+// topics. Topic names are resolved by the caller, missing topics are not created here
 func (r *DynamoDBRoadmapRepository) UpsertRoadmap(ctx context.Context, roadmap *domain.Roadmap) error {
-	// Log topics
-	fmt.Println("Topics: ", roadmap.Topics)
-
-	// Fetch all topics
-	topics, err := r.topicRepo.GetTopicsByNames(ctx, roadmap.Topics)
-	if err != nil {
-		return err
-	}
-
-	// Add this roadmap to the roadmapIds of each topic if not already present
-	for _, topic := range topics {
-		// Check if the roadmap ID is already in the RoadmapIds list
-		exists := false
-		for _, id := range topic.RoadmapIds {
-			if id == roadmap.ID {
-				exists = true
-				break
-			}
-		}
-		// Append the roadmap ID if it doesn't exist
-		if !exists {
-			topic.RoadmapIds = append(topic.RoadmapIds, roadmap.ID)
-		}
-	}
-	// Update the topics in a separate goroutine
-	errChan := make(chan error)
-	go func() {
-		errChan <- r.topicRepo.BulkWrite(ctx, topics)
-	}()
-
 	item, err := dynamodbattribute.MarshalMap(roadmap)
 	if err != nil {
 		return err
 	}
-
-	input := &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
-	}
-
-	_, err = r.db.PutItemWithContext(ctx, input)
-
-	// Wait for the goroutine to finish and check for errors
-	if err := <-errChan; err != nil {
-		return err
+	for attribute, value := range roadmapListingAttributes(roadmap) {
+		item[attribute] = value
 	}
-
-	return err
+	item[facetsCountedAttribute] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+
+	return r.items.write(ctx, roadmap.ID, func(stored storedTopicState) (*dynamodb.TransactWriteItem, map[string]*dynamodb.AttributeValue, error) {
+		names := make(map[string]*string)
+		values := make(map[string]*dynamodb.AttributeValue)
+		put := &dynamodb.Put{
+			TableName:                aws.String(r.tableName),
+			Item:                     item,
+			ConditionExpression:      aws.String(stored.condition(names, values)),
+			ExpressionAttributeNames: names,
+		}
+		if len(values) > 0 {
+			put.ExpressionAttributeValues = values
+		}
+		return &dynamodb.TransactWriteItem{Put: put}, item, nil
+	})
 }
 
 func (r *DynamoDBRoadmapRepository) GetRoadmap(ctx context.Context, roadmapID string) (*domain.Roadmap, error) {
@@ -125,37 +141,14 @@ func (r *DynamoDBRoadmapRepository) GetRoadmap(ctx context.Context, roadmapID st
 		return &roadmap, nil
 	}
 
-	// Collect all course IDs
-	courseIDs := make(map[string]struct{})
-	for _, courseID := range roadmap.CourseIDs {
-		courseIDs[courseID] = struct{}{}
-	}
-
-	// Prepare keys for BatchGetItem
-	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(courseIDs))
-	for courseID := range courseIDs {
-		keys = append(keys, map[string]*dynamodb.AttributeValue{
-			"id": {S: aws.String(courseID)},
-		})
-	}
-
-	// Perform BatchGetItem
-	batchGetInput := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			"Qriosity-Courses": {
-				Keys: keys,
-			},
-		},
-	}
-
-	batchGetResult, err := r.db.BatchGetItemWithContext(ctx, batchGetInput)
+	items, err := batchGetItems(ctx, r.db, "Qriosity-Courses", idKeys(roadmap.CourseIDs))
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal courses
 	var courses []domain.Course
-	err = dynamodbattribute.UnmarshalListOfMaps(batchGetResult.Responses["Qriosity-Courses"], &courses)
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &courses)
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +159,11 @@ func (r *DynamoDBRoadmapRepository) GetRoadmap(ctx context.Context, roadmapID st
 	return &roadmap, nil
 }
 
+// GetByIDs batch reads the roadmaps in the order of the ids, leaving out deleted ones
+func (r *DynamoDBRoadmapRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.Roadmap, error) {
+	return getByIDs[*domain.Roadmap](ctx, r.db, r.tableName, ids)
+}
+
 func (r *DynamoDBRoadmapRepository) GetRoadmapsByUser(ctx context.Context, userID string, userRepo IUserRepository) ([]*domain.Roadmap, error) {
 	// Fetch the user
 	user, err := userRepo.GetUserByName(userID)
@@ -173,75 +171,187 @@ func (r *DynamoDBRoadmapRepository) GetRoadmapsByUser(ctx context.Context, userI
 		return nil, err
 	}
 
-	// Now fetch all roadmaps using a batch operation from the user.RoadmapIds
-	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(user.Roadmaps))
+	items, err := batchGetItems(ctx, r.db, r.tableName, idKeys(user.Roadmaps))
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal roadmaps
+	var roadmaps []*domain.Roadmap
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &roadmaps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Batch reads come back in any order, keep the order the user liked them in
+	byID := make(map[string]*domain.Roadmap, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		byID[roadmap.ID] = roadmap
+	}
+	ordered := make([]*domain.Roadmap, 0, len(roadmaps))
 	for _, roadmapID := range user.Roadmaps {
-		keys = append(keys, map[string]*dynamodb.AttributeValue{
-			"id": {S: aws.String(roadmapID)},
-		})
+		if roadmap, ok := byID[roadmapID]; ok {
+			ordered = append(ordered, roadmap)
+			delete(byID, roadmapID)
+		}
 	}
 
-	batchGetInput := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			r.tableName: {
-				Keys: keys,
-			},
-		},
+	return ordered, nil
+}
+
+// GetByTopic reads every roadmap tagged with the topic, none for an unknown topic
+func (r *DynamoDBRoadmapRepository) GetByTopic(ctx context.Context, topic string) ([]*domain.Roadmap, error) {
+	var roadmaps []*domain.Roadmap
+
+	request := pagination.Request{First: pagination.MaxFirst}
+	for {
+		page, err := r.FindByTopic(ctx, topic, request)
+		if err != nil {
+			return nil, err
+		}
+		roadmaps = append(roadmaps, page.Items...)
+
+		if !page.HasNextPage {
+			return roadmaps, nil
+		}
+		request.After = page.NextPosition()
 	}
+}
 
-	batchGetResult, err := r.db.BatchGetItemWithContext(ctx, batchGetInput)
+// FindByTopic pages through the roadmaps tagged with the topic
+func (r *DynamoDBRoadmapRepository) FindByTopic(ctx context.Context, topic string, request pagination.Request) (pagination.Page[*domain.Roadmap], error) {
+	edges, err := r.edges.GetByTopic(ctx, topic, constants.TopicItemRoadmap, request)
 	if err != nil {
-		return nil, nil
+		return pagination.Page[*domain.Roadmap]{}, err
 	}
+	return pageByEdges[*domain.Roadmap](ctx, r.db, r.tableName, edges)
+}
 
-	// Unmarshal roadmaps
-	var roadmaps []*domain.Roadmap
-	err = dynamodbattribute.UnmarshalListOfMaps(batchGetResult.Responses[r.tableName], &roadmaps)
+// GetByTopics reads every roadmap tagged with any of the topics, through their edges
+func (r *DynamoDBRoadmapRepository) GetByTopics(ctx context.Context, topics []string) ([]*domain.Roadmap, error) {
+	ids, err := edgeItemIDs(ctx, r.edges, constants.TopicItemRoadmap, topics)
 	if err != nil {
 		return nil, err
 	}
+	return r.GetByIDs(ctx, ids)
+}
 
-	return roadmaps, nil
+func roadmapCondition(filter domain.RoadmapFilter) *conditionBuilder {
+	condition := newConditionBuilder()
+	condition.equals("difficulty", filter.Difficulty)
+	condition.containsAny("topics", filter.Topics)
+	condition.equals("authorId", filter.AuthorID)
+	condition.boolean("verified", filter.Verified)
+	return condition
 }
 
-func (r *DynamoDBRoadmapRepository) GetByTopic(ctx context.Context, topic string) ([]*domain.Roadmap, error) {
-	// Fetch the topic
-	topicObj, err := r.topicRepo.GetTopicsByNames(ctx, []string{topic})
+// FindRoadmaps lists the roadmaps matching the filter, DynamoDB applying it as the items are
+// read. Sorted listings page through the listing index of the field. A page evaluates a bounded
+// number of items, so a selective filter may return a short or empty page with a next cursor.
+// An unsorted filter on one topic alone reads the topic's edges instead
+func (r *DynamoDBRoadmapRepository) FindRoadmaps(ctx context.Context, filter domain.RoadmapFilter, order domain.SortOrder, request pagination.Request) (pagination.Page[*domain.Roadmap], error) {
+	if order.Field == "" && len(filter.Topics) == 1 && reflect.DeepEqual(filter, domain.RoadmapFilter{Topics: filter.Topics}) {
+		return r.FindByTopic(ctx, filter.Topics[0], request)
+	}
+
+	var raw *rawPage
+	var err error
+	if order.Field == "" {
+		input := &dynamodb.ScanInput{
+			TableName: aws.String(r.tableName),
+		}
+		roadmapCondition(filter).apply(input)
+		raw, err = scanPage(ctx, r.db, input, request, "id")
+	} else {
+		index, ok := roadmapListingIndexes[order.Field]
+		if !ok {
+			return pagination.Page[*domain.Roadmap]{}, fmt.Errorf("roadmaps cannot be sorted by %s", order.Field)
+		}
+		raw, err = listingPage(ctx, r.db, r.tableName, index, order.Descending, roadmapCondition(filter), request)
+	}
 	if err != nil {
-		return nil, err
+		return pagination.Page[*domain.Roadmap]{}, err
 	}
+	return decodePage[*domain.Roadmap](raw)
+}
 
-	if len(topicObj) == 0 {
-		return nil, errors.New("topic not found")
+// FindNewest pages through the published roadmaps, newest first. Roadmaps published before
+// creation dates were recorded are left out
+func (r *DynamoDBRoadmapRepository) FindNewest(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Roadmap], error) {
+	raw, err := listingPage(ctx, r.db, r.tableName, newestRoadmapsIndex, true, newConditionBuilder(), request)
+	if err != nil {
+		return pagination.Page[*domain.Roadmap]{}, err
 	}
+	return decodePage[*domain.Roadmap](raw)
+}
 
-	// Fetch all roadmaps using a batch operation from the topic.RoadmapIds
-	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(topicObj[0].RoadmapIds))
-	for _, roadmapID := range topicObj[0].RoadmapIds {
-		keys = append(keys, map[string]*dynamodb.AttributeValue{
-			"id": {S: aws.String(roadmapID)},
-		})
+// roadmapFacetScope is the facet count scope of a filter on at most one value, which facets are
+// kept for on every write. Authors are too many to keep counts for
+func roadmapFacetScope(filter domain.RoadmapFilter) (string, bool) {
+	var values []string
+	if filter.Difficulty != "" {
+		values = append(values, facetValue("difficulty", filter.Difficulty))
 	}
+	if filter.Verified != nil {
+		values = append(values, facetValue("verified", strconv.FormatBool(*filter.Verified)))
+	}
+	for _, topic := range filter.Topics {
+		values = append(values, facetValue("topics", topic))
+	}
+	if len(values) > 1 || filter.AuthorID != "" {
+		return "", false
+	}
+	if len(values) == 0 {
+		return facetScope(constants.TopicItemRoadmap, ""), true
+	}
+	return facetScope(constants.TopicItemRoadmap, values[0]), true
+}
 
-	batchGetInput := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			r.tableName: {
-				Keys: keys,
-			},
-		},
+// GetRoadmapFacets reads the counts kept on write for all roadmaps or a single filter value.
+// Other filters are counted over at most maxFacetEvaluated roadmaps, the facets being partial
+// when more were left
+func (r *DynamoDBRoadmapRepository) GetRoadmapFacets(ctx context.Context, filter domain.RoadmapFilter) (*domain.RoadmapFacets, error) {
+	if scope, ok := roadmapFacetScope(filter); ok {
+		counts, err := r.facets.Get(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+		return &domain.RoadmapFacets{
+			Total:      counts[facetTotal],
+			Difficulty: facetCounts(counts, "difficulty"),
+			Topics:     facetCounts(counts, "topics"),
+			Verified:   facetCounts(counts, "verified"),
+		}, nil
 	}
 
-	batchGetResult, err := r.db.BatchGetItemWithContext(ctx, batchGetInput)
-	if err != nil {
-		return nil, nil
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
 	}
+	condition := roadmapCondition(filter)
+	condition.project(input, "difficulty", "topics", "verified")
+	condition.apply(input)
 
-	// Unmarshal roadmaps
-	var roadmaps []*domain.Roadmap
-	err = dynamodbattribute.UnmarshalListOfMaps(batchGetResult.Responses[r.tableName], &roadmaps)
+	items, partial, err := scanBounded(ctx, r.db, input, maxFacetEvaluated)
 	if err != nil {
 		return nil, err
 	}
 
-	return roadmaps, nil
+	counts := facetCounter{}
+	for _, item := range items {
+		values, err := roadmapFacetValues(item)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			counts.add(value)
+		}
+	}
+
+	return &domain.RoadmapFacets{
+		Total:      len(items),
+		Difficulty: facetCounts(counts, "difficulty"),
+		Topics:     facetCounts(counts, "topics"),
+		Verified:   facetCounts(counts, "verified"),
+		Partial:    partial,
+	}, nil
 }
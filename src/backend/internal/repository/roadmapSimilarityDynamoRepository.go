@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DynamoDBRoadmapSimilarityRepository stores one item per roadmap with roadmapId as partition key,
+// rewritten whole by the recommendations job
+type DynamoDBRoadmapSimilarityRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBRoadmapSimilarityRepository(sess *session.Session, tableName string) *DynamoDBRoadmapSimilarityRepository {
+	return &DynamoDBRoadmapSimilarityRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBRoadmapSimilarityRepository) Upsert(ctx context.Context, similarity *domain.RoadmapSimilarity) error {
+	item, err := dynamodbattribute.MarshalMap(similarity)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// Get returns the roadmap's similar roadmaps, or nil when the job has not seen the roadmap yet
+func (r *DynamoDBRoadmapSimilarityRepository) Get(ctx context.Context, roadmapID string) (*domain.RoadmapSimilarity, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"roadmapId": {S: aws.String(roadmapID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var similarity domain.RoadmapSimilarity
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &similarity); err != nil {
+		return nil, err
+	}
+
+	return &similarity, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"time"
+)
+
+// Views only need to be remembered for the day they count on, the expiresAt TTL drops them after
+const roadmapViewRetention = 48 * time.Hour
+
+// DynamoDBRoadmapViewRepository records which roadmaps each user opened on each day, with
+// username as partition key and view ("roadmapId#day") as sort key, so a view counts once a day
+type DynamoDBRoadmapViewRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBRoadmapViewRepository(sess *session.Session, tableName string) *DynamoDBRoadmapViewRepository {
+	return &DynamoDBRoadmapViewRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+// MarkViewed records the view and reports whether it is the user's first of the roadmap that day
+func (r *DynamoDBRoadmapViewRepository) MarkViewed(ctx context.Context, username, roadmapID, day string) (bool, error) {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"username":  {S: aws.String(username)},
+			"view":      {S: aws.String(roadmapID + "#" + day)},
+			"expiresAt": {N: aws.String(fmt.Sprint(time.Now().Add(roadmapViewRetention).Unix()))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#view)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#view": aws.String("view"),
+		},
+	}
+
+	_, err := r.db.PutItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"time"
+)
+
+// Questions can be served again once the expiresAt TTL removes the record of them being seen
+const seenQuestionRetention = 180 * 24 * time.Hour
+
+// DynamoDBSeenQuestionRepository records the questions served to each user, with username as
+// partition key and questionId as sort key, so the user item does not grow with every challenge
+type DynamoDBSeenQuestionRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBSeenQuestionRepository(sess *session.Session, tableName string) *DynamoDBSeenQuestionRepository {
+	return &DynamoDBSeenQuestionRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBSeenQuestionRepository) MarkSeen(ctx context.Context, username, questionID string) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"username":   {S: aws.String(username)},
+			"questionId": {S: aws.String(questionID)},
+			"expiresAt":  {N: aws.String(fmt.Sprint(time.Now().Add(seenQuestionRetention).Unix()))},
+		},
+	}
+
+	_, err := r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// GetSeen returns which of the questions the user has seen. Expired records the TTL did not
+// remove yet still count as seen
+func (r *DynamoDBSeenQuestionRepository) GetSeen(ctx context.Context, username string, questionIDs []string) (map[string]bool, error) {
+	seen := make(map[string]bool, len(questionIDs))
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(questionIDs))
+	for _, id := range questionIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = false
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			"username":   {S: aws.String(username)},
+			"questionId": {S: aws.String(id)},
+		})
+	}
+	if len(keys) == 0 {
+		return seen, nil
+	}
+
+	items, err := batchGetItems(ctx, r.db, r.tableName, keys)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if id, ok := item["questionId"]; ok && id.S != nil {
+			seen[*id.S] = true
+		}
+	}
+
+	return seen, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DynamoDBTopicAliasRepository stores one item per slug with alias as partition key
+type DynamoDBTopicAliasRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBTopicAliasRepository(sess *session.Session, tableName string) *DynamoDBTopicAliasRepository {
+	return &DynamoDBTopicAliasRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBTopicAliasRepository) Put(ctx context.Context, alias *domain.TopicAlias) error {
+	item, err := dynamodbattribute.MarshalMap(alias)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+// Get returns the alias, or nil when the slug resolves to no topic yet
+func (r *DynamoDBTopicAliasRepository) Get(ctx context.Context, alias string) (*domain.TopicAlias, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"alias": {S: aws.String(alias)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var topicAlias domain.TopicAlias
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &topicAlias); err != nil {
+		return nil, err
+	}
+
+	return &topicAlias, nil
+}
+
+func (r *DynamoDBTopicAliasRepository) Delete(ctx context.Context, alias string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"alias": {S: aws.String(alias)},
+		},
+	}
+
+	_, err := r.db.DeleteItemWithContext(ctx, input)
+	return err
+}
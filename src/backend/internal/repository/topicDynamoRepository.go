@@ -2,6 +2,7 @@ package repository
 
 import (
 	"backend/internal/domain"
+	"backend/internal/pagination"
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
@@ -48,13 +49,14 @@ func (r *DynamoDBTopicRepository) GetAllTopics(ctx context.Context) ([]*domain.T
 		TableName: aws.String(r.tableName),
 	}
 
-	result, err := r.db.ScanWithContext(ctx, input)
+	// A single scan stops at 1 MB, read every page
+	items, err := scanAll(ctx, r.db, input)
 	if err != nil {
 		return nil, err
 	}
 
 	var topics []*domain.Topic
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &topics)
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &topics)
 	if err != nil {
 		return nil, err
 	}
@@ -62,6 +64,19 @@ func (r *DynamoDBTopicRepository) GetAllTopics(ctx context.Context) ([]*domain.T
 	return topics, nil
 }
 
+func (r *DynamoDBTopicRepository) GetTopics(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Topic], error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}
+
+	raw, err := scanPage(ctx, r.db, input, request, "name")
+	if err != nil {
+		return pagination.Page[*domain.Topic]{}, err
+	}
+	return decodePage[*domain.Topic](raw)
+}
+
+// GetTopicsByNames returns the topics that exist among the names, in no particular order
 func (r *DynamoDBTopicRepository) GetTopicsByNames(ctx context.Context, names []string) ([]*domain.Topic, error) {
 	if len(names) == 0 {
 		return nil, fmt.Errorf("names slice is empty")
@@ -76,7 +91,7 @@ func (r *DynamoDBTopicRepository) GetTopicsByNames(ctx context.Context, names []
 		go func(name string) {
 			defer wg.Done()
 			input := &dynamodb.GetItemInput{
-				TableName: aws.String("Qriosity-Topics"),
+				TableName: aws.String(r.tableName),
 				Key: map[string]*dynamodb.AttributeValue{
 					"name": {S: aws.String(name)},
 				},
@@ -89,13 +104,6 @@ func (r *DynamoDBTopicRepository) GetTopicsByNames(ctx context.Context, names []
 			}
 
 			if result.Item == nil {
-				// Create a new topic if not found
-				newTopic := &domain.Topic{
-					Name:       name,
-					RoadmapIds: []string{},
-				}
-
-				topicChan <- newTopic
 				return
 			}
 
@@ -126,6 +134,44 @@ func (r *DynamoDBTopicRepository) GetTopicsByNames(ctx context.Context, names []
 	return topics, nil
 }
 
+// Get returns the topic, or nil when there is no topic by that name
+func (r *DynamoDBTopicRepository) Get(ctx context.Context, name string) (*domain.Topic, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String(name)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var topic domain.Topic
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &topic); err != nil {
+		return nil, err
+	}
+
+	return &topic, nil
+}
+
+func (r *DynamoDBTopicRepository) Delete(ctx context.Context, name string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String(name)},
+		},
+	}
+
+	_, err := r.db.DeleteItemWithContext(ctx, input)
+	return err
+}
+
 func (r *DynamoDBTopicRepository) BulkWrite(ctx context.Context, topics []*domain.Topic) error {
 	// Prepare the write requests
 	writeRequests := make([]*dynamodb.WriteRequest, 0, len(topics))
@@ -141,18 +187,5 @@ func (r *DynamoDBTopicRepository) BulkWrite(ctx context.Context, topics []*domai
 		})
 	}
 
-	fmt.Printf("writeRequests: %v\n", writeRequests)
-
-	// Create the batch write input
-	batchWriteInput := &dynamodb.BatchWriteItemInput{
-		RequestItems: map[string][]*dynamodb.WriteRequest{
-			r.tableName: writeRequests,
-		},
-	}
-
-	fmt.Printf("batchWriteInput: %v\n", batchWriteInput)
-
-	// Execute the batch write operation
-	_, err := r.db.BatchWriteItemWithContext(ctx, batchWriteInput)
-	return err
+	return batchWriteItems(ctx, r.db, r.tableName, writeRequests)
 }
@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"strings"
+	"time"
+)
+
+// DynamoDBTopicEdgeRepository stores one item per topic and tagged roadmap or course, with topic as
+// partition key and item ("kind:id") as sort key. Tags come and go one edge at a time and a topic's
+// items are read a page at a time, so nothing grows with the size of a topic
+type DynamoDBTopicEdgeRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBTopicEdgeRepository(sess *session.Session, tableName string) *DynamoDBTopicEdgeRepository {
+	return &DynamoDBTopicEdgeRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func topicEdgeItem(kind, itemID string) string {
+	return kind + ":" + itemID
+}
+
+// Add tags the item with the topic. Adding an existing edge again keeps the date it was first added
+func (r *DynamoDBTopicEdgeRepository) Add(ctx context.Context, edge *domain.TopicEdge) error {
+	edge.Item = topicEdgeItem(edge.Kind, edge.ItemID)
+	if edge.AddedAt.IsZero() {
+		edge.AddedAt = time.Now().UTC()
+	}
+
+	addedAt, err := dynamodbattribute.Marshal(edge.AddedAt)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"topic": {S: aws.String(edge.Topic)},
+			"item":  {S: aws.String(edge.Item)},
+		},
+		UpdateExpression: aws.String("SET #kind = :kind, itemId = :itemId, addedAt = if_not_exists(addedAt, :addedAt)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#kind": aws.String("kind"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":kind":    {S: aws.String(edge.Kind)},
+			":itemId":  {S: aws.String(edge.ItemID)},
+			":addedAt": addedAt,
+		},
+	}
+
+	_, err = r.db.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+func (r *DynamoDBTopicEdgeRepository) Remove(ctx context.Context, topic, kind, itemID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"topic": {S: aws.String(topic)},
+			"item":  {S: aws.String(topicEdgeItem(kind, itemID))},
+		},
+	}
+
+	_, err := r.db.DeleteItemWithContext(ctx, input)
+	return err
+}
+
+// GetByTopic pages through the topic's edges of one kind, in item key order
+func (r *DynamoDBTopicEdgeRepository) GetByTopic(ctx context.Context, topic, kind string, request pagination.Request) (pagination.Page[*domain.TopicEdge], error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("#topic = :topic AND begins_with(#item, :kind)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#topic": aws.String("topic"),
+			"#item":  aws.String("item"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":topic": {S: aws.String(topic)},
+			":kind":  {S: aws.String(topicEdgeItem(kind, ""))},
+		},
+	}
+
+	raw, err := queryPage(ctx, r.db, input, request, "topic", "item")
+	if err != nil {
+		return pagination.Page[*domain.TopicEdge]{}, err
+	}
+	return decodePage[*domain.TopicEdge](raw)
+}
+
+// syncTopicEdges adds the edge of every topic the item holds and removes those of the topics it lost.
+// Adding is idempotent and every topic is added again, so writing an item again after a sync
+// failed half way restores its missing edges. An edge whose removal failed is left out by
+// pageByEdges, as the item no longer holds its topic
+func syncTopicEdges(ctx context.Context, edges ITopicEdgeRepository, kind, itemID string, previous, current []string) error {
+	kept := make(map[string]bool, len(current))
+	for _, topic := range current {
+		kept[topic] = true
+	}
+	had := make(map[string]bool, len(previous))
+	for _, topic := range previous {
+		had[topic] = true
+	}
+
+	for topic := range kept {
+		if err := edges.Add(ctx, &domain.TopicEdge{Topic: topic, Kind: kind, ItemID: itemID}); err != nil {
+			return err
+		}
+	}
+	for topic := range had {
+		if kept[topic] {
+			continue
+		}
+		if err := edges.Remove(ctx, topic, kind, itemID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A write losing the race with another write of the same item reads it again, this many times at most
+const maxTopicWriteAttempts = 3
+
+// facetsCountedAttribute marks the items counted in the facets of their listing. Items saved
+// before the counts were kept lack it, their next write counts them as new
+const facetsCountedAttribute = "facetsCounted"
+
+// topicItemTable is a table keyed by id whose items are tagged with topics, through edges, and
+// counted in the facets of their listing
+type topicItemTable struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+	// kind of the edges, also the name of the listing the facets count
+	kind   string
+	edges  ITopicEdgeRepository
+	facets *DynamoDBFacetCountRepository
+	// attributes the facet values are read from, topics included
+	facetAttributes []string
+	facetValues     func(item map[string]*dynamodb.AttributeValue) ([]string, error)
+}
+
+// storedTopicState is what an item was saved with, its facet attributes read before writing it
+type storedTopicState struct {
+	exists     bool
+	attributes []string
+	item       map[string]*dynamodb.AttributeValue
+}
+
+// condition requires the item to still hold the facet attributes that were read. Without it two
+// writes racing on one item would both move the edges and counts away from the same previous
+// values, leaving those only the first write saved behind
+func (s storedTopicState) condition(names map[string]*string, values map[string]*dynamodb.AttributeValue) string {
+	// Expressions are refused with names or values they do not use, only those used are added.
+	// A retried write passes the maps of its previous attempt
+	delete(names, "#storedId")
+	delete(values, ":storedNull")
+	for i := range s.attributes {
+		delete(names, fmt.Sprintf("#stored%d", i))
+		delete(values, fmt.Sprintf(":stored%d", i))
+	}
+
+	if !s.exists {
+		names["#storedId"] = aws.String("id")
+		return "attribute_not_exists(#storedId)"
+	}
+
+	clauses := make([]string, len(s.attributes))
+	for i, attribute := range s.attributes {
+		name, value := fmt.Sprintf("#stored%d", i), fmt.Sprintf(":stored%d", i)
+		names[name] = aws.String(attribute)
+		raw, ok := s.item[attribute]
+		switch {
+		case !ok:
+			clauses[i] = "attribute_not_exists(" + name + ")"
+		case raw.NULL != nil:
+			values[":storedNull"] = &dynamodb.AttributeValue{S: aws.String("NULL")}
+			clauses[i] = "attribute_type(" + name + ", :storedNull)"
+		default:
+			values[value] = raw
+			clauses[i] = name + " = " + value
+		}
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// stored reads the facet attributes an item was saved with, and whether it was counted
+func (t topicItemTable) stored(ctx context.Context, id string) (storedTopicState, error) {
+	names := map[string]*string{"#id": aws.String("id")}
+	projection := []string{"#id"}
+	attributes := append([]string{facetsCountedAttribute}, t.facetAttributes...)
+	for i, attribute := range attributes {
+		name := fmt.Sprintf("#a%d", i)
+		names[name] = aws.String(attribute)
+		projection = append(projection, name)
+	}
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(t.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(id)},
+		},
+		ProjectionExpression:     aws.String(strings.Join(projection, ", ")),
+		ExpressionAttributeNames: names,
+		ConsistentRead:           aws.Bool(true),
+	}
+
+	result, err := t.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return storedTopicState{}, err
+	}
+
+	return storedTopicState{exists: result.Item != nil, attributes: attributes, item: result.Item}, nil
+}
+
+// write saves an item on the condition it still holds the facet attributes read before, in one
+// transaction with the facet counts it moves, then moves its edges from the topics it had to
+// those it was written with. build returns the write of the item, with the condition added, and
+// the attributes it is written with, facetsCountedAttribute set among them
+func (t topicItemTable) write(ctx context.Context, id string, build func(stored storedTopicState) (*dynamodb.TransactWriteItem, map[string]*dynamodb.AttributeValue, error)) error {
+	for attempt := 1; ; attempt++ {
+		stored, err := t.stored(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		write, written, err := build(stored)
+		if err != nil {
+			return err
+		}
+
+		var previous []string
+		if counted := stored.item[facetsCountedAttribute]; counted != nil && aws.BoolValue(counted.BOOL) {
+			if previous, err = t.facetValues(stored.item); err != nil {
+				return err
+			}
+		}
+		current, err := t.facetValues(written)
+		if err != nil {
+			return err
+		}
+		counts, err := t.facets.updates(t.kind, previous, current)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.db.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: append([]*dynamodb.TransactWriteItem{write}, counts...),
+		})
+		if conditionFailed(err) && attempt < maxTopicWriteAttempts {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		previousTopics, err := itemTopics(stored.item)
+		if err != nil {
+			return err
+		}
+		currentTopics, err := itemTopics(written)
+		if err != nil {
+			return err
+		}
+		return syncTopicEdges(ctx, t.edges, t.kind, id, previousTopics, currentTopics)
+	}
+}
+
+// conditionFailed reports whether a transaction was cancelled by the condition of its first
+// write, the item's
+func conditionFailed(err error) bool {
+	var cancelled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &cancelled) || len(cancelled.CancellationReasons) == 0 {
+		return false
+	}
+	return aws.StringValue(cancelled.CancellationReasons[0].Code) == "ConditionalCheckFailed"
+}
+
+func itemTopics(item map[string]*dynamodb.AttributeValue) ([]string, error) {
+	var topics []string
+	if raw, ok := item["topics"]; ok {
+		if err := dynamodbattribute.Unmarshal(raw, &topics); err != nil {
+			return nil, err
+		}
+	}
+	return topics, nil
+}
+
+// edgeItemIDs reads the ids of the items of one kind tagged with any of the topics, each once
+func edgeItemIDs(ctx context.Context, edges ITopicEdgeRepository, kind string, topics []string) ([]string, error) {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, topic := range uniqueValues(topics) {
+		request := pagination.Request{First: pagination.MaxFirst}
+		for {
+			page, err := edges.GetByTopic(ctx, topic, kind, request)
+			if err != nil {
+				return nil, err
+			}
+			for _, edge := range page.Items {
+				if !seen[edge.ItemID] {
+					seen[edge.ItemID] = true
+					ids = append(ids, edge.ItemID)
+				}
+			}
+
+			if !page.HasNextPage {
+				break
+			}
+			request.After = page.NextPosition()
+		}
+	}
+	return ids, nil
+}
+
+// holdsTopic reports whether an item's topics list the topic
+func holdsTopic(item map[string]*dynamodb.AttributeValue, topic string) bool {
+	topics, ok := item["topics"]
+	if !ok {
+		return false
+	}
+	for _, value := range topics.L {
+		if value.S != nil && *value.S == topic {
+			return true
+		}
+	}
+	for _, value := range topics.SS {
+		if value != nil && *value == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// itemsByID batch reads the items of a table keyed by id, keyed by their id
+func itemsByID(ctx context.Context, db *dynamodb.DynamoDB, tableName string, ids []string) (map[string]map[string]*dynamodb.AttributeValue, error) {
+	items := make(map[string]map[string]*dynamodb.AttributeValue, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	result, err := batchGetItems(ctx, db, tableName, idKeys(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range result {
+		if id, ok := item["id"]; ok && id.S != nil {
+			items[*id.S] = item
+		}
+	}
+
+	return items, nil
+}
+
+// pageByEdges turns a page of edges into the page of their items, in the same order. Items
+// deleted since they were tagged, or no longer holding the edge's topic, are left out
+func pageByEdges[T any](ctx context.Context, db *dynamodb.DynamoDB, tableName string, edges pagination.Page[*domain.TopicEdge]) (pagination.Page[T], error) {
+	ids := make([]string, len(edges.Items))
+	for i, edge := range edges.Items {
+		ids[i] = edge.ItemID
+	}
+
+	items, err := itemsByID(ctx, db, tableName, ids)
+	if err != nil {
+		return pagination.Page[T]{}, err
+	}
+
+	raw := &rawPage{hasNextPage: edges.HasNextPage}
+	for i, id := range ids {
+		if item, ok := items[id]; ok && holdsTopic(item, edges.Items[i].Topic) {
+			raw.items = append(raw.items, item)
+			raw.positions = append(raw.positions, edges.Positions[i])
+		}
+	}
+	if edges.HasNextPage && len(raw.items) < len(ids) {
+		// The next page starts after the last edge even when its item was left out
+		raw.endPosition = edges.NextPosition()
+	}
+	return decodePage[T](raw)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"backend/internal/domain"
+	"backend/internal/pagination"
 	"context"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -52,3 +53,7 @@ func (r *TopicMongoDBRepository) Insert(ctx context.Context, topics []*domain.To
 	_, err := r.collection.InsertMany(ctx, documents)
 	return err
 }
+
+func (r *TopicMongoDBRepository) GetTopics(ctx context.Context, request pagination.Request) (pagination.Page[*domain.Topic], error) {
+	return findPage(ctx, r.collection, "name", request, func(topic *domain.Topic) string { return topic.Name })
+}
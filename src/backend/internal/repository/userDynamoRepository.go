@@ -2,8 +2,11 @@ package repository
 
 import (
 	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
@@ -46,14 +49,15 @@ func (r *DynamoDBUserRepository) GetUsers() []*domain.User {
 		TableName: aws.String(r.tableName),
 	}
 
-	result, err := r.client.Scan(input)
+	// A single scan stops at 1 MB, read every page
+	items, err := scanAll(context.Background(), r.client, input)
 	if err != nil {
 		fmt.Println("Error getting users:", err)
 		return nil
 	}
 
 	var users []*domain.User
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &users)
+	err = dynamodbattribute.UnmarshalListOfMaps(items, &users)
 	if err != nil {
 		fmt.Println("Error unmarshalling users:", err)
 		return nil
@@ -62,6 +66,18 @@ func (r *DynamoDBUserRepository) GetUsers() []*domain.User {
 	return users
 }
 
+func (r *DynamoDBUserRepository) ListUsers(ctx context.Context, request pagination.Request) (pagination.Page[*domain.User], error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}
+
+	raw, err := scanPage(ctx, r.client, input, request, "name")
+	if err != nil {
+		return pagination.Page[*domain.User]{}, err
+	}
+	return decodePage[*domain.User](raw)
+}
+
 func (r *DynamoDBUserRepository) GetUserByName(name string) (*domain.User, error) {
 	input := &dynamodb.QueryInput{
 		TableName: aws.String(r.tableName),
@@ -94,3 +110,97 @@ func (r *DynamoDBUserRepository) GetUserByName(name string) (*domain.User, error
 
 	return &user, nil
 }
+
+// UpdateAchievements writes the achievement fields of the user and leaves the rest of the item alone
+func (r *DynamoDBUserRepository) UpdateAchievements(ctx context.Context, user *domain.User) error {
+	achievements, err := dynamodbattribute.Marshal(user.Achievements)
+	if err != nil {
+		return err
+	}
+	counters, err := dynamodbattribute.Marshal(user.AchievementCounters)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String(user.Name)},
+		},
+		UpdateExpression:    aws.String("SET xp = :xp, #level = :level, achievements = :achievements, achievementCounters = :counters"),
+		ConditionExpression: aws.String("attribute_exists(#name)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#level": aws.String("level"),
+			"#name":  aws.String("name"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":xp":           {N: aws.String(fmt.Sprint(user.XP))},
+			":level":        {N: aws.String(fmt.Sprint(user.Level))},
+			":achievements": achievements,
+			":counters":     counters,
+		},
+	}
+
+	_, err = r.client.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// UpdateTopics writes the topics and topic skills of the user, conditioned on the values user
+// holds. Empty values match an absent, null or empty attribute, whichever the user was saved with
+func (r *DynamoDBUserRepository) UpdateTopics(ctx context.Context, user *domain.User, topics []string, skills map[string]float64) (bool, error) {
+	topicsValue, err := dynamodbattribute.Marshal(topics)
+	if err != nil {
+		return false, err
+	}
+	skillsValue, err := dynamodbattribute.Marshal(skills)
+	if err != nil {
+		return false, err
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":topics": topicsValue,
+		":skills": skillsValue,
+	}
+
+	unchanged := func(attribute, placeholder string, previous interface{}, empty bool) (string, error) {
+		if empty {
+			values[":null"] = &dynamodb.AttributeValue{S: aws.String("NULL")}
+			values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+			return fmt.Sprintf("(attribute_not_exists(%[1]s) OR attribute_type(%[1]s, :null) OR size(%[1]s) = :zero)", attribute), nil
+		}
+		value, err := dynamodbattribute.Marshal(previous)
+		if err != nil {
+			return "", err
+		}
+		values[placeholder] = value
+		return attribute + " = " + placeholder, nil
+	}
+	topicsCondition, err := unchanged("topics", ":previousTopics", user.Topics, len(user.Topics) == 0)
+	if err != nil {
+		return false, err
+	}
+	skillsCondition, err := unchanged("topicSkills", ":previousSkills", user.TopicSkills, len(user.TopicSkills) == 0)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String(user.Name)},
+		},
+		UpdateExpression:    aws.String("SET topics = :topics, topicSkills = :skills"),
+		ConditionExpression: aws.String("attribute_exists(#name) AND " + topicsCondition + " AND " + skillsCondition),
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+		ExpressionAttributeValues: values,
+	}
+
+	if _, err := r.client.UpdateItemWithContext(ctx, input); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"backend/internal/domain"
+	"backend/internal/pagination"
 	"context"
 	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
@@ -83,6 +84,10 @@ func (r *MongoDBUserRepository) GetUsers() []*domain.User {
 	return users
 }
 
+func (r *MongoDBUserRepository) ListUsers(ctx context.Context, request pagination.Request) (pagination.Page[*domain.User], error) {
+	return findPage(ctx, r.collection, "name", request, func(user *domain.User) string { return user.Name })
+}
+
 func (r *MongoDBUserRepository) GetUserByID(id string) (*domain.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -118,3 +123,17 @@ func (r *MongoDBUserRepository) GetUserByName(name string) (*domain.User, error)
 
 	return &user, nil
 }
+
+func (r *MongoDBUserRepository) UpdateAchievements(ctx context.Context, user *domain.User) error {
+	update := bson.M{
+		"$set": bson.M{
+			"xp":                  user.XP,
+			"level":               user.Level,
+			"achievements":        user.Achievements,
+			"achievementcounters": user.AchievementCounters,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"name": user.Name}, update)
+	return err
+}
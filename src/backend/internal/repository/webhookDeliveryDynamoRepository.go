@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"strconv"
+	"time"
+)
+
+// DynamoDBWebhookDeliveryRepository stores deliveries with webhookId as partition key and id as
+// sort key, plus a createdAt-index local secondary index listing a webhook's deliveries by date
+// and a status-index global secondary index (status, createdAt) listing pending and claimed deliveries
+// oldest first
+type DynamoDBWebhookDeliveryRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBWebhookDeliveryRepository(sess *session.Session, tableName string) *DynamoDBWebhookDeliveryRepository {
+	return &DynamoDBWebhookDeliveryRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBWebhookDeliveryRepository) Insert(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	// Dates are compared as strings by the createdAt-index, keep them all in UTC
+	delivery.CreatedAt = delivery.CreatedAt.UTC()
+
+	item, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+func (r *DynamoDBWebhookDeliveryRepository) Get(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"webhookId": {S: aws.String(webhookID)},
+			"id":        {S: aws.String(deliveryID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, errors.New("webhook delivery not found")
+	}
+
+	var delivery domain.WebhookDelivery
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &delivery); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// GetByWebhook returns the latest deliveries of the webhook, newest first
+func (r *DynamoDBWebhookDeliveryRepository) GetByWebhook(ctx context.Context, webhookID string, request pagination.Request) (pagination.Page[*domain.WebhookDelivery], error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("createdAt-index"),
+		KeyConditionExpression: aws.String("webhookId = :webhookId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":webhookId": {S: aws.String(webhookID)},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	raw, err := queryPage(ctx, r.db, input, request, "webhookId", "id", "createdAt")
+	if err != nil {
+		return pagination.Page[*domain.WebhookDelivery]{}, err
+	}
+	return decodePage[*domain.WebhookDelivery](raw)
+}
+
+// GetPending returns up to limit deliveries waiting to be sent, oldest first, followed by those
+// whose claim expired because the run sending them stopped before saving the outcome
+func (r *DynamoDBWebhookDeliveryRepository) GetPending(ctx context.Context, limit int) ([]*domain.WebhookDelivery, error) {
+	deliveries, err := r.getByStatus(ctx, constants.WebhookDeliveryPending, time.Time{}, limit)
+	if err != nil || len(deliveries) >= limit {
+		return deliveries, err
+	}
+
+	expired, err := r.getByStatus(ctx, constants.WebhookDeliverySending, time.Now(), limit-len(deliveries))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(deliveries, expired...), nil
+}
+
+// getByStatus reads up to limit deliveries with the status, oldest first. With a non-zero
+// claimedBefore, only those whose claim expires before it are kept
+func (r *DynamoDBWebhookDeliveryRepository) getByStatus(ctx context.Context, status string, claimedBefore time.Time, limit int) ([]*domain.WebhookDelivery, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("status-index"),
+		KeyConditionExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+		},
+		Limit: aws.Int64(int64(limit)),
+	}
+	if !claimedBefore.IsZero() {
+		input.FilterExpression = aws.String("claimExpiresAt < :before")
+		input.ExpressionAttributeValues[":before"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(claimedBefore.Unix(), 10))}
+	}
+
+	deliveries := make([]*domain.WebhookDelivery, 0)
+	var decodeErr error
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []*domain.WebhookDelivery
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); decodeErr != nil {
+			return false
+		}
+		deliveries = append(deliveries, items...)
+		// Claimed deliveries are few, the filtered partition is read on until enough expired
+		return len(deliveries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return deliveries[:min(len(deliveries), limit)], nil
+}
+
+// Claim marks a pending delivery, or one whose claim expired, as sent by the caller until the given
+// time. It reports false when another run claimed it first
+func (r *DynamoDBWebhookDeliveryRepository) Claim(ctx context.Context, delivery *domain.WebhookDelivery, until time.Time) (bool, error) {
+	now := time.Now()
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"webhookId": {S: aws.String(delivery.WebhookID)},
+			"id":        {S: aws.String(delivery.ID)},
+		},
+		UpdateExpression:    aws.String("SET #status = :sending, claimExpiresAt = :until"),
+		ConditionExpression: aws.String("#status = :pending OR (#status = :sending AND claimExpiresAt < :now)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pending": {S: aws.String(constants.WebhookDeliveryPending)},
+			":sending": {S: aws.String(constants.WebhookDeliverySending)},
+			":until":   {N: aws.String(strconv.FormatInt(until.Unix(), 10))},
+			":now":     {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	}
+
+	_, err := r.db.UpdateItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	delivery.Status = constants.WebhookDeliverySending
+	delivery.ClaimExpiresAt = until.Unix()
+	return true, nil
+}
+
+// Update saves the outcome of a delivery attempt and releases the claim. It fails when the claim
+// is no longer the one the delivery was sent under, as another run has taken the delivery over
+func (r *DynamoDBWebhookDeliveryRepository) Update(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	claim := delivery.ClaimExpiresAt
+	delivery.ClaimExpiresAt = 0
+	delivery.CreatedAt = delivery.CreatedAt.UTC()
+
+	item, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("#status = :sending AND claimExpiresAt = :claim"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sending": {S: aws.String(constants.WebhookDeliverySending)},
+			":claim":   {N: aws.String(strconv.FormatInt(claim, 10))},
+		},
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return fmt.Errorf("webhook delivery %s was claimed by another run", delivery.ID)
+	}
+	return err
+}
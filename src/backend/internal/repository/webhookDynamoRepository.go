@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"backend/internal/domain"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// DynamoDBWebhookRepository stores webhooks by id, with an owner-index global secondary index
+// on ownerId. There are few webhooks, so finding the ones subscribed to an event is a scan
+type DynamoDBWebhookRepository struct {
+	db        *dynamodb.DynamoDB
+	tableName string
+}
+
+func NewDynamoDBWebhookRepository(sess *session.Session, tableName string) *DynamoDBWebhookRepository {
+	return &DynamoDBWebhookRepository{
+		db:        dynamodb.New(sess),
+		tableName: tableName,
+	}
+}
+
+func (r *DynamoDBWebhookRepository) Insert(ctx context.Context, webhook *domain.Webhook) error {
+	item, err := dynamodbattribute.MarshalMap(webhook)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	}
+
+	_, err = r.db.PutItemWithContext(ctx, input)
+	return err
+}
+
+func (r *DynamoDBWebhookRepository) Get(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(webhookID)},
+		},
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, errors.New("webhook not found")
+	}
+
+	var webhook domain.Webhook
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &webhook); err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *DynamoDBWebhookRepository) GetByOwner(ctx context.Context, ownerID string) ([]*domain.Webhook, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("owner-index"),
+		KeyConditionExpression: aws.String("ownerId = :ownerId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ownerId": {S: aws.String(ownerID)},
+		},
+	}
+
+	webhooks := make([]*domain.Webhook, 0)
+	var unmarshalErr error
+	err := r.db.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageWebhooks []*domain.Webhook
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageWebhooks); unmarshalErr != nil {
+			return false
+		}
+		webhooks = append(webhooks, pageWebhooks...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return webhooks, nil
+}
+
+func (r *DynamoDBWebhookRepository) GetByEvent(ctx context.Context, event string) ([]*domain.Webhook, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(r.tableName),
+		FilterExpression: aws.String("contains(events, :event)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":event": {S: aws.String(event)},
+		},
+	}
+
+	webhooks := make([]*domain.Webhook, 0)
+	var unmarshalErr error
+	err := r.db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageWebhooks []*domain.Webhook
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageWebhooks); unmarshalErr != nil {
+			return false
+		}
+		webhooks = append(webhooks, pageWebhooks...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return webhooks, nil
+}
+
+func (r *DynamoDBWebhookRepository) Delete(ctx context.Context, webhookID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(webhookID)},
+		},
+	}
+
+	_, err := r.db.DeleteItemWithContext(ctx, input)
+	return err
+}
@@ -0,0 +1,132 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a URL points at a loopback, private, link-local or
+// otherwise non-public address, such as the instance metadata or the Lambda runtime API
+var ErrForbiddenAddress = errors.New("address is not public")
+
+const maxRedirects = 10
+
+// Ranges that are neither private nor loopback per net/netip but still never reach the internet
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can embed any IPv4 address
+}
+
+// AllowPrivate reports whether ALLOW_PRIVATE_URLS is set to true, which lets clients reach
+// localhost and private addresses. It is meant for development against local servers only
+func AllowPrivate() bool {
+	return os.Getenv("ALLOW_PRIVATE_URLS") == "true"
+}
+
+// NewClient returns a client for URLs supplied by users or models. Every connection, redirects
+// included, is checked once the host is resolved, so a public name resolving to a private
+// address is refused as well
+func NewClient(timeout time.Duration) *http.Client {
+	allowPrivate := AllowPrivate()
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+			return checkAddress(address)
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// A proxy would be dialled instead of the target, bypassing the address check
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			ForceAttemptHTTP2:      true,
+			TLSHandshakeTimeout:    5 * time.Second,
+			MaxResponseHeaderBytes: 64 << 10,
+			IdleConnTimeout:        30 * time.Second,
+		},
+		CheckRedirect: checkRedirect,
+	}
+}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %s", req.URL.Scheme)
+	}
+	return nil
+}
+
+// CheckHost resolves the host and fails unless every address it resolves to is public. It gives
+// early feedback when a URL is registered, the client still checks every connection it makes
+func CheckHost(ctx context.Context, host string) error {
+	if AllowPrivate() {
+		return nil
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return checkIP(ip, host)
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := checkIP(ip, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsPublic reports whether the address is routable on the internet
+func IsPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	return checkIP(ip, host)
+}
+
+func checkIP(ip netip.Addr, host string) error {
+	if !IsPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package search
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultIndexName = "qriosity"
+
+// NewIndexFromEnv connects to the cluster at SEARCH_ENDPOINT, signing with the session's
+// credentials unless SEARCH_USERNAME is set. Local runs without an endpoint get a memory index.
+// Deployed lambdas must have an endpoint: every instance would hold its own memory index,
+// filled from a full scan and blind to writes made by the other instances
+func NewIndexFromEnv(sess *session.Session) (Index, error) {
+	endpoint := os.Getenv("SEARCH_ENDPOINT")
+	if endpoint == "" {
+		if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+			return nil, errors.New("SEARCH_ENDPOINT is required, the memory index is for local runs only")
+		}
+		return NewMemoryIndex(), nil
+	}
+
+	name := os.Getenv("SEARCH_INDEX")
+	if name == "" {
+		name = defaultIndexName
+	}
+
+	index := NewOpenSearchIndex(endpoint, name, &http.Client{Timeout: 5 * time.Second})
+	if username := os.Getenv("SEARCH_USERNAME"); username != "" {
+		return index.WithBasicAuth(username, os.Getenv("SEARCH_PASSWORD")), nil
+	}
+	return index.WithSigner(v4.NewSigner(sess.Config.Credentials), aws.StringValue(sess.Config.Region)), nil
+}
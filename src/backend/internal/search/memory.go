@@ -0,0 +1,205 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// BM25 parameters, the usual defaults
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Fields are searched together, a match in the title weighs more than one in the description
+const (
+	fieldTitle = iota
+	fieldTopics
+	fieldAuthor
+	fieldDescription
+	fieldCount
+)
+
+var fieldBoosts = [fieldCount]float64{
+	fieldTitle:       3,
+	fieldTopics:      2,
+	fieldAuthor:      1.5,
+	fieldDescription: 1,
+}
+
+type indexedDocument struct {
+	doc     Document
+	terms   [fieldCount]map[string]int
+	lengths [fieldCount]int
+}
+
+// MemoryIndex ranks with BM25F over an in-process inverted index. It only lives as long as the
+// process, the search service fills it from the tables on first use
+type MemoryIndex struct {
+	mu          sync.RWMutex
+	docs        map[string]*indexedDocument
+	postings    map[string]map[string]struct{}
+	totalLength [fieldCount]int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[string]*indexedDocument),
+		postings: make(map[string]map[string]struct{}),
+	}
+}
+
+func documentKey(kind, id string) string {
+	return kind + ":" + id
+}
+
+func (m *MemoryIndex) Upsert(_ context.Context, doc Document) error {
+	indexed := &indexedDocument{doc: doc}
+	texts := [fieldCount]string{
+		fieldTitle:       doc.Title,
+		fieldTopics:      strings.Join(doc.Topics, " "),
+		fieldAuthor:      doc.Author,
+		fieldDescription: doc.Description,
+	}
+	for field, text := range texts {
+		terms := Tokenize(text)
+		indexed.terms[field] = make(map[string]int, len(terms))
+		for _, term := range terms {
+			indexed.terms[field][term]++
+		}
+		indexed.lengths[field] = len(terms)
+	}
+
+	key := documentKey(doc.Kind, doc.ID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+	m.docs[key] = indexed
+	for field := 0; field < fieldCount; field++ {
+		m.totalLength[field] += indexed.lengths[field]
+		for term := range indexed.terms[field] {
+			if m.postings[term] == nil {
+				m.postings[term] = make(map[string]struct{})
+			}
+			m.postings[term][key] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (m *MemoryIndex) Delete(_ context.Context, kind, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(documentKey(kind, id))
+	return nil
+}
+
+// remove drops a document from the postings, the caller holds the write lock
+func (m *MemoryIndex) remove(key string) {
+	indexed, ok := m.docs[key]
+	if !ok {
+		return
+	}
+
+	for field := 0; field < fieldCount; field++ {
+		m.totalLength[field] -= indexed.lengths[field]
+		for term := range indexed.terms[field] {
+			delete(m.postings[term], key)
+			if len(m.postings[term]) == 0 {
+				delete(m.postings, term)
+			}
+		}
+	}
+	delete(m.docs, key)
+}
+
+func (m *MemoryIndex) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.docs)
+}
+
+func (m *MemoryIndex) Search(_ context.Context, query Query) ([]Hit, error) {
+	query = query.Normalize()
+	terms := Tokenize(query.Text)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	total := float64(len(m.docs))
+	var averageLength [fieldCount]float64
+	for field := 0; field < fieldCount; field++ {
+		if total > 0 {
+			averageLength[field] = float64(m.totalLength[field]) / total
+		}
+	}
+
+	// Without terms every document passing the filters is a hit, they all score the same
+	scores := make(map[string]float64)
+	if len(terms) == 0 {
+		for key, indexed := range m.docs {
+			if query.Matches(indexed.doc) {
+				scores[key] = 0
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := m.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		frequency := float64(len(postings))
+		idf := math.Log(1 + (total-frequency+0.5)/(frequency+0.5))
+
+		for key := range postings {
+			indexed := m.docs[key]
+			if !query.Matches(indexed.doc) {
+				continue
+			}
+
+			// BM25F: the weighted, length normalized frequency of every field is saturated once
+			weighted := 0.0
+			for field := 0; field < fieldCount; field++ {
+				count := indexed.terms[field][term]
+				if count == 0 || averageLength[field] == 0 {
+					continue
+				}
+				normalization := 1 - bm25B + bm25B*float64(indexed.lengths[field])/averageLength[field]
+				weighted += fieldBoosts[field] * float64(count) / normalization
+			}
+			scores[key] += idf * weighted / (bm25K1 + weighted)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for key, score := range scores {
+		doc := m.docs[key].doc
+		hits = append(hits, Hit{ID: doc.ID, Kind: doc.Kind, Score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return documentKey(hits[i].Kind, hits[i].ID) < documentKey(hits[j].Kind, hits[j].ID)
+	})
+
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
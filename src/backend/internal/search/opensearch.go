@@ -0,0 +1,240 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// indexSettings maps the filter fields as lowercase keywords next to the analyzed text
+const indexSettings = `{
+	"settings": {
+		"analysis": {
+			"normalizer": {
+				"lowercase": {"type": "custom", "filter": ["lowercase"]}
+			}
+		}
+	},
+	"mappings": {
+		"properties": {
+			"id": {"type": "keyword"},
+			"kind": {"type": "keyword"},
+			"title": {"type": "text", "analyzer": "english"},
+			"description": {"type": "text", "analyzer": "english"},
+			"author": {"type": "text"},
+			"topics": {
+				"type": "text",
+				"analyzer": "english",
+				"fields": {"raw": {"type": "keyword", "normalizer": "lowercase"}}
+			},
+			"difficulty": {"type": "keyword", "normalizer": "lowercase"}
+		}
+	}
+}`
+
+// HTTPDoer is the subset of *http.Client used to reach the cluster
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// OpenSearchIndex stores documents in an OpenSearch (or Elasticsearch) index. Requests are
+// signed for Amazon OpenSearch Service when a signer is set, sent with basic auth otherwise
+type OpenSearchIndex struct {
+	endpoint string
+	index    string
+	http     HTTPDoer
+
+	signer   *v4.Signer
+	region   string
+	username string
+	password string
+}
+
+func NewOpenSearchIndex(endpoint, index string, doer HTTPDoer) *OpenSearchIndex {
+	return &OpenSearchIndex{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		index:    index,
+		http:     doer,
+	}
+}
+
+// WithSigner signs every request with SigV4 for the "es" service of the region
+func (o *OpenSearchIndex) WithSigner(signer *v4.Signer, region string) *OpenSearchIndex {
+	o.signer = signer
+	o.region = region
+	return o
+}
+
+func (o *OpenSearchIndex) WithBasicAuth(username, password string) *OpenSearchIndex {
+	o.username = username
+	o.password = password
+	return o
+}
+
+// EnsureIndex creates the index with its mappings, an index that already exists is left as is
+func (o *OpenSearchIndex) EnsureIndex(ctx context.Context) error {
+	status, body, err := o.do(ctx, http.MethodPut, "/"+o.index, []byte(indexSettings))
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && bytes.Contains(body, []byte("resource_already_exists_exception")) {
+		return nil
+	}
+	return checkStatus(status, body)
+}
+
+func (o *OpenSearchIndex) Upsert(ctx context.Context, doc Document) error {
+	payload, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	status, body, err := o.do(ctx, http.MethodPut, o.documentPath(doc.Kind, doc.ID), payload)
+	if err != nil {
+		return err
+	}
+	return checkStatus(status, body)
+}
+
+func (o *OpenSearchIndex) Delete(ctx context.Context, kind, id string) error {
+	status, body, err := o.do(ctx, http.MethodDelete, o.documentPath(kind, id), nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return checkStatus(status, body)
+}
+
+func (o *OpenSearchIndex) Search(ctx context.Context, query Query) ([]Hit, error) {
+	query = query.Normalize()
+
+	filters := make([]map[string]interface{}, 0, 3)
+	if len(query.Kinds) > 0 {
+		filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{"kind": query.Kinds}})
+	}
+	if len(query.Topics) > 0 {
+		filters = append(filters, map[string]interface{}{"terms": map[string]interface{}{"topics.raw": query.Topics}})
+	}
+	if query.Difficulty != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"difficulty": query.Difficulty}})
+	}
+
+	must := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if query.Text != "" {
+		must = map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     query.Text,
+				"fields":    []string{"title^3", "topics^2", "author^1.5", "description"},
+				"fuzziness": "AUTO",
+			},
+		}
+	}
+
+	request := map[string]interface{}{
+		"size":    query.Limit,
+		"_source": []string{"id", "kind"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filters,
+			},
+		},
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	status, body, err := o.do(ctx, http.MethodPost, "/"+o.index+"/_search", payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(status, body); err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Score  float64 `json:"_score"`
+				Source struct {
+					ID   string `json:"id"`
+					Kind string `json:"kind"`
+				} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		hits = append(hits, Hit{ID: hit.Source.ID, Kind: hit.Source.Kind, Score: hit.Score})
+	}
+	return hits, nil
+}
+
+func (o *OpenSearchIndex) documentPath(kind, id string) string {
+	return "/" + o.index + "/_doc/" + url.PathEscape(documentKey(kind, id))
+}
+
+func (o *OpenSearchIndex) do(ctx context.Context, method, path string, payload []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, o.endpoint+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var body io.ReadSeeker
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	if o.signer != nil {
+		// The signer hashes the payload and sets it as the request body
+		if _, err := o.signer.Sign(req, body, "es", o.region, time.Now()); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		if body != nil {
+			req.Body = io.NopCloser(body)
+			req.ContentLength = int64(len(payload))
+		}
+		if o.username != "" {
+			req.SetBasicAuth(o.username, o.password)
+		}
+	}
+
+	resp, err := o.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, responseBody, nil
+}
+
+func checkStatus(status int, body []byte) error {
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	if len(body) > 512 {
+		body = body[:512]
+	}
+	return fmt.Errorf("search cluster answered %d: %s", status, strings.TrimSpace(string(body)))
+}
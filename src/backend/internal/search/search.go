@@ -0,0 +1,149 @@
+package search
+
+import (
+	"backend/internal/domain"
+	"context"
+	"strings"
+	"unicode"
+)
+
+const (
+	KindCourse  = "course"
+	KindRoadmap = "roadmap"
+
+	DefaultLimit = 20
+	MaxLimit     = 50
+)
+
+// Index is where courses and roadmaps are searched. The memory index serves local runs, the
+// OpenSearch adapter the deployed lambdas. A query without text lists the documents passing its
+// filters
+type Index interface {
+	Upsert(ctx context.Context, doc Document) error
+	Delete(ctx context.Context, kind, id string) error
+	Search(ctx context.Context, query Query) ([]Hit, error)
+}
+
+// Document holds the searchable text of a course or roadmap, results are loaded from their
+// tables so the index never serves stale likes or courses
+type Document struct {
+	ID          string   `json:"id"`
+	Kind        string   `json:"kind"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Author      string   `json:"author"`
+	Topics      []string `json:"topics"`
+	Difficulty  string   `json:"difficulty"`
+}
+
+type Query struct {
+	Text       string
+	Kinds      []string
+	Topics     []string
+	Difficulty string
+	Limit      int
+}
+
+type Hit struct {
+	ID    string  `json:"id"`
+	Kind  string  `json:"kind"`
+	Score float64 `json:"score"`
+}
+
+func CourseDocument(course *domain.Course) Document {
+	return Document{
+		ID:          course.ID,
+		Kind:        KindCourse,
+		Title:       course.Title,
+		Description: course.Description,
+		Author:      course.Author,
+		Topics:      course.Topics,
+		Difficulty:  course.Difficulty,
+	}
+}
+
+func RoadmapDocument(roadmap *domain.Roadmap) Document {
+	return Document{
+		ID:          roadmap.ID,
+		Kind:        KindRoadmap,
+		Title:       roadmap.Title,
+		Description: roadmap.Description,
+		Author:      roadmap.Author,
+		Topics:      roadmap.Topics,
+		Difficulty:  roadmap.Difficulty,
+	}
+}
+
+// Normalize fills in the defaults and lowercases the filters, which match case-insensitively
+func (q Query) Normalize() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	} else if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+
+	q.Text = strings.TrimSpace(q.Text)
+	q.Difficulty = strings.ToLower(q.Difficulty)
+	q.Kinds = lowerAll(q.Kinds)
+	q.Topics = lowerAll(q.Topics)
+	return q
+}
+
+// Empty reports whether a normalized query has neither text nor filters
+func (q Query) Empty() bool {
+	return q.Text == "" && len(q.Kinds) == 0 && len(q.Topics) == 0 && q.Difficulty == ""
+}
+
+// Matches reports whether a document passes the filters of a normalized query
+func (q Query) Matches(doc Document) bool {
+	if len(q.Kinds) > 0 && !contains(q.Kinds, doc.Kind) {
+		return false
+	}
+	if q.Difficulty != "" && strings.ToLower(doc.Difficulty) != q.Difficulty {
+		return false
+	}
+	if len(q.Topics) > 0 {
+		for _, topic := range doc.Topics {
+			if contains(q.Topics, strings.ToLower(topic)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Tokenize splits text into lowercase terms, dropping plural endings so "roadmaps" finds "roadmap"
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+' && r != '#'
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			lowered = append(lowered, value)
+		}
+	}
+	return lowered
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
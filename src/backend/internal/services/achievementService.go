@@ -0,0 +1,163 @@
+package services
+
+import (
+	"backend/internal/domain"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+//go:embed achievements.json
+var defaultAchievementConfig []byte
+
+// AchievementConfig declares how events turn into XP, counters and badges. The default one is
+// embedded in the binary and the ACHIEVEMENTS_CONFIG environment variable replaces it
+type AchievementConfig struct {
+	// XP needed to reach each level, the first entry is level 1
+	Levels       []int                           `json:"levels"`
+	Events       map[string]AchievementEventRule `json:"events"`
+	Achievements []AchievementRule               `json:"achievements"`
+}
+
+type AchievementEventRule struct {
+	XP       int           `json:"xp"`
+	Counters []CounterRule `json:"counters"`
+}
+
+// CounterRule counts the events of a type. With Max the counter keeps the highest value seen
+// instead, with Where only events whose value reaches Min are counted
+type CounterRule struct {
+	Counter string `json:"counter"`
+	Max     string `json:"max,omitempty"`
+	Where   string `json:"where,omitempty"`
+	Min     int    `json:"min,omitempty"`
+}
+
+// AchievementRule unlocks a badge once a counter reaches the threshold
+type AchievementRule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Counter     string `json:"counter"`
+	Threshold   int    `json:"threshold"`
+	XP          int    `json:"xp"`
+}
+
+// AchievementEvent is something a user did, with the values rules can look at (e.g. the rating of a challenge)
+type AchievementEvent struct {
+	Type   string
+	Values map[string]int
+}
+
+type AchievementService struct {
+	config AchievementConfig
+	now    func() time.Time
+}
+
+func NewAchievementService() (*AchievementService, error) {
+	raw := defaultAchievementConfig
+	if override := os.Getenv("ACHIEVEMENTS_CONFIG"); override != "" {
+		raw = []byte(override)
+	}
+
+	var config AchievementConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("invalid achievements config: %w", err)
+	}
+
+	return NewAchievementServiceWithConfig(config, time.Now)
+}
+
+func NewAchievementServiceWithConfig(config AchievementConfig, now func() time.Time) (*AchievementService, error) {
+	for i := 1; i < len(config.Levels); i++ {
+		if config.Levels[i] <= config.Levels[i-1] {
+			return nil, errors.New("invalid achievements config: levels must be increasing")
+		}
+	}
+
+	ids := make(map[string]bool)
+	for _, rule := range config.Achievements {
+		if rule.ID == "" || ids[rule.ID] {
+			return nil, fmt.Errorf("invalid achievements config: missing or duplicated achievement id %q", rule.ID)
+		}
+		if rule.Threshold <= 0 {
+			return nil, fmt.Errorf("invalid achievements config: achievement %s needs a positive threshold", rule.ID)
+		}
+		ids[rule.ID] = true
+	}
+
+	return &AchievementService{config: config, now: now}, nil
+}
+
+// Apply records the event on the user and returns the achievements it unlocked
+func (s *AchievementService) Apply(user *domain.User, event AchievementEvent) []domain.Achievement {
+	rule, ok := s.config.Events[event.Type]
+	if !ok {
+		return nil
+	}
+
+	if user.AchievementCounters == nil {
+		user.AchievementCounters = make(map[string]int)
+	}
+
+	user.XP += rule.XP
+	for _, counter := range rule.Counters {
+		switch {
+		case counter.Max != "":
+			if value := event.Values[counter.Max]; value > user.AchievementCounters[counter.Counter] {
+				user.AchievementCounters[counter.Counter] = value
+			}
+		case counter.Where != "":
+			if event.Values[counter.Where] >= counter.Min {
+				user.AchievementCounters[counter.Counter]++
+			}
+		default:
+			user.AchievementCounters[counter.Counter]++
+		}
+	}
+
+	unlocked := make(map[string]bool, len(user.Achievements))
+	for _, achievement := range user.Achievements {
+		unlocked[achievement.ID] = true
+	}
+
+	newlyUnlocked := make([]domain.Achievement, 0)
+	for _, achievementRule := range s.config.Achievements {
+		if unlocked[achievementRule.ID] || user.AchievementCounters[achievementRule.Counter] < achievementRule.Threshold {
+			continue
+		}
+
+		achievement := domain.Achievement{
+			ID:          achievementRule.ID,
+			Name:        achievementRule.Name,
+			Description: achievementRule.Description,
+			UnlockedAt:  s.now().UTC(),
+		}
+		user.Achievements = append(user.Achievements, achievement)
+		user.XP += achievementRule.XP
+		newlyUnlocked = append(newlyUnlocked, achievement)
+	}
+
+	user.Level = s.Level(user.XP)
+
+	return newlyUnlocked
+}
+
+// Level is the level reached with the given XP, starting at 1
+func (s *AchievementService) Level(xp int) int {
+	level := 0
+	for _, threshold := range s.config.Levels {
+		if xp < threshold {
+			break
+		}
+		level++
+	}
+
+	if level == 0 {
+		return 1
+	}
+	return level
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/events"
+	"backend/internal/repository"
+	"context"
+	"os"
+)
+
+// ActivitySubscribers are the side effects of user activity that do not decide the outcome of
+// a request: achievements and leaderboards. Each handler loads the user it needs, so it works
+// the same whether the event is dispatched in process or delivered later from the outbox, and
+// only writes the achievement fields of the user, never the whole item. Achievements for
+// challenges are awarded by the daily lambda itself, which returns them with the answer
+type ActivitySubscribers struct {
+	users        repository.IUserRepository
+	achievements *AchievementService
+	leaderboards *LeaderboardService
+}
+
+func NewActivitySubscribers(users repository.IUserRepository, achievements *AchievementService, leaderboards *LeaderboardService) *ActivitySubscribers {
+	return &ActivitySubscribers{
+		users:        users,
+		achievements: achievements,
+		leaderboards: leaderboards,
+	}
+}
+
+// Achievements and leaderboards are separate handlers, so a retry after one failed does not
+// repeat the other
+func (s *ActivitySubscribers) Register(bus events.Subscriber) {
+	bus.Subscribe(events.ChallengeAnsweredEvent, "leaderboards", s.recordChallenge)
+	bus.Subscribe(events.RoadmapCompletedEvent, "achievements", s.rewardCompletion)
+	bus.Subscribe(events.RoadmapCompletedEvent, "leaderboards", s.recordCompletion)
+	bus.Subscribe(events.RoadmapCreatedEvent, "achievements", s.onRoadmapCreated)
+	bus.Subscribe(events.RoadmapLikedEvent, "achievements", s.onRoadmapLiked)
+}
+
+func (s *ActivitySubscribers) recordChallenge(ctx context.Context, event events.Event) error {
+	answered := event.(events.ChallengeAnswered)
+
+	user, err := s.users.GetUserByName(answered.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.leaderboards.RecordChallenge(ctx, user, answered.Topic, answered.Rating)
+}
+
+func (s *ActivitySubscribers) rewardCompletion(ctx context.Context, event events.Event) error {
+	return s.reward(ctx, event.(events.RoadmapCompleted).UserID, constants.EventRoadmapCompleted)
+}
+
+func (s *ActivitySubscribers) recordCompletion(ctx context.Context, event events.Event) error {
+	completed := event.(events.RoadmapCompleted)
+
+	user, err := s.users.GetUserByName(completed.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.leaderboards.RecordCompletion(ctx, user, completed.Topics)
+}
+
+func (s *ActivitySubscribers) onRoadmapCreated(ctx context.Context, event events.Event) error {
+	return s.reward(ctx, event.(events.RoadmapCreated).UserID, constants.EventRoadmapCreated)
+}
+
+// onRoadmapLiked rewards the author, likes on their own roadmaps do not count
+func (s *ActivitySubscribers) onRoadmapLiked(ctx context.Context, event events.Event) error {
+	liked := event.(events.RoadmapLiked)
+	if liked.AuthorID == "" || liked.AuthorID == liked.UserID {
+		return nil
+	}
+
+	return s.reward(ctx, liked.AuthorID, constants.EventRoadmapLiked)
+}
+
+func (s *ActivitySubscribers) reward(ctx context.Context, userID, eventType string) error {
+	user, err := s.users.GetUserByName(userID)
+	if err != nil {
+		return err
+	}
+
+	s.achievements.Apply(user, AchievementEvent{Type: eventType})
+	return s.users.UpdateAchievements(ctx, user)
+}
+
+// NewEventPublisher dispatches events in process to the subscribers, unless EVENT_BUS is set to
+// outbox, in which case events are stored for cmd/outbox to deliver asynchronously
+func NewEventPublisher(outbox repository.IOutboxRepository, subscribers ...IEventSubscriber) events.Publisher {
+	if os.Getenv("EVENT_BUS") == "outbox" {
+		return events.NewOutboxPublisher(outbox)
+	}
+
+	bus := events.NewInProcessBus()
+	for _, subscriber := range subscribers {
+		subscriber.Register(bus)
+	}
+	return bus
+}
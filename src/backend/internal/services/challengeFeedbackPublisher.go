@@ -0,0 +1,168 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const publishChallengeFeedbackMutation = `mutation PublishChallengeFeedback($input: ChallengeFeedbackInput!) {
+	publishChallengeFeedback(input: $input) { username sequence tokens done rating left }
+}`
+
+// AppSyncFeedbackPublisher pushes feedback messages through the publishChallengeFeedback mutation,
+// which fans them out to the onChallengeFeedback subscribers. Requests are signed with the
+// lambda's IAM credentials, only IAM callers may publish feedback
+type AppSyncFeedbackPublisher struct {
+	endpoint string
+	region   string
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+func NewAppSyncFeedbackPublisher(sess *session.Session) *AppSyncFeedbackPublisher {
+	return &AppSyncFeedbackPublisher{
+		endpoint: os.Getenv("APPSYNC_ENDPOINT"),
+		region:   aws.StringValue(sess.Config.Region),
+		signer:   v4.NewSigner(sess.Config.Credentials),
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (p *AppSyncFeedbackPublisher) Publish(ctx context.Context, feedback domain.ChallengeFeedback) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     publishChallengeFeedbackMutation,
+		"variables": map[string]interface{}{"input": feedback},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Signing takes the body along, the payload is hashed into the signature
+	if _, err := p.signer.Sign(req, bytes.NewReader(payload), "appsync", p.region, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to publish challenge feedback: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// FeedbackStream batches insight tokens into feedback messages so subscribers get a steady
+// flow of updates without one request per token. A nil publisher turns it into a no-op
+type FeedbackStream struct {
+	ctx       context.Context
+	publisher IChallengeFeedbackPublisher
+	username  string
+	interval  time.Duration
+
+	mu        sync.Mutex
+	pending   strings.Builder
+	sequence  int
+	lastFlush time.Time
+	done      bool
+}
+
+func NewFeedbackStream(ctx context.Context, publisher IChallengeFeedbackPublisher, username string) *FeedbackStream {
+	return &FeedbackStream{
+		ctx:       ctx,
+		publisher: publisher,
+		username:  username,
+		interval:  250 * time.Millisecond,
+		lastFlush: time.Now(),
+	}
+}
+
+// Write queues a token and publishes the queued tokens once the flush interval has elapsed
+func (s *FeedbackStream) Write(token string) {
+	if s.publisher == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.pending.WriteString(token)
+	if time.Since(s.lastFlush) >= s.interval {
+		s.flush(domain.ChallengeFeedback{})
+	}
+}
+
+// Finish publishes the remaining tokens together with the final rating and remaining challenges
+func (s *FeedbackStream) Finish(response *domain.ChallengeResponse) {
+	if s.publisher == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+	s.flush(domain.ChallengeFeedback{
+		Done:   true,
+		Rating: response.Rating,
+		Left:   response.Left,
+	})
+}
+
+// Close ends a stream that was not finished, so subscribers stop waiting when grading or saving
+// the answer failed. It is meant to be deferred and does nothing after Finish
+func (s *FeedbackStream) Close() {
+	if s.publisher == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+	s.flush(domain.ChallengeFeedback{Done: true})
+}
+
+func (s *FeedbackStream) flush(feedback domain.ChallengeFeedback) {
+	feedback.Username = s.username
+	feedback.Sequence = s.sequence
+	feedback.Tokens = s.pending.String()
+
+	s.pending.Reset()
+	s.sequence++
+	s.lastFlush = time.Now()
+
+	// Streaming is best effort, the mutation response still carries the whole insight
+	if err := s.publisher.Publish(s.ctx, feedback); err != nil {
+		log.Printf("Failed to publish challenge feedback for %s: %v", s.username, err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type ICodeRunner interface {
+	Run(ctx context.Context, language, code, input string, limits RunLimits) (*RunResult, error)
+}
+
+// ChallengeGrader grades multiple choice, short answer and code challenges without a model call.
+// Without a runner code challenges fail with ErrCodeRunnerUnavailable
+type ChallengeGrader struct {
+	runner ICodeRunner
+}
+
+func NewChallengeGrader(runner ICodeRunner) *ChallengeGrader {
+	return &ChallengeGrader{runner: runner}
+}
+
+// IsDeterministic reports whether questions of the type are graded by ChallengeGrader
+func IsDeterministic(questionType string) bool {
+	switch questionType {
+	case constants.ChallengeTypeMultipleChoice, constants.ChallengeTypeShortAnswer, constants.ChallengeTypeCode:
+		return true
+	}
+	return false
+}
+
+// ValidateQuestion checks that a question carries what its type needs to be graded
+func ValidateQuestion(question *domain.Question) error {
+	switch question.Type {
+	case constants.ChallengeTypeMultipleChoice:
+		if len(question.Choices) < 2 {
+			return errors.New("multiple choice questions need at least two choices")
+		}
+		if question.CorrectChoice < 0 || question.CorrectChoice >= len(question.Choices) {
+			return errors.New("correct choice is out of range")
+		}
+	case constants.ChallengeTypeShortAnswer:
+		if len(question.AcceptedAnswers) == 0 {
+			return errors.New("short answer questions need at least one accepted answer")
+		}
+	case constants.ChallengeTypeCode:
+		if !SupportsLanguage(question.Language) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedLanguage, question.Language)
+		}
+		if len(question.TestCases) == 0 {
+			return errors.New("code questions need at least one test case")
+		}
+	}
+	return nil
+}
+
+func (g *ChallengeGrader) Grade(ctx context.Context, question *domain.Question, answer string) (*domain.ChallengeResponse, error) {
+	response := &domain.ChallengeResponse{
+		Question: question.Question,
+		Answer:   answer,
+		Scores:   make([]domain.CriterionScore, 0),
+	}
+
+	switch question.Type {
+	case constants.ChallengeTypeMultipleChoice:
+		gradeMultipleChoice(question, answer, response)
+	case constants.ChallengeTypeShortAnswer:
+		gradeShortAnswer(question, answer, response)
+	case constants.ChallengeTypeCode:
+		if err := g.gradeCode(ctx, question, answer, response); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("questions of type %s are not graded deterministically", question.Type)
+	}
+
+	return response, nil
+}
+
+// gradeMultipleChoice accepts the text of the choice, or its index when the answer matches no
+// choice text, so choices that are numbers themselves are matched by their value
+func gradeMultipleChoice(question *domain.Question, answer string, response *domain.ChallengeResponse) {
+	correct := question.Choices[question.CorrectChoice]
+
+	chosen := -1
+	for i, choice := range question.Choices {
+		if normalizeAnswer(choice) == normalizeAnswer(answer) {
+			chosen = i
+			break
+		}
+	}
+	if chosen == -1 {
+		if index, err := strconv.Atoi(strings.TrimSpace(answer)); err == nil {
+			chosen = index
+		}
+	}
+
+	if chosen == question.CorrectChoice {
+		response.Rating = MaxRating
+		response.Insight = "Correct!"
+		return
+	}
+
+	response.Rating = MinRating
+	response.Insight = fmt.Sprintf("Not quite, the correct answer is: %s", correct)
+}
+
+func gradeShortAnswer(question *domain.Question, answer string, response *domain.ChallengeResponse) {
+	normalized := normalizeAnswer(answer)
+	for _, accepted := range question.AcceptedAnswers {
+		if normalizeAnswer(accepted) == normalized {
+			response.Rating = MaxRating
+			response.Insight = "Correct!"
+			return
+		}
+	}
+
+	response.Rating = MinRating
+	response.Insight = fmt.Sprintf("Not quite, an accepted answer is: %s", question.AcceptedAnswers[0])
+}
+
+// gradeCode runs the program against every test case, the rating grows with the share of passed cases
+func (g *ChallengeGrader) gradeCode(ctx context.Context, question *domain.Question, code string, response *domain.ChallengeResponse) error {
+	if g.runner == nil {
+		return ErrCodeRunnerUnavailable
+	}
+
+	limits := RunLimits{
+		Time:     time.Duration(question.TimeLimitMs) * time.Millisecond,
+		MemoryMb: question.MemoryLimitMb,
+	}
+
+	passed := 0
+	var failure string
+	for i, testCase := range question.TestCases {
+		result, err := g.runner.Run(ctx, question.Language, code, testCase.Input, limits)
+		if err != nil {
+			return err
+		}
+
+		output := strings.TrimRightFunc(result.Stdout, unicode.IsSpace)
+		expected := strings.TrimRightFunc(testCase.ExpectedOutput, unicode.IsSpace)
+		if !result.TimedOut && result.ExitCode == 0 && output == expected {
+			passed++
+			continue
+		}
+
+		if failure != "" {
+			continue
+		}
+
+		// Hidden test cases are reported without their contents
+		switch {
+		case result.TimedOut:
+			failure = fmt.Sprintf("Test %d exceeded the time limit.", i+1)
+		case result.ExitCode != 0:
+			failure = fmt.Sprintf("Test %d crashed with exit code %d.", i+1, result.ExitCode)
+		case testCase.Hidden:
+			failure = fmt.Sprintf("Test %d produced a wrong answer.", i+1)
+		default:
+			failure = fmt.Sprintf("Test %d expected %q but got %q.", i+1, expected, output)
+		}
+	}
+
+	total := len(question.TestCases)
+	response.Rating = ClampRating(MinRating + int(math.Round(float64(MaxRating-MinRating)*float64(passed)/float64(total))))
+	response.Insight = fmt.Sprintf("%d of %d tests passed. %s", passed, total, failure)
+	response.Insight = strings.TrimSpace(response.Insight)
+
+	return nil
+}
+
+// normalizeAnswer ignores case, spacing and trailing punctuation differences
+func normalizeAnswer(answer string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(answer)), " ")
+	return strings.TrimRight(normalized, ".,;:!?")
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"math"
+	"sort"
+	"time"
+)
+
+// ComputeChallengeStats aggregates attempts into the average rating per topic and the
+// attempts per calendar day in the given location
+func ComputeChallengeStats(attempts []*domain.ChallengeAttempt, location *time.Location) domain.ChallengeStats {
+	stats := domain.ChallengeStats{
+		Topics:   make([]domain.TopicChallengeStats, 0),
+		Timeline: make([]domain.DailyChallengeActivity, 0),
+	}
+
+	topicTotals := make(map[string]*domain.TopicChallengeStats)
+	dayTotals := make(map[string]*domain.DailyChallengeActivity)
+	total := 0
+
+	for _, attempt := range attempts {
+		total += attempt.Rating
+
+		topic, ok := topicTotals[attempt.Topic]
+		if !ok {
+			topic = &domain.TopicChallengeStats{Topic: attempt.Topic}
+			topicTotals[attempt.Topic] = topic
+		}
+		topic.Attempts++
+		topic.AverageRating += float64(attempt.Rating)
+
+		date := attempt.CreatedAt.In(location).Format(time.DateOnly)
+		day, ok := dayTotals[date]
+		if !ok {
+			day = &domain.DailyChallengeActivity{Date: date}
+			dayTotals[date] = day
+		}
+		day.Attempts++
+		day.AverageRating += float64(attempt.Rating)
+	}
+
+	stats.TotalAttempts = len(attempts)
+	if stats.TotalAttempts > 0 {
+		stats.AverageRating = roundRating(float64(total) / float64(stats.TotalAttempts))
+	}
+
+	// Sums become averages once every attempt has been counted
+	for _, topic := range topicTotals {
+		topic.AverageRating = roundRating(topic.AverageRating / float64(topic.Attempts))
+		stats.Topics = append(stats.Topics, *topic)
+	}
+	for _, day := range dayTotals {
+		day.AverageRating = roundRating(day.AverageRating / float64(day.Attempts))
+		stats.Timeline = append(stats.Timeline, *day)
+	}
+
+	// Weakest topics first, that is where learners struggle
+	sort.Slice(stats.Topics, func(i, j int) bool {
+		if stats.Topics[i].AverageRating != stats.Topics[j].AverageRating {
+			return stats.Topics[i].AverageRating < stats.Topics[j].AverageRating
+		}
+		return stats.Topics[i].Topic < stats.Topics[j].Topic
+	})
+	sort.Slice(stats.Timeline, func(i, j int) bool {
+		return stats.Timeline[i].Date < stats.Timeline[j].Date
+	})
+
+	return stats
+}
+
+func roundRating(rating float64) float64 {
+	return math.Round(rating*100) / 100
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeLimit   = 2 * time.Second
+	defaultMemoryLimit = 256 // MB
+	maxProgramOutput   = 64 * 1024
+)
+
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// ErrCodeRunnerUnavailable is returned for code challenges when no isolated runner is configured
+var ErrCodeRunnerUnavailable = errors.New("code challenges cannot be graded right now")
+
+// RunLimits bounds a single execution of a learner's program
+type RunLimits struct {
+	Time     time.Duration
+	MemoryMb int
+}
+
+type RunResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+	TimedOut bool   `json:"timedOut"`
+}
+
+// runtimeCommand is how a language is executed from a file named main.<extension>. Runtimes
+// reserving large address spaces up front bound their heap themselves instead of through ulimit -v
+type runtimeCommand struct {
+	extension         string
+	command           func(memoryMb int) []string
+	limitAddressSpace bool
+}
+
+var runtimes = map[string]runtimeCommand{
+	"python": {
+		extension:         "py",
+		command:           func(int) []string { return []string{"python3", "-I", "main.py"} },
+		limitAddressSpace: true,
+	},
+	"javascript": {
+		extension: "js",
+		command: func(memoryMb int) []string {
+			return []string{"node", fmt.Sprintf("--max-old-space-size=%d", memoryMb), "main.js"}
+		},
+	},
+}
+
+func SupportsLanguage(language string) bool {
+	_, ok := runtimes[strings.ToLower(language)]
+	return ok
+}
+
+// LocalCodeRunner runs programs in a throwaway directory with an empty environment, under
+// CPU time, address space and output limits, and kills them with their children when the time
+// limit is exceeded. It is no sandbox, programs run as the calling process' user with its network
+// access: it only belongs in the isolated code runner lambda and in local development
+type LocalCodeRunner struct{}
+
+func NewLocalCodeRunner() *LocalCodeRunner {
+	return &LocalCodeRunner{}
+}
+
+func (r *LocalCodeRunner) Run(ctx context.Context, language, code, input string, limits RunLimits) (*RunResult, error) {
+	runtime, ok := runtimes[strings.ToLower(language)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+
+	if limits.Time <= 0 {
+		limits.Time = defaultTimeLimit
+	}
+	if limits.MemoryMb <= 0 {
+		limits.MemoryMb = defaultMemoryLimit
+	}
+
+	dir, err := os.MkdirTemp("", "qriosity-run-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "main."+runtime.extension), []byte(code), 0o600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, limits.Time)
+	defer cancel()
+
+	// ulimit applies the limits to the program itself, the context deadline covers wall time
+	script := fmt.Sprintf(`ulimit -t %d; ulimit -f 1024; `, int(limits.Time.Seconds())+1)
+	if runtime.limitAddressSpace {
+		script += fmt.Sprintf(`ulimit -v %d; `, limits.MemoryMb*1024)
+	}
+	script += `exec "$@"`
+	cmd := exec.CommandContext(ctx, "sh", append([]string{"-c", script, "sh"}, runtime.command(limits.MemoryMb)...)...)
+	cmd.Dir = dir
+	cmd.Env = []string{"PATH=/usr/local/bin:/usr/bin:/bin", "HOME=" + dir, "TMPDIR=" + dir}
+	cmd.Stdin = strings.NewReader(input)
+
+	// Programs run in their own process group, which is killed as a whole so background children
+	// do not outlive the run and linger into the next one
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+	defer func() {
+		if cmd.Process != nil {
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}()
+
+	stdout := &limitedBuffer{limit: maxProgramOutput}
+	stderr := &limitedBuffer{limit: maxProgramOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	result := &RunResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// limitedBuffer keeps the first limit bytes written and silently drops the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.Buffer.Write(p[:remaining])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
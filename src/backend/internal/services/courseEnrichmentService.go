@@ -0,0 +1,265 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/safehttp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeadLink is returned when a course URL cannot be reached or answers with an error status
+var ErrDeadLink = errors.New("dead link")
+
+const maxPageSize = 1 << 20 // 1MB is plenty to reach the <head> of any course page
+
+// HTTPFetcher is the subset of *http.Client used to fetch course pages, so tests can point it at a local server
+type HTTPFetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type CourseEnrichmentService struct {
+	fetcher HTTPFetcher
+}
+
+// NewCourseEnrichmentService fetches pages through a client that refuses non-public addresses,
+// course URLs come from the model and must not reach the metadata or runtime endpoints
+func NewCourseEnrichmentService() *CourseEnrichmentService {
+	return NewCourseEnrichmentServiceWithFetcher(safehttp.NewClient(10 * time.Second))
+}
+
+func NewCourseEnrichmentServiceWithFetcher(fetcher HTTPFetcher) *CourseEnrichmentService {
+	return &CourseEnrichmentService{fetcher: fetcher}
+}
+
+var (
+	tagRegex   = regexp.MustCompile(`(?is)<(meta|link)\s[^>]*>`)
+	attrRegex  = regexp.MustCompile(`(?is)([a-z][a-z0-9:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+type pageMetadata struct {
+	meta      map[string]string
+	canonical string
+	oEmbed    string
+	title     string
+}
+
+type oEmbedResponse struct {
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// Enrich checks that the course URL is alive, replaces it with its canonical form and fills
+// title, description, provider, author and image from the page's OpenGraph or oEmbed metadata
+func (s *CourseEnrichmentService) Enrich(ctx context.Context, course *domain.Course) error {
+	resp, err := s.get(ctx, course.URL, "text/html")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The client follows redirects, so the request on the response holds the final URL
+	finalURL := course.URL
+	if resp.Request != nil && resp.Request.URL != nil {
+		finalURL = resp.Request.URL.String()
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return err
+	}
+
+	metadata := parsePageMetadata(string(body))
+
+	course.URL = finalURL
+	if canonical := resolveURL(finalURL, metadata.canonical); canonical != "" {
+		course.URL = canonical
+	}
+
+	var embed oEmbedResponse
+	if endpoint := resolveURL(finalURL, metadata.oEmbed); endpoint != "" {
+		if err := s.fetchOEmbed(ctx, endpoint, &embed); err != nil {
+			log.Printf("Failed to fetch oEmbed for %s: %v", course.URL, err)
+		}
+	}
+
+	course.Title = firstNonEmpty(metadata.meta["og:title"], embed.Title, metadata.title, course.Title)
+	course.Description = firstNonEmpty(metadata.meta["og:description"], metadata.meta["description"], course.Description)
+	course.Source = firstNonEmpty(metadata.meta["og:site_name"], embed.ProviderName, course.Source)
+	course.Author = firstNonEmpty(embed.AuthorName, metadata.meta["author"], course.Author)
+	course.ImageURL = firstNonEmpty(resolveURL(finalURL, metadata.meta["og:image"]), embed.ThumbnailURL, course.ImageURL)
+
+	return nil
+}
+
+// EnrichAll enriches the courses concurrently, dropping dead links and courses that resolve to the same canonical URL
+func (s *CourseEnrichmentService) EnrichAll(ctx context.Context, courses []domain.Course) []domain.Course {
+	enriched := make([]*domain.Course, len(courses))
+
+	var wg sync.WaitGroup
+	for i := range courses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			course := courses[i]
+			if err := s.Enrich(ctx, &course); err != nil {
+				log.Printf("Dropping course %s: %v", courses[i].URL, err)
+				return
+			}
+			enriched[i] = &course
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	result := make([]domain.Course, 0, len(courses))
+	for _, course := range enriched {
+		if course == nil || seen[course.URL] {
+			continue
+		}
+		seen[course.URL] = true
+		result = append(result, *course)
+	}
+
+	return result
+}
+
+// CheckLink requests the URL, following redirects, and reports the final status code.
+// The code is 0 when the host could not be reached at all
+func (s *CourseEnrichmentService) CheckLink(ctx context.Context, rawURL string) (int, error) {
+	resp, err := s.do(ctx, rawURL, "text/html")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return resp.StatusCode, fmt.Errorf("%w: %s returned %d", ErrDeadLink, rawURL, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s *CourseEnrichmentService) get(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	resp, err := s.do(ctx, rawURL, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s returned %d", ErrDeadLink, rawURL, resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+func (s *CourseEnrichmentService) do(ctx context.Context, rawURL, accept string) (*http.Response, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: invalid url %q", ErrDeadLink, rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "QriosityBot/1.0")
+	req.Header.Set("Accept", accept)
+
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDeadLink, err)
+	}
+
+	return resp, nil
+}
+
+func (s *CourseEnrichmentService) fetchOEmbed(ctx context.Context, endpoint string, embed *oEmbedResponse) error {
+	resp, err := s.get(ctx, endpoint, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxPageSize)).Decode(embed)
+}
+
+func parsePageMetadata(page string) pageMetadata {
+	metadata := pageMetadata{meta: make(map[string]string)}
+
+	if match := titleRegex.FindStringSubmatch(page); match != nil {
+		metadata.title = cleanText(match[1])
+	}
+
+	for _, tag := range tagRegex.FindAllStringSubmatch(page, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range attrRegex.FindAllStringSubmatch(tag[0], -1) {
+			attrs[strings.ToLower(attr[1])] = attr[2] + attr[3] + attr[4]
+		}
+
+		switch strings.ToLower(tag[1]) {
+		case "meta":
+			key := strings.ToLower(firstNonEmpty(attrs["property"], attrs["name"]))
+			if key != "" && attrs["content"] != "" {
+				if _, exists := metadata.meta[key]; !exists {
+					metadata.meta[key] = cleanText(attrs["content"])
+				}
+			}
+		case "link":
+			rel := strings.ToLower(attrs["rel"])
+			if rel == "canonical" && metadata.canonical == "" {
+				metadata.canonical = html.UnescapeString(attrs["href"])
+			} else if strings.ToLower(attrs["type"]) == "application/json+oembed" && metadata.oEmbed == "" {
+				metadata.oEmbed = html.UnescapeString(attrs["href"])
+			}
+		}
+	}
+
+	return metadata
+}
+
+// resolveURL resolves a possibly relative reference against the page URL, returning "" when it is unusable
+func resolveURL(base, ref string) string {
+	if strings.TrimSpace(ref) == "" {
+		return ""
+	}
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+
+	resolved, err := baseURL.Parse(strings.TrimSpace(ref))
+	if err != nil || (resolved.Scheme != "http" && resolved.Scheme != "https") {
+		return ""
+	}
+
+	return resolved.String()
+}
+
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
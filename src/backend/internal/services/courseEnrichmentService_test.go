@@ -0,0 +1,228 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/safehttp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newEnrichmentServer serves the pages of the test cases, plain handlers keyed by path
+func newEnrichmentServer(t *testing.T, pages map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for path, handler := range pages {
+		mux.HandleFunc(path, handler)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func page(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	}
+}
+
+func redirect(to string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, to, http.StatusFound)
+	}
+}
+
+func TestEnrichFallbacks(t *testing.T) {
+	t.Setenv("ALLOW_PRIVATE_URLS", "true")
+
+	var server *httptest.Server
+	server = newEnrichmentServer(t, map[string]http.HandlerFunc{
+		"/og": page(`<html><head><title>Page title</title>
+			<meta property="og:title" content="OG title">
+			<meta property="og:description" content="OG description">
+			<meta property="og:site_name" content="Site">
+			<meta property="og:image" content="/cover.png"></head></html>`),
+		"/title-only": page(`<html><head><title>  Only   the title </title>
+			<meta name="description" content="Plain description"></head></html>`),
+		"/embed": func(w http.ResponseWriter, r *http.Request) {
+			page(fmt.Sprintf(`<html><head><title>Fallback</title>
+				<link rel="alternate" type="application/json+oembed" href="%s/oembed"></head></html>`, server.URL))(w, r)
+		},
+		"/oembed": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"title":"Embedded title","author_name":"Author","provider_name":"Provider","thumbnail_url":"https://img.example/t.png"}`)
+		},
+		"/broken-embed": page(`<html><head><title>Still enriched</title>
+			<link type="application/json+oembed" href="/missing"></head></html>`),
+		"/canonical": page(`<html><head><link rel="canonical" href="/og"></head></html>`),
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		course domain.Course
+		want   domain.Course
+	}{
+		{
+			name: "OpenGraph wins over the page title",
+			path: "/og",
+			want: domain.Course{Title: "OG title", Description: "OG description", Source: "Site", ImageURL: server.URL + "/cover.png"},
+		},
+		{
+			name:   "title and description tags when there is no OpenGraph",
+			path:   "/title-only",
+			course: domain.Course{Source: "Model source"},
+			want:   domain.Course{Title: "Only the title", Description: "Plain description", Source: "Model source"},
+		},
+		{
+			name: "oEmbed fills what the page lacks",
+			path: "/embed",
+			want: domain.Course{Title: "Embedded title", Source: "Provider", Author: "Author", ImageURL: "https://img.example/t.png"},
+		},
+		{
+			name:   "a failing oEmbed endpoint keeps the page metadata",
+			path:   "/broken-embed",
+			course: domain.Course{Author: "Model author"},
+			want:   domain.Course{Title: "Still enriched", Author: "Model author"},
+		},
+	}
+
+	service := NewCourseEnrichmentService()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := test.course
+			course.URL = server.URL + test.path
+			if err := service.Enrich(context.Background(), &course); err != nil {
+				t.Fatalf("Enrich: %v", err)
+			}
+
+			test.want.URL = server.URL + test.path
+			if !reflect.DeepEqual(course, test.want) {
+				t.Errorf("got %+v, want %+v", course, test.want)
+			}
+		})
+	}
+
+	t.Run("the canonical link replaces the url", func(t *testing.T) {
+		course := domain.Course{URL: server.URL + "/canonical"}
+		if err := service.Enrich(context.Background(), &course); err != nil {
+			t.Fatalf("Enrich: %v", err)
+		}
+		if course.URL != server.URL+"/og" {
+			t.Errorf("got url %s, want the canonical %s/og", course.URL, server.URL)
+		}
+	})
+}
+
+func TestEnrichRedirects(t *testing.T) {
+	t.Setenv("ALLOW_PRIVATE_URLS", "true")
+
+	server := newEnrichmentServer(t, map[string]http.HandlerFunc{
+		"/final":     page(`<html><head><title>Final</title></head></html>`),
+		"/moved":     redirect("/final"),
+		"/twice":     redirect("/moved"),
+		"/loop":      redirect("/loop"),
+		"/ftp":       redirect("ftp://files.example/course"),
+		"/not-found": http.NotFound,
+		"/to-dead":   redirect("/not-found"),
+	})
+
+	tests := []struct {
+		name    string
+		path    string
+		wantURL string
+		wantErr bool
+	}{
+		{name: "one redirect", path: "/moved", wantURL: "/final"},
+		{name: "chained redirects", path: "/twice", wantURL: "/final"},
+		{name: "redirect loop", path: "/loop", wantErr: true},
+		{name: "redirect to another scheme", path: "/ftp", wantErr: true},
+		{name: "dead page", path: "/not-found", wantErr: true},
+		{name: "redirect to a dead page", path: "/to-dead", wantErr: true},
+	}
+
+	service := NewCourseEnrichmentService()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := domain.Course{URL: server.URL + test.path}
+			err := service.Enrich(context.Background(), &course)
+			if test.wantErr {
+				if !errors.Is(err, ErrDeadLink) {
+					t.Fatalf("got %v, want a dead link", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Enrich: %v", err)
+			}
+			if course.URL != server.URL+test.wantURL {
+				t.Errorf("got url %s, want %s", course.URL, server.URL+test.wantURL)
+			}
+		})
+	}
+}
+
+func TestEnrichRefusesPrivateAddresses(t *testing.T) {
+	t.Setenv("ALLOW_PRIVATE_URLS", "")
+
+	server := newEnrichmentServer(t, map[string]http.HandlerFunc{
+		"/": page(`<html><head><title>Internal</title></head></html>`),
+	})
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "loopback", url: server.URL + "/"},
+		{name: "localhost by name", url: "http://localhost:9001/2018-06-01/runtime/invocation/next"},
+		{name: "instance metadata", url: "http://169.254.169.254/latest/meta-data/"},
+		{name: "private network", url: "http://10.0.0.1/"},
+		{name: "IPv6 loopback", url: "http://[::1]:8080/"},
+	}
+
+	// A short timeout keeps the test quick should an address ever get through to a dial
+	service := NewCourseEnrichmentServiceWithFetcher(safehttp.NewClient(2 * time.Second))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := domain.Course{URL: test.url}
+			err := service.Enrich(context.Background(), &course)
+			if !errors.Is(err, ErrDeadLink) {
+				t.Fatalf("got %v, want a dead link", err)
+			}
+		})
+	}
+
+	t.Run("EnrichAll drops them", func(t *testing.T) {
+		courses := service.EnrichAll(context.Background(), []domain.Course{{URL: server.URL + "/"}})
+		if len(courses) != 0 {
+			t.Errorf("got %d courses, want none", len(courses))
+		}
+	})
+}
+
+func TestEnrichAllDropsDeadLinksAndDuplicates(t *testing.T) {
+	t.Setenv("ALLOW_PRIVATE_URLS", "true")
+
+	server := newEnrichmentServer(t, map[string]http.HandlerFunc{
+		"/a":         page(`<html><head><link rel="canonical" href="/course"></head></html>`),
+		"/b":         redirect("/a"),
+		"/course":    page(`<html><head><title>Course</title></head></html>`),
+		"/not-found": http.NotFound,
+	})
+
+	courses := NewCourseEnrichmentService().EnrichAll(context.Background(), []domain.Course{
+		{URL: server.URL + "/a"},
+		{URL: server.URL + "/not-found"},
+		{URL: server.URL + "/b"},
+	})
+
+	if len(courses) != 1 || courses[0].URL != server.URL+"/course" {
+		t.Fatalf("got %+v, want the canonical course once", courses)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"os"
 )
 
@@ -19,9 +20,9 @@ func NewDailyChallengeService() *DailyChallengeService {
 	}
 }
 
-func (s *DailyChallengeService) GetQuestion(category string) (domain.Problem, error) {
+func (s *DailyChallengeService) GetQuestion(category, difficulty string) (domain.Problem, error) {
 	var problem domain.Problem
-	url := fmt.Sprintf("%s/ask_question?topic=%s", s.baseURL, category)
+	url := fmt.Sprintf("%s/ask_question?topic=%s&difficulty=%s", s.baseURL, neturl.QueryEscape(category), neturl.QueryEscape(difficulty))
 	resp, err := http.Get(url)
 	if err != nil {
 		return problem, err
@@ -37,16 +38,26 @@ func (s *DailyChallengeService) GetQuestion(category string) (domain.Problem, er
 		return problem, err
 	}
 
+	problem.Difficulty = difficulty
+	if problem.Rubric == nil {
+		problem.Rubric = DefaultRubric()
+	}
+
 	return problem, nil
 }
 
-func (s *DailyChallengeService) RateQuestion(question, answer string) (*domain.ChallengeResponse, error) {
+func (s *DailyChallengeService) RateQuestion(question, answer string, rubric *domain.Rubric) (*domain.ChallengeResponse, error) {
 	var challengeResponse domain.ChallengeResponse
 	url := fmt.Sprintf("%s/rate_question", s.baseURL)
 
-	payload := map[string]string{
+	if rubric == nil {
+		rubric = DefaultRubric()
+	}
+
+	payload := map[string]interface{}{
 		"question": question,
 		"answer":   answer,
+		"rubric":   rubric,
 	}
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -70,5 +81,25 @@ func (s *DailyChallengeService) RateQuestion(question, answer string) (*domain.C
 		return nil, err
 	}
 
+	// Never trust the remote rating as is, recompute it from the rubric scores
+	if err := NormalizeGrade(&challengeResponse, rubric); err != nil {
+		return nil, err
+	}
+
 	return &challengeResponse, nil
 }
+
+// RateQuestionStream rates through the remote API. Its lambda returns the grade as one buffered
+// response, so the insight is handed over in one piece once grading is done, not token by token
+func (s *DailyChallengeService) RateQuestionStream(question, answer string, rubric *domain.Rubric, onToken func(string)) (*domain.ChallengeResponse, error) {
+	challengeResponse, err := s.RateQuestion(question, answer, rubric)
+	if err != nil {
+		return nil, err
+	}
+
+	if onToken != nil && challengeResponse.Insight != "" {
+		onToken(challengeResponse.Insight)
+	}
+
+	return challengeResponse, nil
+}
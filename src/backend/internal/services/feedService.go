@@ -0,0 +1,409 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Weights of the course feed signals, each signal being scaled to [0, 1]
+const (
+	courseFeedTopicWeight      = 0.35
+	courseFeedProgressWeight   = 0.3
+	courseFeedPopularityWeight = 0.2
+	courseFeedFreshnessWeight  = 0.15
+)
+
+// Weights of the roadmap feed signals, each signal being scaled to [0, 1]
+const (
+	roadmapFeedTopicWeight      = 0.3
+	roadmapFeedLikesWeight      = 0.2
+	roadmapFeedDifficultyWeight = 0.2
+	roadmapFeedVerifiedWeight   = 0.15
+	roadmapFeedFreshnessWeight  = 0.15
+
+	// Every roadmap already placed from the same author scales the score of the next one by this
+	feedAuthorDecay = 0.6
+)
+
+// Freshness halves every half life
+const feedFreshnessHalfLife = 30 * 24 * time.Hour
+
+// Each of the user's topics brings at most this many candidates to a feed
+const feedCandidatesPerTopic = 200
+
+// FeedService ranks the courses and roadmaps shown to a user on their home page
+type FeedService struct {
+	courses  repository.ICourseRepository
+	roadmaps repository.IRoadmapRepository
+	topics   *TopicResolver
+	skills   *SkillService
+	now      func() time.Time
+}
+
+func NewFeedService(courses repository.ICourseRepository, roadmaps repository.IRoadmapRepository, topics *TopicResolver) *FeedService {
+	return NewFeedServiceWithClock(courses, roadmaps, topics, time.Now)
+}
+
+func NewFeedServiceWithClock(courses repository.ICourseRepository, roadmaps repository.IRoadmapRepository, topics *TopicResolver, now func() time.Time) *FeedService {
+	return &FeedService{courses: courses, roadmaps: roadmaps, topics: topics, skills: NewSkillService(), now: now}
+}
+
+// CourseFeed ranks the courses tagged with the user's topics and those of the roadmaps they follow
+// by topic overlap, progress on those roadmaps, how many of the roadmaps on the user's topics and
+// followed by them use the course, and how new it is. Courses the user already went through are
+// left out
+func (s *FeedService) CourseFeed(ctx context.Context, user *domain.User) ([]*domain.Course, error) {
+	topics, err := s.topics.Canonical(ctx, user.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	var roadmaps []*domain.Roadmap
+	var roadmapsErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		roadmaps, roadmapsErr = s.relatedRoadmaps(ctx, user, topics)
+	}()
+
+	candidates, err := topicCandidates(ctx, topics, s.courses.FindByTopic)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if roadmapsErr != nil {
+		return nil, roadmapsErr
+	}
+
+	// Progress counts the courses done in roadmap order, the one right after is up next
+	completed := make(map[string]bool)
+	progressScores := make(map[string]float64)
+	popularity := make(map[string]float64)
+	counted := make(map[string]bool, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		if counted[roadmap.ID] {
+			continue
+		}
+		counted[roadmap.ID] = true
+
+		for _, courseID := range roadmap.CourseIDs {
+			popularity[courseID] += float64(1 + roadmap.Likes)
+		}
+
+		progress, tracked := user.RoadmapsProgress[roadmap.ID]
+		if !tracked {
+			continue
+		}
+		for i, courseID := range roadmap.CourseIDs {
+			switch {
+			case i < progress:
+				completed[courseID] = true
+			case i == progress:
+				progressScores[courseID] = 1
+			default:
+				progressScores[courseID] = math.Max(progressScores[courseID], 0.5)
+			}
+		}
+	}
+
+	missing, err := s.missingCourses(ctx, candidates, progressScores)
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, missing...)
+
+	maxPopularity := 0.0
+	for _, value := range popularity {
+		maxPopularity = math.Max(maxPopularity, value)
+	}
+
+	userTopics := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		userTopics[strings.ToLower(topic)] = true
+	}
+
+	now := s.now()
+	scores := make(map[string]float64, len(candidates))
+	feed := make([]*domain.Course, 0, len(candidates))
+	for _, course := range candidates {
+		if completed[course.ID] {
+			continue
+		}
+		if _, seen := scores[course.ID]; seen {
+			continue
+		}
+
+		score := courseFeedTopicWeight*topicOverlap(course.Topics, userTopics) +
+			courseFeedProgressWeight*progressScores[course.ID] +
+			courseFeedFreshnessWeight*freshness(course.CreatedAt, now)
+		if maxPopularity > 0 {
+			score += courseFeedPopularityWeight * math.Log1p(popularity[course.ID]) / math.Log1p(maxPopularity)
+		}
+
+		scores[course.ID] = score
+		feed = append(feed, course)
+	}
+
+	sort.SliceStable(feed, func(i, j int) bool {
+		if scores[feed[i].ID] != scores[feed[j].ID] {
+			return scores[feed[i].ID] > scores[feed[j].ID]
+		}
+		return feed[i].ID < feed[j].ID
+	})
+
+	return feed, nil
+}
+
+// RoadmapFeed ranks the published roadmaps on the user's topics by how well their topics match,
+// likes, verification, how new they are and how close their difficulty is to the user's level.
+// Users without topics get the most liked roadmaps ranked the same way. Authors take turns near
+// the top so one prolific author does not fill the first page
+func (s *FeedService) RoadmapFeed(ctx context.Context, user *domain.User) ([]*domain.Roadmap, error) {
+	topics, err := s.topics.Canonical(ctx, user.Topics)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*domain.Roadmap
+	if len(topics) > 0 {
+		candidates, err = topicCandidates(ctx, topics, s.roadmaps.FindByTopic)
+	} else {
+		var page pagination.Page[*domain.Roadmap]
+		page, err = s.roadmaps.FindRoadmaps(ctx, domain.RoadmapFilter{}, domain.SortOrder{Field: constants.SortLikes, Descending: true}, pagination.Request{First: pagination.MaxFirst})
+		candidates = page.Items
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	maxLikes := 0
+	for _, roadmap := range candidates {
+		maxLikes = max(maxLikes, roadmap.Likes)
+	}
+
+	liked := make(map[string]bool, len(user.Roadmaps))
+	for _, roadmapID := range user.Roadmaps {
+		liked[roadmapID] = true
+	}
+
+	userTopics := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		userTopics[strings.ToLower(topic)] = true
+	}
+
+	now := s.now()
+	scores := make(map[string]float64, len(candidates))
+	feed := make([]*domain.Roadmap, 0, len(candidates))
+	for _, roadmap := range candidates {
+		if roadmap.IsCustom {
+			continue
+		}
+		if _, seen := scores[roadmap.ID]; seen {
+			continue
+		}
+
+		score := roadmapFeedTopicWeight*topicOverlap(roadmap.Topics, userTopics) +
+			roadmapFeedDifficultyWeight*s.difficultyFit(user, roadmap) +
+			roadmapFeedFreshnessWeight*freshness(roadmap.CreatedAt, now)
+		if maxLikes > 0 {
+			score += roadmapFeedLikesWeight * math.Log1p(float64(roadmap.Likes)) / math.Log1p(float64(maxLikes))
+		}
+		if roadmap.Verified {
+			score += roadmapFeedVerifiedWeight
+		}
+
+		roadmap.Liked = liked[roadmap.ID]
+		scores[roadmap.ID] = score
+		feed = append(feed, roadmap)
+	}
+
+	sort.SliceStable(feed, func(i, j int) bool {
+		if scores[feed[i].ID] != scores[feed[j].ID] {
+			return scores[feed[i].ID] > scores[feed[j].ID]
+		}
+		return feed[i].ID < feed[j].ID
+	})
+
+	return diversifyAuthors(feed, scores), nil
+}
+
+// difficultyFit is 1 when the roadmap is at the level the user's skill on its topics points to,
+// half for a level next to it and nothing two levels away. Roadmaps without a difficulty get half
+func (s *FeedService) difficultyFit(user *domain.User, roadmap *domain.Roadmap) float64 {
+	level := difficultyLevel(roadmap.Difficulty)
+	if level < 0 || len(roadmap.Topics) == 0 {
+		return 0.5
+	}
+
+	skill := 0.0
+	for _, topic := range roadmap.Topics {
+		skill += s.skills.Skill(user, topic)
+	}
+	target := difficultyLevel(DifficultyForSkill(skill / float64(len(roadmap.Topics))))
+
+	switch distance := level - target; {
+	case distance == 0:
+		return 1
+	case distance == 1 || distance == -1:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+func difficultyLevel(difficulty string) int {
+	switch {
+	case strings.EqualFold(difficulty, constants.DifficultyBeginner):
+		return 0
+	case strings.EqualFold(difficulty, constants.DifficultyIntermediate):
+		return 1
+	case strings.EqualFold(difficulty, constants.DifficultyAdvanced):
+		return 2
+	default:
+		return -1
+	}
+}
+
+// diversifyAuthors reorders a ranked feed greedily, every pick scaling down the author's
+// remaining roadmaps. The input is sorted by score so each author's best roadmap is its first
+func diversifyAuthors(ranked []*domain.Roadmap, scores map[string]float64) []*domain.Roadmap {
+	author := func(roadmap *domain.Roadmap) string {
+		if roadmap.AuthorId != "" {
+			return roadmap.AuthorId
+		}
+		return roadmap.Author
+	}
+
+	// Queues per author keep the ranked order, only their heads compete
+	var authors []string
+	queues := make(map[string][]*domain.Roadmap)
+	for _, roadmap := range ranked {
+		key := author(roadmap)
+		if _, ok := queues[key]; !ok {
+			authors = append(authors, key)
+		}
+		queues[key] = append(queues[key], roadmap)
+	}
+
+	placed := make(map[string]int, len(authors))
+	diversified := make([]*domain.Roadmap, 0, len(ranked))
+	for len(diversified) < len(ranked) {
+		best := ""
+		bestScore := math.Inf(-1)
+		for _, key := range authors {
+			queue := queues[key]
+			if len(queue) == 0 {
+				continue
+			}
+			score := scores[queue[0].ID] * math.Pow(feedAuthorDecay, float64(placed[key]))
+			if score > bestScore || (score == bestScore && queue[0].ID < queues[best][0].ID) {
+				best, bestScore = key, score
+			}
+		}
+
+		diversified = append(diversified, queues[best][0])
+		queues[best] = queues[best][1:]
+		placed[best]++
+	}
+
+	return diversified
+}
+
+// relatedRoadmaps reads the roadmaps on the user's topics and the roadmaps they follow
+func (s *FeedService) relatedRoadmaps(ctx context.Context, user *domain.User, topics []string) ([]*domain.Roadmap, error) {
+	roadmaps, err := topicCandidates(ctx, topics, s.roadmaps.FindByTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make([]string, 0, len(user.RoadmapsProgress))
+	for roadmapID := range user.RoadmapsProgress {
+		tracked = append(tracked, roadmapID)
+	}
+	sort.Strings(tracked)
+
+	followed, err := s.roadmaps.GetByIDs(ctx, tracked)
+	if err != nil {
+		return nil, err
+	}
+	return append(roadmaps, followed...), nil
+}
+
+// missingCourses batch reads the courses of followed roadmaps that are outside the user's topics
+func (s *FeedService) missingCourses(ctx context.Context, candidates []*domain.Course, progressScores map[string]float64) ([]*domain.Course, error) {
+	known := make(map[string]bool, len(candidates))
+	for _, course := range candidates {
+		known[course.ID] = true
+	}
+
+	ids := make([]string, 0, len(progressScores))
+	for courseID := range progressScores {
+		if !known[courseID] {
+			ids = append(ids, courseID)
+		}
+	}
+	sort.Strings(ids)
+
+	return s.courses.GetByIDs(ctx, ids)
+}
+
+// topicCandidates reads the items tagged with each topic, up to feedCandidatesPerTopic per topic.
+// The same item can come up under several topics
+func topicCandidates[T any](ctx context.Context, topics []string, find func(ctx context.Context, topic string, request pagination.Request) (pagination.Page[T], error)) ([]T, error) {
+	var candidates []T
+	for _, topic := range topics {
+		request := pagination.Request{First: min(feedCandidatesPerTopic, pagination.MaxFirst)}
+		for read := 0; read < feedCandidatesPerTopic; {
+			page, err := find(ctx, topic, request)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, page.Items...)
+			read += len(page.Positions)
+
+			if !page.HasNextPage || len(page.Positions) == 0 {
+				break
+			}
+			request.After = page.NextPosition()
+			request.First = min(feedCandidatesPerTopic-read, pagination.MaxFirst)
+		}
+	}
+	return candidates, nil
+}
+
+// topicOverlap is the share of the item's topics the user follows
+func topicOverlap(topics []string, userTopics map[string]bool) float64 {
+	if len(topics) == 0 {
+		return 0
+	}
+
+	matches := 0
+	for _, topic := range topics {
+		if userTopics[strings.ToLower(topic)] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(topics))
+}
+
+// freshness decays from 1 for something created now, items without a creation date score 0
+func freshness(createdAt, now time.Time) float64 {
+	if createdAt.IsZero() {
+		return 0
+	}
+
+	age := now.Sub(createdAt)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(feedFreshnessHalfLife))
+}
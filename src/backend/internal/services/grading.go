@@ -0,0 +1,226 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 10
+)
+
+// ErrUnscoredGrade is returned when the grader scored none of the rubric criteria. The answer is
+// left ungraded rather than given a made up rating, the learner can submit it again
+var ErrUnscoredGrade = errors.New("grade has no score for any rubric criterion")
+
+// DefaultRubric is used for questions that do not come with their own rubric
+func DefaultRubric() *domain.Rubric {
+	return &domain.Rubric{
+		Criteria: []domain.RubricCriterion{
+			{Name: "Correctness", Description: "The answer is factually and technically correct", Weight: 0.5},
+			{Name: "Completeness", Description: "The answer covers the key points the question asks for", Weight: 0.3},
+			{Name: "Clarity", Description: "The answer is clear, well structured and uses precise terms", Weight: 0.2},
+		},
+	}
+}
+
+// GradingPrompt builds the system prompt asking the model to grade against the rubric and reply with JSON only
+func GradingPrompt(rubric *domain.Rubric) string {
+	var builder strings.Builder
+	builder.WriteString("You grade answers to learning questions. Score the answer from 1 to 10 on each of these criteria:\n")
+	for _, criterion := range rubric.Criteria {
+		builder.WriteString(fmt.Sprintf("- %s: %s\n", criterion.Name, criterion.Description))
+	}
+	if rubric.ReferenceAnswer != "" {
+		builder.WriteString(fmt.Sprintf("A reference answer is: %s\n", rubric.ReferenceAnswer))
+	}
+	builder.WriteString(`Reply only with a JSON object of the form {"insight": "<feedback for the learner>", ` +
+		`"scores": [{"criterion": "<criterion name>", "score": <1-10>, "justification": "<why>"}]}`)
+	return builder.String()
+}
+
+// GradingSchema is the JSON schema of the reply described by GradingPrompt, for models supporting structured output
+func GradingSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "grade",
+			"strict": true,
+			"schema": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"required":             []string{"insight", "scores"},
+				"properties": map[string]interface{}{
+					"insight": map[string]interface{}{"type": "string"},
+					"scores": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type":                 "object",
+							"additionalProperties": false,
+							"required":             []string{"criterion", "score", "justification"},
+							"properties": map[string]interface{}{
+								"criterion":     map[string]interface{}{"type": "string"},
+								"score":         map[string]interface{}{"type": "integer"},
+								"justification": map[string]interface{}{"type": "string"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ParseGrade reads the JSON grade returned by the model, tolerating prose or code fences around it
+func ParseGrade(raw string) (*domain.ChallengeResponse, error) {
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start == -1 || end < start {
+		return nil, errors.New("grade does not contain a JSON object")
+	}
+
+	var grade domain.ChallengeResponse
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &grade); err != nil {
+		return nil, fmt.Errorf("failed to parse grade: %w", err)
+	}
+
+	return &grade, nil
+}
+
+// NormalizeGrade keeps only the scores of the rubric criteria, clamps them to 1-10 and derives
+// the rating as their weighted average. It fails with ErrUnscoredGrade when no criterion is scored
+func NormalizeGrade(response *domain.ChallengeResponse, rubric *domain.Rubric) error {
+	scores := make([]domain.CriterionScore, 0, len(rubric.Criteria))
+	var total, weights float64
+	for _, criterion := range rubric.Criteria {
+		for _, score := range response.Scores {
+			if !strings.EqualFold(strings.TrimSpace(score.Criterion), criterion.Name) {
+				continue
+			}
+
+			score.Criterion = criterion.Name
+			score.Score = ClampRating(score.Score)
+			scores = append(scores, score)
+
+			weight := criterion.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			total += weight * float64(score.Score)
+			weights += weight
+			break
+		}
+	}
+
+	if weights == 0 {
+		return ErrUnscoredGrade
+	}
+
+	response.Scores = scores
+	response.Rating = ClampRating(int(math.Round(total / weights)))
+	return nil
+}
+
+func ClampRating(rating int) int {
+	if rating < MinRating {
+		return MinRating
+	}
+	if rating > MaxRating {
+		return MaxRating
+	}
+	return rating
+}
+
+// insightExtractor follows a streamed JSON grade and hands the decoded characters of its
+// "insight" string to onText as they arrive, so learners never see the raw JSON
+type insightExtractor struct {
+	onText  func(string)
+	buffer  strings.Builder
+	scanned int
+	inValue bool
+	done    bool
+	escaped bool
+	unicode string
+}
+
+func newInsightExtractor(onText func(string)) *insightExtractor {
+	return &insightExtractor{onText: onText}
+}
+
+func (e *insightExtractor) Write(token string) {
+	if e.onText == nil || e.done {
+		return
+	}
+	e.buffer.WriteString(token)
+
+	if !e.inValue {
+		// Wait until the opening quote of the insight value has been received
+		content := e.buffer.String()
+		key := strings.Index(content, `"insight"`)
+		if key == -1 {
+			return
+		}
+		rest := content[key+len(`"insight"`):]
+		trimmed := strings.TrimLeft(rest, " \t\r\n")
+		if !strings.HasPrefix(trimmed, ":") {
+			return
+		}
+		trimmed = strings.TrimLeft(trimmed[1:], " \t\r\n")
+		if !strings.HasPrefix(trimmed, `"`) {
+			return
+		}
+		e.inValue = true
+		e.scanned = len(content) - len(trimmed) + 1
+	}
+
+	content := e.buffer.String()
+	var text strings.Builder
+	for e.scanned < len(content) && !e.done {
+		r, size := utf8.DecodeRuneInString(content[e.scanned:])
+		if r == utf8.RuneError && size == 1 && !utf8.FullRuneInString(content[e.scanned:]) {
+			break // wait for the rest of a split multi-byte character
+		}
+		e.scanned += size
+
+		switch {
+		case e.unicode != "":
+			e.unicode += string(r)
+			if len(e.unicode) == 5 {
+				if code, err := strconv.ParseUint(e.unicode[1:], 16, 32); err == nil {
+					text.WriteRune(rune(code))
+				}
+				e.unicode = ""
+			}
+		case e.escaped:
+			e.escaped = false
+			switch r {
+			case 'n':
+				text.WriteRune('\n')
+			case 't':
+				text.WriteRune('\t')
+			case 'r':
+			case 'u':
+				e.unicode = "u"
+			default:
+				text.WriteRune(r)
+			}
+		case r == '\\':
+			e.escaped = true
+		case r == '"':
+			e.done = true
+		default:
+			text.WriteRune(r)
+		}
+	}
+
+	if text.Len() > 0 {
+		e.onText(text.String())
+	}
+}
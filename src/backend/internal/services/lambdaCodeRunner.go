@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// CodeRunRequest is the payload of the code runner lambda
+type CodeRunRequest struct {
+	Language      string `json:"language"`
+	Code          string `json:"code"`
+	Input         string `json:"input"`
+	TimeLimitMs   int    `json:"timeLimitMs"`
+	MemoryLimitMb int    `json:"memoryLimitMb"`
+}
+
+// LambdaCodeRunner runs programs in the code runner lambda (cmd/coderunner), a function with no
+// IAM permissions and no network egress, so learners' code never runs next to our credentials
+type LambdaCodeRunner struct {
+	client       *lambda.Lambda
+	functionName string
+}
+
+func NewLambdaCodeRunner(sess *session.Session, functionName string) *LambdaCodeRunner {
+	return &LambdaCodeRunner{
+		client:       lambda.New(sess),
+		functionName: functionName,
+	}
+}
+
+func (r *LambdaCodeRunner) Run(ctx context.Context, language, code, input string, limits RunLimits) (*RunResult, error) {
+	if !SupportsLanguage(language) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+
+	payload, err := json.Marshal(CodeRunRequest{
+		Language:      language,
+		Code:          code,
+		Input:         input,
+		TimeLimitMs:   int(limits.Time.Milliseconds()),
+		MemoryLimitMb: limits.MemoryMb,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := r.client.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(r.functionName),
+		Payload:      payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.FunctionError != nil {
+		return nil, fmt.Errorf("code runner failed: %s: %s", aws.StringValue(output.FunctionError), output.Payload)
+	}
+
+	var result RunResult
+	if err := json.Unmarshal(output.Payload, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLeaderboardSize = 10
+	MaxLeaderboardSize     = 100
+)
+
+var leaderboardPeriods = []string{constants.LeaderboardWeekly, constants.LeaderboardMonthly, constants.LeaderboardAllTime}
+
+// LeaderboardService keeps one board per metric, scope and period up to date as users answer
+// challenges and complete roadmaps. Periods follow UTC so every competitor shares the same week
+type LeaderboardService struct {
+	repo repository.ILeaderboardRepository
+	now  func() time.Time
+}
+
+func NewLeaderboardService(repo repository.ILeaderboardRepository) *LeaderboardService {
+	return NewLeaderboardServiceWithClock(repo, time.Now)
+}
+
+func NewLeaderboardServiceWithClock(repo repository.ILeaderboardRepository, now func() time.Time) *LeaderboardService {
+	return &LeaderboardService{repo: repo, now: now}
+}
+
+// RecordChallenge adds the rating to the challenge boards and the user's streak to the streak boards
+func (s *LeaderboardService) RecordChallenge(ctx context.Context, user *domain.User, topic string, rating int) error {
+	if user.LeaderboardOptOut {
+		return nil
+	}
+
+	now := s.now()
+	for _, period := range leaderboardPeriods {
+		if err := s.repo.Increment(ctx, BoardKey(constants.LeaderboardChallenge, "", period, now), user.Name, user.Username, rating); err != nil {
+			return err
+		}
+
+		if topic != "" {
+			if err := s.repo.Increment(ctx, BoardKey(constants.LeaderboardChallenge, topic, period, now), user.Name, user.Username, rating); err != nil {
+				return err
+			}
+		}
+
+		// Streak boards rank the longest streak reached during the period
+		if err := s.repo.SetMax(ctx, BoardKey(constants.LeaderboardStreak, "", period, now), user.Name, user.Username, user.DailyChallengeStreak); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordCompletion counts a completed roadmap on the global board and on the boards of its topics
+func (s *LeaderboardService) RecordCompletion(ctx context.Context, user *domain.User, topics []string) error {
+	if user.LeaderboardOptOut {
+		return nil
+	}
+
+	now := s.now()
+	for _, period := range leaderboardPeriods {
+		for _, topic := range append([]string{""}, topics...) {
+			if err := s.repo.Increment(ctx, BoardKey(constants.LeaderboardCompletions, topic, period, now), user.Name, user.Username, 1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Leaderboard returns the ranked entries of a board. The friends scope ranks the user and their
+// friends on the global board, the topic scope needs a topic
+func (s *LeaderboardService) Leaderboard(ctx context.Context, user *domain.User, metric, scope, topic, period string, limit int) ([]*domain.LeaderboardEntry, error) {
+	switch metric {
+	case constants.LeaderboardStreak, constants.LeaderboardChallenge, constants.LeaderboardCompletions:
+	default:
+		return nil, fmt.Errorf("unknown leaderboard metric %s", metric)
+	}
+
+	switch period {
+	case constants.LeaderboardWeekly, constants.LeaderboardMonthly, constants.LeaderboardAllTime:
+	default:
+		return nil, fmt.Errorf("unknown leaderboard period %s", period)
+	}
+
+	if limit <= 0 {
+		limit = DefaultLeaderboardSize
+	}
+	if limit > MaxLeaderboardSize {
+		limit = MaxLeaderboardSize
+	}
+
+	var entries []*domain.LeaderboardEntry
+	var err error
+	switch scope {
+	case "", constants.LeaderboardGlobal:
+		entries, err = s.repo.GetTop(ctx, BoardKey(metric, "", period, s.now()), limit)
+	case constants.LeaderboardTopic:
+		if topic == "" {
+			return nil, errors.New("topic leaderboards need a topic")
+		}
+		entries, err = s.repo.GetTop(ctx, BoardKey(metric, topic, period, s.now()), limit)
+	case constants.LeaderboardFriends:
+		entries, err = s.repo.GetEntries(ctx, BoardKey(metric, "", period, s.now()), append([]string{user.Name}, user.Friends...))
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Score != entries[j].Score {
+				return entries[i].Score > entries[j].Score
+			}
+			return entries[i].Username < entries[j].Username
+		})
+		if len(entries) > limit {
+			entries = entries[:limit]
+		}
+	default:
+		return nil, fmt.Errorf("unknown leaderboard scope %s", scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Tied scores share a rank
+	for i, entry := range entries {
+		entry.Rank = i + 1
+		if i > 0 && entry.Score == entries[i-1].Score {
+			entry.Rank = entries[i-1].Rank
+		}
+	}
+
+	return entries, nil
+}
+
+// OptOut takes the user off every board, scores start over if they opt back in
+func (s *LeaderboardService) OptOut(ctx context.Context, user *domain.User) error {
+	user.LeaderboardOptOut = true
+	return s.repo.RemoveUser(ctx, user.Name)
+}
+
+// BoardKey names the board of a metric for the period containing t, e.g. challenge#topic:go#2024-W07.
+// An empty topic is the global board
+func BoardKey(metric, topic, period string, t time.Time) string {
+	scope := constants.LeaderboardGlobal
+	if topic != "" {
+		scope = constants.LeaderboardTopic + ":" + strings.ToLower(topic)
+	}
+
+	t = t.UTC()
+	var window string
+	switch period {
+	case constants.LeaderboardWeekly:
+		year, week := t.ISOWeek()
+		window = fmt.Sprintf("%d-W%02d", year, week)
+	case constants.LeaderboardMonthly:
+		window = t.Format("2006-01")
+	default:
+		window = "all"
+	}
+
+	return metric + "#" + scope + "#" + window
+}
@@ -8,8 +8,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -19,46 +17,60 @@ type LMStudioService struct {
 }
 
 func NewLDefaultLMStudioService() *LMStudioService {
-	return &LMStudioService{endpoint: "http://localhost:1234/v1/chat/completions"}
+	return NewLMStudioService("http://localhost:1234/v1/chat/completions")
 }
 
-func (s *LMStudioService) RateQuestion(question, answer string) (domain.ChallengeResponse, error) {
+func NewLMStudioService(endpoint string) *LMStudioService {
+	return &LMStudioService{endpoint: endpoint}
+}
+
+func (s *LMStudioService) RateQuestion(question, answer string, rubric *domain.Rubric) (*domain.ChallengeResponse, error) {
+	return s.RateQuestionStream(question, answer, rubric, nil)
+}
+
+// RateQuestionStream grades the answer against the rubric, handing the insight to onToken as it is written
+func (s *LMStudioService) RateQuestionStream(question, answer string, rubric *domain.Rubric, onToken func(string)) (*domain.ChallengeResponse, error) {
+	if rubric == nil {
+		rubric = DefaultRubric()
+	}
+
 	// Define the request payload
 	payload := map[string]interface{}{
 		"messages": []map[string]string{
-			{"role": "system", "content": "Rate the answer to the following question in a scale from 1 to 10."},
-			{"role": "user", "content": fmt.Sprintf("Question: %s, Answer: %s", question, answer)},
+			{"role": "system", "content": GradingPrompt(rubric)},
+			{"role": "user", "content": fmt.Sprintf("Question: %s\nAnswer: %s", question, answer)},
 		},
-		"temperature": 0.7,
-		"max_tokens":  -1,
-		"stream":      true,
+		"temperature":     0.2,
+		"max_tokens":      -1,
+		"stream":          true,
+		"response_format": GradingSchema(),
 	}
 
-	insight, err := AskLMStudio(payload, s.endpoint)
+	// The model streams JSON, only the insight text is forwarded
+	extractor := newInsightExtractor(onToken)
+	raw, err := StreamLMStudio(payload, s.endpoint, extractor.Write)
 	if err != nil {
-		return domain.ChallengeResponse{}, err
+		return nil, err
 	}
 
-	// Extract the first number found in the insight as the rating
-	re := regexp.MustCompile(`\d+`)
-	rating := 0
-	if match := re.FindString(insight); match != "" {
-		rating, _ = strconv.Atoi(match)
+	grade, err := ParseGrade(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := NormalizeGrade(grade, rubric); err != nil {
+		return nil, err
 	}
 
-	return domain.ChallengeResponse{
-		Question: question,
-		Answer:   answer,
-		Rating:   rating,
-		Insight:  insight,
-	}, nil
+	grade.Question = question
+	grade.Answer = answer
+	return grade, nil
 }
 
-func (s *LMStudioService) GetQuestion(category string) (domain.Problem, error) {
+func (s *LMStudioService) GetQuestion(category, difficulty string) (domain.Problem, error) {
 	// Define the request payload
 	payload := map[string]interface{}{
 		"messages": []map[string]string{
-			{"role": "user", "content": fmt.Sprintf("Give me a question for the following category: %s", category)},
+			{"role": "user", "content": fmt.Sprintf("Give me a %s level question for the following category: %s", strings.ToLower(difficulty), category)},
 		},
 		"temperature": 0.7,
 		"max_tokens":  -1,
@@ -74,10 +86,18 @@ func (s *LMStudioService) GetQuestion(category string) (domain.Problem, error) {
 		Question:   question,
 		Categories: append(make([]string, 0), category),
 		Type:       "LLM Asking",
+		Difficulty: difficulty,
+		Rubric:     DefaultRubric(),
 	}, nil
 }
 
 func AskLMStudio(payload map[string]interface{}, endpoint string) (string, error) {
+	return StreamLMStudio(payload, endpoint, nil)
+}
+
+// StreamLMStudio sends a streamed completion request and returns the whole content,
+// calling onToken (when not nil) with each content delta as soon as it is received
+func StreamLMStudio(payload map[string]interface{}, endpoint string, onToken func(string)) (string, error) {
 	// Convert payload to JSON
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
@@ -100,6 +120,7 @@ func AskLMStudio(payload map[string]interface{}, endpoint string) (string, error
 			if err == io.EOF || err.Error() == "unexpected end of JSON input" {
 				break
 			}
+			return "", err
 		}
 
 		line = strings.TrimPrefix(line, "data: ")
@@ -108,6 +129,11 @@ func AskLMStudio(payload map[string]interface{}, endpoint string) (string, error
 			continue
 		}
 
+		// OpenAI compatible servers close the stream with a sentinel
+		if line == "[DONE]" {
+			break
+		}
+
 		// Parse the JSON line
 		var result map[string]interface{}
 		if err := json.Unmarshal([]byte(line), &result); err != nil {
@@ -119,6 +145,9 @@ func AskLMStudio(payload map[string]interface{}, endpoint string) (string, error
 			if delta, ok := choices[0].(map[string]interface{})["delta"].(map[string]interface{}); ok {
 				if content, ok := delta["content"].(string); ok {
 					builder.WriteString(content)
+					if onToken != nil {
+						onToken(content)
+					}
 				}
 			}
 		}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/repository"
+	"context"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Questions at least this similar to one already in the bank are treated as duplicates
+	duplicateSimilarity = 0.7
+	// New questions are compared with this many of the newest of their topic and difficulty
+	duplicateWindow = 200
+
+	// Questions drawn in one go, the first the user has not seen is served
+	drawCandidates = 25
+	// Questions looked at by one draw before a new one is generated instead
+	drawMaxCandidates = 500
+)
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "what": true, "how": true, "why": true,
+	"does": true, "you": true, "can": true, "with": true, "between": true, "which": true, "when": true,
+	"this": true, "that": true, "its": true, "your": true, "into": true, "from": true, "explain": true,
+}
+
+type QuestionBankService struct {
+	repo   repository.IQuestionRepository
+	seen   repository.ISeenQuestionRepository
+	topics *TopicResolver
+}
+
+func NewQuestionBankService(repo repository.IQuestionRepository, seen repository.ISeenQuestionRepository, topics *TopicResolver) *QuestionBankService {
+	return &QuestionBankService{repo: repo, seen: seen, topics: topics}
+}
+
+// Draw returns a random question of the topic and difficulty the user has not seen yet. Runs
+// of candidates are read on from a random start until one is new to the user, the pool has been
+// gone through or drawMaxCandidates were looked at. It returns nil when none was found
+func (s *QuestionBankService) Draw(ctx context.Context, user *domain.User, topic, difficulty string) (*domain.Question, error) {
+	start := uuid.NewString()
+	considered := make(map[string]bool)
+	for len(considered) < drawMaxCandidates {
+		questions, err := s.repo.Sample(ctx, topic, difficulty, start, drawCandidates)
+		if err != nil {
+			return nil, err
+		}
+
+		// Sampling wraps around, a run holding no new question went round the whole pool
+		var candidates []*domain.Question
+		for _, question := range questions {
+			if !considered[question.ID] {
+				considered[question.ID] = true
+				candidates = append(candidates, question)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+
+		ids := make([]string, len(candidates))
+		for i, question := range candidates {
+			ids[i] = question.ID
+		}
+		seen, err := s.seen.GetSeen(ctx, user.Name, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, question := range candidates {
+			if !seen[question.ID] {
+				return question, nil
+			}
+		}
+
+		// The next run starts right after the last key of this one
+		last := questions[len(questions)-1].DrawKey
+		if last == "" {
+			return nil, nil
+		}
+		start = last + "\x00"
+	}
+
+	return nil, nil
+}
+
+// Add stores the question unless a similar one is among the newest of its topic and difficulty.
+// It returns the stored question, or the existing one together with false when it was a duplicate
+func (s *QuestionBankService) Add(ctx context.Context, question *domain.Question) (*domain.Question, bool, error) {
+	// Questions are filed under resolved topics, so every name of a topic draws from one pool
+	topics, err := s.topics.Resolve(ctx, []string{question.Topic})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(topics) > 0 {
+		question.Topic = topics[0]
+	}
+
+	existing, err := s.repo.GetRecent(ctx, question.Topic, question.Difficulty, duplicateWindow)
+	if err != nil {
+		return nil, false, err
+	}
+
+	words := questionWords(question.Question)
+	for _, other := range existing {
+		if similarity(words, questionWords(other.Question)) >= duplicateSimilarity {
+			return other, false, nil
+		}
+	}
+
+	if question.ID == "" {
+		question.ID = uuid.NewString()
+	}
+	if question.CreatedAt.IsZero() {
+		question.CreatedAt = time.Now()
+	}
+
+	if err := s.repo.Insert(ctx, question); err != nil {
+		return nil, false, err
+	}
+
+	return question, true, nil
+}
+
+func (s *QuestionBankService) GetByID(ctx context.Context, questionID string) (*domain.Question, error) {
+	return s.repo.GetByID(ctx, questionID)
+}
+
+// MarkSeen records that the user was served the question
+func (s *QuestionBankService) MarkSeen(ctx context.Context, username, questionID string) error {
+	return s.seen.MarkSeen(ctx, username, questionID)
+}
+
+func questionWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) > 2 && !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// similarity is the Jaccard index of the two word sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+
+	intersection := 0
+	for word := range a {
+		if b[word] {
+			intersection++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// memoryQuestions samples questions in draw key order from start, wrapping around like the
+// draw-index does
+type memoryQuestions struct {
+	questions []*domain.Question
+}
+
+func (m *memoryQuestions) Insert(ctx context.Context, question *domain.Question) error {
+	m.questions = append(m.questions, question)
+	return nil
+}
+
+func (m *memoryQuestions) GetByID(ctx context.Context, questionID string) (*domain.Question, error) {
+	return nil, nil
+}
+
+func (m *memoryQuestions) Sample(ctx context.Context, topic, difficulty, start string, limit int) ([]*domain.Question, error) {
+	sort.Slice(m.questions, func(i, j int) bool { return m.questions[i].DrawKey < m.questions[j].DrawKey })
+	first := sort.Search(len(m.questions), func(i int) bool { return m.questions[i].DrawKey >= start })
+
+	var sample []*domain.Question
+	for i := 0; i < len(m.questions) && len(sample) < limit; i++ {
+		sample = append(sample, m.questions[(first+i)%len(m.questions)])
+	}
+	return sample, nil
+}
+
+func (m *memoryQuestions) GetRecent(ctx context.Context, topic, difficulty string, limit int) ([]*domain.Question, error) {
+	return nil, nil
+}
+
+type memorySeen map[string]bool
+
+func (m memorySeen) MarkSeen(ctx context.Context, username, questionID string) error {
+	m[questionID] = true
+	return nil
+}
+
+func (m memorySeen) GetSeen(ctx context.Context, username string, questionIDs []string) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	for _, id := range questionIDs {
+		seen[id] = m[id]
+	}
+	return seen, nil
+}
+
+func newTestQuestionBank(count int, seen memorySeen) *QuestionBankService {
+	questions := &memoryQuestions{}
+	for i := 0; i < count; i++ {
+		// Keys spread over the range random starts fall in
+		key := fmt.Sprintf("%x", i*16/count)
+		questions.questions = append(questions.questions, &domain.Question{ID: fmt.Sprintf("q%d", i), DrawKey: fmt.Sprintf("%s-%04d", key, i)})
+	}
+	return NewQuestionBankService(questions, seen, nil)
+}
+
+func TestDrawReadsPastSeenQuestions(t *testing.T) {
+	seen := memorySeen{}
+	for i := 0; i < 99; i++ {
+		if i != 42 {
+			seen[fmt.Sprintf("q%d", i)] = true
+		}
+	}
+
+	question, err := newTestQuestionBank(99, seen).Draw(context.Background(), &domain.User{Name: "ada"}, "Go", "easy")
+	if err != nil {
+		t.Fatalf("Draw: %v", err)
+	}
+	if question == nil || question.ID != "q42" {
+		t.Errorf("got %+v, want the only unseen question q42", question)
+	}
+}
+
+func TestDrawReturnsNothingWhenEveryQuestionWasSeen(t *testing.T) {
+	seen := memorySeen{}
+	for i := 0; i < 60; i++ {
+		seen[fmt.Sprintf("q%d", i)] = true
+	}
+
+	question, err := newTestQuestionBank(60, seen).Draw(context.Background(), &domain.User{Name: "ada"}, "Go", "easy")
+	if err != nil {
+		t.Fatalf("Draw: %v", err)
+	}
+	if question != nil {
+		t.Errorf("got %+v, want none", question)
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// Cursors into a ranking stay valid this long after its first page
+	rankingSnapshotTTL = 24 * time.Hour
+	// Rankings are cut to this many items, well past what anyone scrolls through
+	MaxRankedItems = 1000
+)
+
+// RankingPager pages rankings that are computed per request, such as feeds and trending lists.
+// Cutting them by offset would skip or repeat items as their scores move between requests, so
+// the first page saves the ranked ids as a snapshot and the later pages are cut from it
+type RankingPager struct {
+	snapshots repository.IRankingSnapshotRepository
+}
+
+func NewRankingPager(snapshots repository.IRankingSnapshotRepository) *RankingPager {
+	return &RankingPager{snapshots: snapshots}
+}
+
+// PageRanking returns the requested page of a ranking. rank computes the ranking for the first
+// page only, load reads the items of later pages by id, in order, leaving out deleted ones
+func PageRanking[T any](ctx context.Context, pager *RankingPager, request pagination.Request, rank func(ctx context.Context) ([]T, error), id func(T) string, load func(ctx context.Context, ids []string) ([]T, error)) (pagination.Page[T], error) {
+	limit := request.Limit()
+
+	if request.After.IsZero() {
+		items, err := rank(ctx)
+		if err != nil {
+			return pagination.Page[T]{}, err
+		}
+		if len(items) > MaxRankedItems {
+			items = items[:MaxRankedItems]
+		}
+
+		snapshot := &domain.RankingSnapshot{}
+		if len(items) > limit {
+			snapshot.ID = uuid.NewString()
+			snapshot.ExpiresAt = time.Now().Add(rankingSnapshotTTL).Unix()
+			snapshot.IDs = make([]string, len(items))
+			for i, item := range items {
+				snapshot.IDs[i] = id(item)
+			}
+			if err := pager.snapshots.Insert(ctx, snapshot); err != nil {
+				return pagination.Page[T]{}, err
+			}
+		}
+
+		total := len(items)
+		page := pagination.Page[T]{
+			Items:       items[:min(limit, total)],
+			HasNextPage: total > limit,
+			TotalCount:  &total,
+		}
+		page.Positions = make([]pagination.Position, len(page.Items))
+		for i := range page.Items {
+			page.Positions[i] = pagination.Position{Snapshot: snapshot.ID, Offset: i + 1}
+		}
+		return page, nil
+	}
+
+	if request.After.Snapshot == "" {
+		return pagination.Page[T]{}, pagination.ErrInvalidCursor
+	}
+	snapshot, err := pager.snapshots.Get(ctx, request.After.Snapshot)
+	if err != nil {
+		return pagination.Page[T]{}, err
+	}
+	if snapshot == nil {
+		return pagination.Page[T]{}, pagination.ErrExpiredCursor
+	}
+
+	start := min(request.After.Offset, len(snapshot.IDs))
+	end := min(start+limit, len(snapshot.IDs))
+	items, err := load(ctx, snapshot.IDs[start:end])
+	if err != nil {
+		return pagination.Page[T]{}, err
+	}
+
+	// Positions count the snapshot's ids, items deleted since do not shift the next page
+	offsets := make(map[string]int, end-start)
+	for i, itemID := range snapshot.IDs[start:end] {
+		offsets[itemID] = start + i + 1
+	}
+	total := len(snapshot.IDs)
+	page := pagination.Page[T]{
+		Items:       items,
+		Positions:   make([]pagination.Position, len(items)),
+		HasNextPage: end < total,
+		TotalCount:  &total,
+	}
+	for i, item := range items {
+		page.Positions[i] = pagination.Position{Snapshot: snapshot.ID, Offset: offsets[id(item)]}
+	}
+	return page, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// SimilarRoadmapsPerRoadmap is how many neighbours the job keeps for every roadmap
+	SimilarRoadmapsPerRoadmap = 20
+	DefaultRecommendations    = 10
+
+	// A like counts fully, following a roadmap counts half plus half the share of it completed
+	likeStrength     = 1.0
+	progressStrength = 0.5
+
+	// Only the strongest interactions of a learner form pairs, keeping the pair count bounded
+	maxInteractionsPerUser = 200
+
+	// Pairs shared by few learners are pulled towards zero, one common learner is no evidence
+	similarityShrinkage = 5.0
+
+	userPageSize = 100
+)
+
+// RecommendationService computes item-item similarities between roadmaps from what learners
+// like and follow, and recommends from them
+type RecommendationService struct {
+	users        repository.IUserRepository
+	roadmaps     repository.IRoadmapRepository
+	similarities repository.IRoadmapSimilarityRepository
+	now          func() time.Time
+}
+
+func NewRecommendationService(users repository.IUserRepository, roadmaps repository.IRoadmapRepository, similarities repository.IRoadmapSimilarityRepository) *RecommendationService {
+	return &RecommendationService{
+		users:        users,
+		roadmaps:     roadmaps,
+		similarities: similarities,
+		now:          time.Now,
+	}
+}
+
+type roadmapPair struct {
+	first, second string
+}
+
+type pairStats struct {
+	dot    float64
+	common int
+}
+
+// ComputeSimilarities rebuilds the similar roadmaps of every published roadmap with the
+// shrunk cosine similarity of their learners, and returns how many roadmaps it wrote
+func (s *RecommendationService) ComputeSimilarities(ctx context.Context) (int, error) {
+	roadmaps, err := s.roadmaps.GetAllRoadmaps(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	published := make(map[string]*domain.Roadmap, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		if !roadmap.IsCustom {
+			published[roadmap.ID] = roadmap
+		}
+	}
+
+	norms := make(map[string]float64, len(published))
+	pairs := make(map[roadmapPair]*pairStats)
+
+	request := pagination.Request{First: userPageSize}
+	for {
+		page, err := s.users.ListUsers(ctx, request)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, user := range page.Items {
+			strengths := interactionStrengths(user, published)
+			ids := strongestInteractions(strengths, maxInteractionsPerUser)
+
+			for i, first := range ids {
+				norms[first] += strengths[first] * strengths[first]
+				for _, second := range ids[i+1:] {
+					pair := roadmapPair{first, second}
+					if second < first {
+						pair = roadmapPair{second, first}
+					}
+					stats := pairs[pair]
+					if stats == nil {
+						stats = &pairStats{}
+						pairs[pair] = stats
+					}
+					stats.dot += strengths[first] * strengths[second]
+					stats.common++
+				}
+			}
+		}
+
+		if !page.HasNextPage {
+			break
+		}
+		request.After = page.NextPosition()
+	}
+
+	neighbours := make(map[string][]domain.SimilarRoadmap, len(published))
+	for pair, stats := range pairs {
+		score := stats.dot / math.Sqrt(norms[pair.first]*norms[pair.second])
+		score *= float64(stats.common) / (float64(stats.common) + similarityShrinkage)
+
+		neighbours[pair.first] = append(neighbours[pair.first], domain.SimilarRoadmap{RoadmapID: pair.second, Score: score})
+		neighbours[pair.second] = append(neighbours[pair.second], domain.SimilarRoadmap{RoadmapID: pair.first, Score: score})
+	}
+
+	// Every published roadmap is written, so one that lost its learners does not keep stale neighbours
+	computedAt := s.now().UTC()
+	written := 0
+	for roadmapID := range published {
+		similar := neighbours[roadmapID]
+		sortSimilar(similar)
+		if len(similar) > SimilarRoadmapsPerRoadmap {
+			similar = similar[:SimilarRoadmapsPerRoadmap]
+		}
+
+		if err := s.similarities.Upsert(ctx, &domain.RoadmapSimilarity{
+			RoadmapID:  roadmapID,
+			Similar:    similar,
+			ComputedAt: computedAt,
+		}); err != nil {
+			return written, err
+		}
+		written++
+	}
+
+	return written, nil
+}
+
+// SimilarRoadmaps returns the roadmaps most followed by the learners of the roadmap
+func (s *RecommendationService) SimilarRoadmaps(ctx context.Context, roadmapID string, limit int) ([]*domain.Roadmap, error) {
+	similarity, err := s.similarities.Get(ctx, roadmapID)
+	if err != nil || similarity == nil {
+		return []*domain.Roadmap{}, err
+	}
+
+	similar := similarity.Similar
+	if len(similar) > recommendationLimit(limit) {
+		similar = similar[:recommendationLimit(limit)]
+	}
+
+	ids := make([]string, len(similar))
+	for i, neighbour := range similar {
+		ids[i] = neighbour.RoadmapID
+	}
+	return s.loadRoadmaps(ctx, ids), nil
+}
+
+// AlsoFollowed recommends the roadmaps learners like the user followed: the neighbours of the
+// roadmaps the user liked or follows, weighted by how strongly the user took to each of them
+func (s *RecommendationService) AlsoFollowed(ctx context.Context, user *domain.User, limit int) ([]*domain.Roadmap, error) {
+	strengths := interactionStrengths(user, nil)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var lookupErr error
+	scores := make(map[string]float64)
+	for roadmapID, strength := range strengths {
+		wg.Add(1)
+		go func(roadmapID string, strength float64) {
+			defer wg.Done()
+			similarity, err := s.similarities.Get(ctx, roadmapID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lookupErr = err
+				return
+			}
+			if similarity == nil {
+				return
+			}
+			for _, neighbour := range similarity.Similar {
+				scores[neighbour.RoadmapID] += strength * neighbour.Score
+			}
+		}(roadmapID, strength)
+	}
+	wg.Wait()
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	candidates := make([]domain.SimilarRoadmap, 0, len(scores))
+	for roadmapID, score := range scores {
+		if _, seen := strengths[roadmapID]; !seen {
+			candidates = append(candidates, domain.SimilarRoadmap{RoadmapID: roadmapID, Score: score})
+		}
+	}
+	sortSimilar(candidates)
+	if len(candidates) > recommendationLimit(limit) {
+		candidates = candidates[:recommendationLimit(limit)]
+	}
+
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.RoadmapID
+	}
+	return s.loadRoadmaps(ctx, ids), nil
+}
+
+// loadRoadmaps fetches the roadmaps in the given order, dropping those deleted since the job ran
+func (s *RecommendationService) loadRoadmaps(ctx context.Context, ids []string) []*domain.Roadmap {
+	roadmaps := make([]*domain.Roadmap, len(ids))
+	var wg sync.WaitGroup
+	for i, roadmapID := range ids {
+		wg.Add(1)
+		go func(i int, roadmapID string) {
+			defer wg.Done()
+			roadmap, err := s.roadmaps.GetRoadmap(ctx, roadmapID)
+			if err != nil {
+				log.Printf("Skipping recommended roadmap %s: %v", roadmapID, err)
+				return
+			}
+			roadmaps[i] = roadmap
+		}(i, roadmapID)
+	}
+	wg.Wait()
+
+	found := make([]*domain.Roadmap, 0, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		if roadmap != nil {
+			found = append(found, roadmap)
+		}
+	}
+	return found
+}
+
+// interactionStrengths scores the user's likes and followed roadmaps. With a catalog, roadmaps
+// outside it are dropped and progress counts by the share of courses completed
+func interactionStrengths(user *domain.User, catalog map[string]*domain.Roadmap) map[string]float64 {
+	strengths := make(map[string]float64, len(user.Roadmaps)+len(user.RoadmapsProgress))
+	for _, roadmapID := range user.Roadmaps {
+		strengths[roadmapID] = likeStrength
+	}
+
+	for roadmapID, progress := range user.RoadmapsProgress {
+		completed := 0.0
+		if roadmap, ok := catalog[roadmapID]; ok && len(roadmap.CourseIDs) > 0 {
+			completed = math.Min(1, float64(progress)/float64(len(roadmap.CourseIDs)))
+		}
+		strengths[roadmapID] += progressStrength * (1 + completed)
+	}
+
+	if catalog != nil {
+		for roadmapID := range strengths {
+			if _, ok := catalog[roadmapID]; !ok {
+				delete(strengths, roadmapID)
+			}
+		}
+	}
+	return strengths
+}
+
+// strongestInteractions returns up to limit roadmap ids, strongest first
+func strongestInteractions(strengths map[string]float64, limit int) []string {
+	ids := make([]string, 0, len(strengths))
+	for roadmapID := range strengths {
+		ids = append(ids, roadmapID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if strengths[ids[i]] != strengths[ids[j]] {
+			return strengths[ids[i]] > strengths[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids
+}
+
+func sortSimilar(similar []domain.SimilarRoadmap) {
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].RoadmapID < similar[j].RoadmapID
+	})
+}
+
+func recommendationLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultRecommendations
+	}
+	return min(limit, SimilarRoadmapsPerRoadmap)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	MinReviewGrade = 0
+	MaxReviewGrade = 5
+
+	initialEaseFactor = 2.5
+	minEaseFactor     = 1.3
+)
+
+// ReviewScheduler schedules review cards with the SM-2 algorithm. Grades go from 0 (blackout)
+// to 5 (perfect recall), anything below 3 counts as a lapse
+type ReviewScheduler struct {
+	now func() time.Time
+}
+
+func NewReviewScheduler() *ReviewScheduler {
+	return NewReviewSchedulerWithClock(time.Now)
+}
+
+func NewReviewSchedulerWithClock(now func() time.Time) *ReviewScheduler {
+	return &ReviewScheduler{now: now}
+}
+
+// NewCard creates a card that is first due the day after the question was answered
+func (s *ReviewScheduler) NewCard(username, source, questionID, question, answer, topic string) *domain.ReviewCard {
+	now := s.now()
+	return &domain.ReviewCard{
+		Username:     username,
+		CardID:       CardID(questionID, question),
+		Source:       source,
+		QuestionID:   questionID,
+		Question:     question,
+		Answer:       answer,
+		Topic:        topic,
+		EaseFactor:   initialEaseFactor,
+		IntervalDays: 1,
+		Due:          now.AddDate(0, 0, 1),
+		CreatedAt:    now,
+	}
+}
+
+// Review reschedules the card after it was recalled with the given grade
+func (s *ReviewScheduler) Review(card *domain.ReviewCard, grade int) error {
+	if grade < MinReviewGrade || grade > MaxReviewGrade {
+		return errors.New("review grade must be between 0 and 5")
+	}
+
+	if grade < 3 {
+		card.Repetitions = 0
+		card.IntervalDays = 1
+		card.Lapses++
+	} else {
+		switch card.Repetitions {
+		case 0:
+			card.IntervalDays = 1
+		case 1:
+			card.IntervalDays = 6
+		default:
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.EaseFactor))
+		}
+		card.Repetitions++
+	}
+
+	quality := float64(MaxReviewGrade - grade)
+	card.EaseFactor = math.Max(minEaseFactor, card.EaseFactor+0.1-quality*(0.08+quality*0.02))
+
+	now := s.now()
+	card.LastReviewedAt = now
+	card.Due = now.AddDate(0, 0, card.IntervalDays)
+
+	return nil
+}
+
+// EndOfToday is the moment until which cards count as due today in the given location
+func (s *ReviewScheduler) EndOfToday(location *time.Location) time.Time {
+	now := s.now().In(location)
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, location)
+}
+
+// CardID identifies a card by its bank question, or by its text for questions outside the bank
+func CardID(questionID, question string) string {
+	if questionID != "" {
+		return questionID
+	}
+
+	hash := sha1.Sum([]byte(strings.ToLower(strings.TrimSpace(question))))
+	return hex.EncodeToString(hash[:8])
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"backend/internal/search"
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+const reindexPageSize = 100
+
+// SearchService keeps the search index in step with the course and roadmap tables and turns
+// hits back into the stored items
+type SearchService struct {
+	index    search.Index
+	courses  repository.ICourseRepository
+	roadmaps repository.IRoadmapRepository
+
+	// A memory index starts empty in every process and is filled from the tables on first use.
+	// A failed load is tried again by the next search
+	loadMu sync.Mutex
+	loaded bool
+}
+
+func NewSearchService(index search.Index, courses repository.ICourseRepository, roadmaps repository.IRoadmapRepository) *SearchService {
+	return &SearchService{
+		index:    index,
+		courses:  courses,
+		roadmaps: roadmaps,
+	}
+}
+
+func (s *SearchService) IndexCourse(ctx context.Context, course *domain.Course) error {
+	return s.index.Upsert(ctx, search.CourseDocument(course))
+}
+
+// IndexRoadmap indexes published roadmaps, custom ones belong to the learner who asked for them
+func (s *SearchService) IndexRoadmap(ctx context.Context, roadmap *domain.Roadmap) error {
+	if roadmap.IsCustom {
+		return nil
+	}
+	return s.index.Upsert(ctx, search.RoadmapDocument(roadmap))
+}
+
+// Reindex writes every course and roadmap to the index again
+func (s *SearchService) Reindex(ctx context.Context) (int, error) {
+	indexed := 0
+
+	request := pagination.Request{First: reindexPageSize}
+	for {
+		page, err := s.courses.GetAllCourses(ctx, request)
+		if err != nil {
+			return indexed, err
+		}
+		for _, course := range page.Items {
+			if err := s.IndexCourse(ctx, course); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+
+		if !page.HasNextPage {
+			break
+		}
+		request.After = page.NextPosition()
+	}
+
+	roadmaps, err := s.roadmaps.GetAllRoadmaps(ctx)
+	if err != nil {
+		return indexed, err
+	}
+	for _, roadmap := range roadmaps {
+		if roadmap.IsCustom {
+			continue
+		}
+		if err := s.IndexRoadmap(ctx, roadmap); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+
+	return indexed, nil
+}
+
+// Search ranks courses and roadmaps by relevance to the query over their title, description,
+// author and topics. A query without text lists what passes the filters. Items deleted since
+// they were indexed are left out
+func (s *SearchService) Search(ctx context.Context, query search.Query) ([]*domain.SearchResult, error) {
+	query = query.Normalize()
+	if query.Empty() {
+		return nil, errors.New("search needs a query or a filter")
+	}
+
+	if err := s.loadMemoryIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	hits, err := s.index.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*domain.SearchResult, len(hits))
+	var wg sync.WaitGroup
+	for i, hit := range hits {
+		wg.Add(1)
+		go func(i int, hit search.Hit) {
+			defer wg.Done()
+
+			var err error
+			result := &domain.SearchResult{Kind: hit.Kind, Score: hit.Score}
+			switch hit.Kind {
+			case search.KindCourse:
+				result.Course, err = s.courses.GetCourseByID(ctx, hit.ID)
+			case search.KindRoadmap:
+				result.Roadmap, err = s.roadmaps.GetRoadmap(ctx, hit.ID)
+			}
+			if err != nil {
+				log.Printf("Skipping search hit %s %s: %v", hit.Kind, hit.ID, err)
+				return
+			}
+			if result.Course != nil || result.Roadmap != nil {
+				results[i] = result
+			}
+		}(i, hit)
+	}
+	wg.Wait()
+
+	found := make([]*domain.SearchResult, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			found = append(found, result)
+		}
+	}
+	return found, nil
+}
+
+// loadMemoryIndex fills an empty memory index from the tables, other indexes are kept up to date
+// by the searchindex lambda
+func (s *SearchService) loadMemoryIndex(ctx context.Context) error {
+	memory, ok := s.index.(*search.MemoryIndex)
+	if !ok {
+		return nil
+	}
+
+	s.loadMu.Lock()
+	defer s.loadMu.Unlock()
+
+	if s.loaded || memory.Len() > 0 {
+		s.loaded = true
+		return nil
+	}
+	if _, err := s.Reindex(ctx); err != nil {
+		return err
+	}
+	s.loaded = true
+	return nil
+}
@@ -1,12 +1,35 @@
 package services
 
-import "backend/internal/domain"
+import (
+	"backend/internal/domain"
+	"backend/internal/events"
+	"context"
+)
 
 type IDailyChallengeService interface {
-	GetQuestion(username string) (domain.Problem, error)
-	RateQuestion(question, answer string) (*domain.ChallengeResponse, error)
+	GetQuestion(category, difficulty string) (domain.Problem, error)
+	RateQuestion(question, answer string, rubric *domain.Rubric) (*domain.ChallengeResponse, error)
+	RateQuestionStream(question, answer string, rubric *domain.Rubric, onToken func(string)) (*domain.ChallengeResponse, error)
 }
 
 type IRoadmapService interface {
 	GetCustomRoadmap(topic string) (*domain.Roadmap, error)
 }
+
+type ICourseEnrichmentService interface {
+	Enrich(ctx context.Context, course *domain.Course) error
+	EnrichAll(ctx context.Context, courses []domain.Course) []domain.Course
+}
+
+type ILinkChecker interface {
+	CheckLink(ctx context.Context, url string) (int, error)
+}
+
+type IChallengeFeedbackPublisher interface {
+	Publish(ctx context.Context, feedback domain.ChallengeFeedback) error
+}
+
+// IEventSubscriber subscribes its handlers to the events it reacts to
+type IEventSubscriber interface {
+	Register(bus events.Subscriber)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSkill is the Elo rating of a topic the learner has not been challenged on yet
+	DefaultSkill = 1200.0
+	skillKFactor = 32.0
+)
+
+// SkillService keeps an Elo-style skill estimate per user and topic, treating every challenge
+// as a match between the learner and a question rated by its difficulty
+type SkillService struct {
+	rnd *rand.Rand
+}
+
+func NewSkillService() *SkillService {
+	return NewSkillServiceWithRand(rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+func NewSkillServiceWithRand(rnd *rand.Rand) *SkillService {
+	return &SkillService{rnd: rnd}
+}
+
+func (s *SkillService) Skill(user *domain.User, topic string) float64 {
+	if skill, ok := user.TopicSkills[topic]; ok {
+		return skill
+	}
+	return DefaultSkill
+}
+
+// UpdateSkill moves the user's skill on the topic after a challenge of the given difficulty was rated 1-10
+func (s *SkillService) UpdateSkill(user *domain.User, topic, difficulty string, rating int) {
+	if user.TopicSkills == nil {
+		user.TopicSkills = make(map[string]float64)
+	}
+
+	skill := s.Skill(user, topic)
+	expected := 1 / (1 + math.Pow(10, (difficultyRating(difficulty)-skill)/400))
+	score := float64(ClampRating(rating)-MinRating) / float64(MaxRating-MinRating)
+
+	user.TopicSkills[topic] = math.Round(skill + skillKFactor*(score-expected))
+}
+
+// TargetDifficulty is the question difficulty matching the user's current level on the topic
+func (s *SkillService) TargetDifficulty(user *domain.User, topic string) string {
+	return DifficultyForSkill(s.Skill(user, topic))
+}
+
+// DifficultyForSkill is the difficulty matching a skill rating
+func DifficultyForSkill(skill float64) string {
+	switch {
+	case skill < 1150:
+		return constants.DifficultyBeginner
+	case skill < 1450:
+		return constants.DifficultyIntermediate
+	default:
+		return constants.DifficultyAdvanced
+	}
+}
+
+// PickTopic draws one of the user's topics at random, the weaker the user is on a topic the likelier it gets picked
+func (s *SkillService) PickTopic(user *domain.User) (string, error) {
+	if len(user.Topics) == 0 {
+		return "", errors.New("user has no topics to be challenged on")
+	}
+
+	weights := make([]float64, len(user.Topics))
+	total := 0.0
+	for i, topic := range user.Topics {
+		// Logistic weight, topics well below the default skill get close to the full weight
+		weights[i] = 1 / (1 + math.Exp((s.Skill(user, topic)-DefaultSkill)/200))
+		total += weights[i]
+	}
+
+	target := s.rnd.Float64() * total
+	for i, weight := range weights {
+		target -= weight
+		if target < 0 {
+			return user.Topics[i], nil
+		}
+	}
+
+	return user.Topics[len(user.Topics)-1], nil
+}
+
+func difficultyRating(difficulty string) float64 {
+	switch {
+	case strings.EqualFold(difficulty, constants.DifficultyBeginner):
+		return 1000
+	case strings.EqualFold(difficulty, constants.DifficultyAdvanced):
+		return 1600
+	default:
+		return 1300
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"time"
+	_ "time/tzdata" // the Lambda runtime ships without a timezone database
+)
+
+// StreakService computes daily challenge streaks on calendar days in the user's own timezone
+type StreakService struct {
+	now func() time.Time
+}
+
+func NewStreakService() *StreakService {
+	return NewStreakServiceWithClock(time.Now)
+}
+
+func NewStreakServiceWithClock(now func() time.Time) *StreakService {
+	return &StreakService{now: now}
+}
+
+// Record counts a qualifying challenge for today. A streak continues when the previous one was
+// yesterday, missed days are covered by streak freezes when the user has enough of them, and
+// anything else starts a new streak
+func (s *StreakService) Record(user *domain.User) {
+	location := UserLocation(user)
+	now := s.now()
+
+	if user.LastDailyChallenge.IsZero() {
+		user.DailyChallengeStreak = 1
+	} else {
+		days := calendarDaysBetween(user.LastDailyChallenge.In(location), now.In(location))
+		switch {
+		case days <= 0:
+			// Already counted today
+			if user.DailyChallengeStreak == 0 {
+				user.DailyChallengeStreak = 1
+			}
+			user.LastDailyChallenge = now.UTC()
+			return
+		case days == 1:
+			user.DailyChallengeStreak++
+		case user.StreakFreezes >= days-1:
+			user.StreakFreezes -= days - 1
+			user.DailyChallengeStreak++
+		default:
+			user.DailyChallengeStreak = 1
+		}
+	}
+
+	user.LastDailyChallenge = now.UTC()
+
+	if user.DailyChallengeStreak > user.LongestStreak {
+		user.LongestStreak = user.DailyChallengeStreak
+	}
+
+	// Every full week of streak earns a freeze
+	if user.DailyChallengeStreak%constants.StreakFreezeInterval == 0 && user.StreakFreezes < constants.MaxStreakFreezes {
+		user.StreakFreezes++
+	}
+}
+
+// Current returns the streak as it stands now, which is zero once it can no longer be continued
+func (s *StreakService) Current(user *domain.User) int {
+	if user.LastDailyChallenge.IsZero() {
+		return 0
+	}
+
+	location := UserLocation(user)
+	days := calendarDaysBetween(user.LastDailyChallenge.In(location), s.now().In(location))
+	if days > user.StreakFreezes+1 {
+		return 0
+	}
+
+	return user.DailyChallengeStreak
+}
+
+// UserLocation returns the user's timezone, falling back to UTC when it is unset or unknown
+func UserLocation(user *domain.User) *time.Location {
+	if user.Timezone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// calendarDaysBetween counts the calendar days from a to b, both already in the same location
+func calendarDaysBetween(a, b time.Time) int {
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dayB.Sub(dayA).Hours() / 24)
+}
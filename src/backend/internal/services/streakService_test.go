@@ -0,0 +1,215 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"testing"
+	"time"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestStreakRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        domain.User
+		now         string
+		wantStreak  int
+		wantFreezes int
+		wantLongest int
+	}{
+		{
+			name:        "first challenge starts a streak",
+			now:         "2024-01-15T12:00:00Z",
+			wantStreak:  1,
+			wantLongest: 1,
+		},
+		{
+			name:        "next day continues the streak",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-01-16T08:00:00Z",
+			wantStreak:  4,
+			wantLongest: 4,
+		},
+		{
+			name:        "same day keeps the streak",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T01:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 5},
+			now:         "2024-01-15T23:00:00Z",
+			wantStreak:  3,
+			wantLongest: 5,
+		},
+		{
+			name:        "a new UTC day is the same day in New York",
+			user:        domain.User{Timezone: "America/New_York", LastDailyChallenge: at("2024-01-15T23:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-01-16T04:00:00Z",
+			wantStreak:  3,
+			wantLongest: 3,
+		},
+		{
+			name:        "the same UTC day is the next day in Auckland",
+			user:        domain.User{Timezone: "Pacific/Auckland", LastDailyChallenge: at("2024-06-01T11:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-06-01T13:00:00Z",
+			wantStreak:  4,
+			wantLongest: 4,
+		},
+		{
+			name:        "the next day in Tokyo is a missed day in UTC terms",
+			user:        domain.User{Timezone: "Asia/Tokyo", LastDailyChallenge: at("2024-01-14T16:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-01-16T14:00:00Z",
+			wantStreak:  4,
+			wantLongest: 4,
+		},
+		{
+			name:        "the spring forward day counts once",
+			user:        domain.User{Timezone: "America/New_York", LastDailyChallenge: at("2024-03-10T03:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-03-11T03:30:00Z",
+			wantStreak:  4,
+			wantLongest: 4,
+		},
+		{
+			name:        "24 hours on the fall back day are still one day",
+			user:        domain.User{Timezone: "Europe/Berlin", LastDailyChallenge: at("2024-10-26T22:30:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-10-27T22:30:00Z",
+			wantStreak:  3,
+			wantLongest: 3,
+		},
+		{
+			name:        "an unknown timezone falls back to UTC",
+			user:        domain.User{Timezone: "Mars/Olympus_Mons", LastDailyChallenge: at("2024-01-15T23:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3},
+			now:         "2024-01-16T04:00:00Z",
+			wantStreak:  4,
+			wantLongest: 4,
+		},
+		{
+			name:        "a freeze covers one missed day",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3, StreakFreezes: 1},
+			now:         "2024-01-17T12:00:00Z",
+			wantStreak:  4,
+			wantFreezes: 0,
+			wantLongest: 4,
+		},
+		{
+			name:        "two freezes cover two missed days",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3, StreakFreezes: 2},
+			now:         "2024-01-18T12:00:00Z",
+			wantStreak:  4,
+			wantFreezes: 0,
+			wantLongest: 4,
+		},
+		{
+			name:        "too few freezes reset the streak and are kept",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3, StreakFreezes: 1},
+			now:         "2024-01-18T12:00:00Z",
+			wantStreak:  1,
+			wantFreezes: 1,
+			wantLongest: 3,
+		},
+		{
+			name:        "a missed day in the user's timezone uses a freeze",
+			user:        domain.User{Timezone: "America/Los_Angeles", LastDailyChallenge: at("2024-01-16T07:00:00Z"), DailyChallengeStreak: 3, LongestStreak: 3, StreakFreezes: 1},
+			now:         "2024-01-17T09:00:00Z",
+			wantStreak:  4,
+			wantFreezes: 0,
+			wantLongest: 4,
+		},
+		{
+			name:        "a full week earns a freeze",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: constants.StreakFreezeInterval - 1},
+			now:         "2024-01-16T12:00:00Z",
+			wantStreak:  constants.StreakFreezeInterval,
+			wantFreezes: 1,
+			wantLongest: constants.StreakFreezeInterval,
+		},
+		{
+			name:        "freezes are capped",
+			user:        domain.User{LastDailyChallenge: at("2024-01-15T12:00:00Z"), DailyChallengeStreak: constants.StreakFreezeInterval - 1, StreakFreezes: constants.MaxStreakFreezes},
+			now:         "2024-01-16T12:00:00Z",
+			wantStreak:  constants.StreakFreezeInterval,
+			wantFreezes: constants.MaxStreakFreezes,
+			wantLongest: constants.StreakFreezeInterval,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := at(test.now)
+			user := test.user
+			NewStreakServiceWithClock(func() time.Time { return now }).Record(&user)
+
+			if user.DailyChallengeStreak != test.wantStreak {
+				t.Errorf("streak: got %d, want %d", user.DailyChallengeStreak, test.wantStreak)
+			}
+			if user.StreakFreezes != test.wantFreezes {
+				t.Errorf("freezes: got %d, want %d", user.StreakFreezes, test.wantFreezes)
+			}
+			if user.LongestStreak != test.wantLongest {
+				t.Errorf("longest streak: got %d, want %d", user.LongestStreak, test.wantLongest)
+			}
+			if !user.LastDailyChallenge.Equal(now) {
+				t.Errorf("last challenge: got %s, want %s", user.LastDailyChallenge, now)
+			}
+		})
+	}
+}
+
+func TestStreakCurrent(t *testing.T) {
+	tests := []struct {
+		name string
+		user domain.User
+		now  string
+		want int
+	}{
+		{
+			name: "no challenge yet",
+			now:  "2024-01-15T12:00:00Z",
+			want: 0,
+		},
+		{
+			name: "answered yesterday",
+			user: domain.User{LastDailyChallenge: at("2024-01-14T12:00:00Z"), DailyChallengeStreak: 5},
+			now:  "2024-01-15T23:59:00Z",
+			want: 5,
+		},
+		{
+			name: "missed yesterday without freezes",
+			user: domain.User{LastDailyChallenge: at("2024-01-13T12:00:00Z"), DailyChallengeStreak: 5},
+			now:  "2024-01-15T00:01:00Z",
+			want: 0,
+		},
+		{
+			name: "missed yesterday with a freeze",
+			user: domain.User{LastDailyChallenge: at("2024-01-13T12:00:00Z"), DailyChallengeStreak: 5, StreakFreezes: 1},
+			now:  "2024-01-15T12:00:00Z",
+			want: 5,
+		},
+		{
+			name: "still yesterday in Honolulu",
+			user: domain.User{Timezone: "Pacific/Honolulu", LastDailyChallenge: at("2024-01-14T20:00:00Z"), DailyChallengeStreak: 5},
+			now:  "2024-01-16T08:00:00Z",
+			want: 5,
+		},
+		{
+			name: "already two days later in Kiritimati",
+			user: domain.User{Timezone: "Pacific/Kiritimati", LastDailyChallenge: at("2024-01-14T09:00:00Z"), DailyChallengeStreak: 5},
+			now:  "2024-01-15T11:00:00Z",
+			want: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := at(test.now)
+			user := test.user
+			got := NewStreakServiceWithClock(func() time.Time { return now }).Current(&user)
+			if got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// TopicResolver turns the topic names learners, creators and the roadmap generator write into
+// the names of existing topics, so "Go", "golang" and "go lang" end up on one topic
+type TopicResolver struct {
+	topics  repository.ITopicRepository
+	aliases repository.ITopicAliasRepository
+}
+
+func NewTopicResolver(topics repository.ITopicRepository, aliases repository.ITopicAliasRepository) *TopicResolver {
+	return &TopicResolver{topics: topics, aliases: aliases}
+}
+
+// Resolve maps every name to the topic its slug is an alias of, creating topics for names
+// nothing resolves to. Blank names and duplicates are dropped, the order is kept
+func (r *TopicResolver) Resolve(ctx context.Context, names []string) ([]string, error) {
+	resolved := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		slug := domain.TopicSlug(name)
+		if slug == "" {
+			continue
+		}
+
+		alias, err := r.aliases.Get(ctx, slug)
+		if err != nil {
+			return nil, err
+		}
+
+		topicName := ""
+		if alias != nil {
+			topicName = alias.Topic
+		} else {
+			// Nothing resolves to the slug, the topic may still exist under this exact name
+			topic, err := r.topics.Get(ctx, strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			if topic == nil {
+				topic = domain.NewTopic(strings.TrimSpace(name))
+				if err := r.topics.Insert(ctx, []*domain.Topic{topic}); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := r.RecordAliases(ctx, topic); err != nil {
+				return nil, err
+			}
+			topicName = topic.Name
+		}
+
+		if !seen[topicName] {
+			seen[topicName] = true
+			resolved = append(resolved, topicName)
+		}
+	}
+
+	return resolved, nil
+}
+
+// Canonical maps every name to the topic its slug is an alias of without creating topics, for
+// reading by topic. Names nothing resolves to are kept as written, duplicates are dropped
+func (r *TopicResolver) Canonical(ctx context.Context, names []string) ([]string, error) {
+	canonical := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := domain.TopicSlug(name)
+		if slug == "" {
+			continue
+		}
+
+		alias, err := r.aliases.Get(ctx, slug)
+		if err != nil {
+			return nil, err
+		}
+		if alias != nil {
+			name = alias.Topic
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			canonical = append(canonical, name)
+		}
+	}
+	return canonical, nil
+}
+
+// Lookup returns the existing topic the name resolves to, without creating one
+func (r *TopicResolver) Lookup(ctx context.Context, name string) (*domain.Topic, error) {
+	topicName := name
+	alias, err := r.aliases.Get(ctx, domain.TopicSlug(name))
+	if err != nil {
+		return nil, err
+	}
+	if alias != nil {
+		topicName = alias.Topic
+	}
+
+	topic, err := r.topics.Get(ctx, topicName)
+	if err != nil {
+		return nil, err
+	}
+	if topic == nil {
+		return nil, fmt.Errorf("topic %s not found", name)
+	}
+	return topic, nil
+}
+
+// RecordAliases points the topic's slugs that resolve to nothing yet at the topic, slugs already
+// resolving to another topic keep doing so. Every inserted topic gets its aliases this way
+func (r *TopicResolver) RecordAliases(ctx context.Context, topic *domain.Topic) error {
+	for slug := range topicSlugs(topic) {
+		alias, err := r.aliases.Get(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if alias != nil {
+			continue
+		}
+		if err := r.aliases.Put(ctx, &domain.TopicAlias{Alias: slug, Topic: topic.Name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topicSlugs are the slugs resolving to the topic: its own, its name's and its aliases'
+func topicSlugs(topic *domain.Topic) map[string]bool {
+	slugs := map[string]bool{domain.TopicSlug(topic.Name): true}
+	if topic.Slug != "" {
+		slugs[topic.Slug] = true
+	}
+	for _, alias := range topic.Aliases {
+		slugs[domain.TopicSlug(alias)] = true
+	}
+	delete(slugs, "")
+	return slugs
+}
+
+// TopicService curates the taxonomy: descriptions, the hierarchy, aliases and merging topics
+type TopicService struct {
+	*TopicResolver
+	roadmaps  repository.IRoadmapRepository
+	courses   repository.ICourseRepository
+	publisher events.Publisher
+	search    *SearchService
+}
+
+func NewTopicService(resolver *TopicResolver, roadmaps repository.IRoadmapRepository, courses repository.ICourseRepository, publisher events.Publisher, search *SearchService) *TopicService {
+	return &TopicService{
+		TopicResolver: resolver,
+		roadmaps:      roadmaps,
+		courses:       courses,
+		publisher:     publisher,
+		search:        search,
+	}
+}
+
+// UpdateTopic changes the display name, description, parent and aliases of an existing topic.
+// An alias already resolving to another topic is refused, merge the topics instead
+func (s *TopicService) UpdateTopic(ctx context.Context, input domain.Topic) (*domain.Topic, error) {
+	topic, err := s.Lookup(ctx, input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Parent != "" {
+		parent, err := s.Lookup(ctx, input.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkParent(ctx, topic.Name, parent); err != nil {
+			return nil, err
+		}
+		input.Parent = parent.Name
+	}
+
+	previous := topicSlugs(topic)
+
+	topic.DisplayName = strings.TrimSpace(input.DisplayName)
+	if topic.DisplayName == "" {
+		topic.DisplayName = topic.Name
+	}
+	topic.Description = input.Description
+	topic.Parent = input.Parent
+	topic.Slug = domain.TopicSlug(topic.Name)
+	topic.Aliases = uniqueAliases(input.Aliases)
+
+	current := topicSlugs(topic)
+	for slug := range current {
+		alias, err := s.aliases.Get(ctx, slug)
+		if err != nil {
+			return nil, err
+		}
+		if alias != nil && alias.Topic != topic.Name {
+			return nil, fmt.Errorf("alias %s already resolves to topic %s", slug, alias.Topic)
+		}
+	}
+
+	if err := s.topics.Insert(ctx, []*domain.Topic{topic}); err != nil {
+		return nil, err
+	}
+	for slug := range current {
+		if err := s.aliases.Put(ctx, &domain.TopicAlias{Alias: slug, Topic: topic.Name}); err != nil {
+			return nil, err
+		}
+	}
+	for slug := range previous {
+		if !current[slug] {
+			if err := s.aliases.Delete(ctx, slug); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return topic, nil
+}
+
+// checkParent refuses a parent that is the topic itself or one of its descendants
+func (s *TopicService) checkParent(ctx context.Context, name string, parent *domain.Topic) error {
+	seen := make(map[string]bool)
+	for ancestor := parent; ancestor != nil; {
+		if ancestor.Name == name {
+			return fmt.Errorf("topic %s cannot be nested under itself", name)
+		}
+		if ancestor.Parent == "" || seen[ancestor.Name] {
+			return nil
+		}
+		seen[ancestor.Name] = true
+
+		next, err := s.topics.Get(ctx, ancestor.Parent)
+		if err != nil {
+			return err
+		}
+		ancestor = next
+	}
+	return nil
+}
+
+// MergeTopics folds the sources into the target: roadmaps and courses referring to a source refer
+// to the target instead, the sources' aliases and children move over and the sources are deleted.
+// Every source name keeps resolving to the target afterwards. Users are moved over asynchronously
+// by the UserTopicMerger subscribed to the TopicsMerged event
+func (s *TopicService) MergeTopics(ctx context.Context, sources []string, target string) (*domain.TopicMerge, error) {
+	targetTopic, err := s.Lookup(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	rename := make(map[string]string)
+	var merged []*domain.Topic
+	for _, name := range sources {
+		source, err := s.Lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if source.Name == targetTopic.Name || rename[source.Name] != "" {
+			continue
+		}
+		rename[source.Name] = targetTopic.Name
+		merged = append(merged, source)
+	}
+	if len(merged) == 0 {
+		return nil, errors.New("no topics to merge into the target")
+	}
+
+	result := &domain.TopicMerge{Target: targetTopic.Name}
+	sourceNames := make([]string, len(merged))
+	for i, source := range merged {
+		sourceNames[i] = source.Name
+	}
+	result.Merged = sourceNames
+
+	// References first, a failure leaves the sources in place and the merge can be run again
+	if result.Roadmaps, err = s.mergeRoadmaps(ctx, sourceNames, rename); err != nil {
+		return nil, err
+	}
+	if result.Courses, err = s.mergeCourses(ctx, sourceNames, rename); err != nil {
+		return nil, err
+	}
+	if err := s.publisher.Publish(ctx, events.TopicsMerged{Sources: sourceNames, Target: targetTopic.Name}); err != nil {
+		return nil, err
+	}
+
+	aliases := targetTopic.Aliases
+	for _, source := range merged {
+		aliases = append(aliases, source.Name)
+		aliases = append(aliases, source.Aliases...)
+	}
+	targetTopic.Aliases = uniqueAliases(aliases)
+	targetTopic.Slug = domain.TopicSlug(targetTopic.Name)
+	if targetTopic.DisplayName == "" {
+		targetTopic.DisplayName = targetTopic.Name
+	}
+	if rename[targetTopic.Parent] != "" {
+		targetTopic.Parent = ""
+	}
+	if err := s.topics.Insert(ctx, []*domain.Topic{targetTopic}); err != nil {
+		return nil, err
+	}
+
+	for slug := range topicSlugs(targetTopic) {
+		if err := s.aliases.Put(ctx, &domain.TopicAlias{Alias: slug, Topic: targetTopic.Name}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Children of a source are nested under the target
+	topics, err := s.topics.GetAllTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, topic := range topics {
+		if rename[topic.Parent] != "" && rename[topic.Name] == "" && topic.Name != targetTopic.Name {
+			topic.Parent = targetTopic.Name
+			if err := s.topics.Insert(ctx, []*domain.Topic{topic}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, source := range merged {
+		if err := s.topics.Delete(ctx, source.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *TopicService) mergeRoadmaps(ctx context.Context, sources []string, rename map[string]string) (int, error) {
+	roadmaps, err := s.roadmaps.GetByTopics(ctx, sources)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, roadmap := range roadmaps {
+		roadmap.Topics = renameTopics(roadmap.Topics, rename)
+		if err := s.roadmaps.UpsertRoadmap(ctx, roadmap); err != nil {
+			return 0, err
+		}
+		if s.search != nil {
+			if err := s.search.IndexRoadmap(ctx, roadmap); err != nil {
+				log.Printf("Error indexing roadmap %s: %v", roadmap.ID, err)
+			}
+		}
+	}
+	return len(roadmaps), nil
+}
+
+func (s *TopicService) mergeCourses(ctx context.Context, sources []string, rename map[string]string) (int, error) {
+	courses, err := s.courses.GetByTopics(ctx, sources)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, course := range courses {
+		course.Topics = renameTopics(course.Topics, rename)
+		if err := s.courses.UpsertCourse(ctx, course); err != nil {
+			return 0, err
+		}
+		if s.search != nil {
+			if err := s.search.IndexCourse(ctx, course); err != nil {
+				log.Printf("Error indexing course %s: %v", course.ID, err)
+			}
+		}
+	}
+	return len(courses), nil
+}
+
+// Attempts at rewriting a user whose topics keep changing between the read and the write
+const userTopicMergeAttempts = 3
+
+// UserTopicMerger moves users off merged topics. It runs from the outbox rather than while
+// merging, since it goes through every user
+type UserTopicMerger struct {
+	users repository.IUserRepository
+}
+
+func NewUserTopicMerger(users repository.IUserRepository) *UserTopicMerger {
+	return &UserTopicMerger{users: users}
+}
+
+func (m *UserTopicMerger) Register(bus events.Subscriber) {
+	bus.Subscribe(events.TopicsMergedEvent, "users", func(ctx context.Context, event events.Event) error {
+		merged := event.(events.TopicsMerged)
+		rename := make(map[string]string, len(merged.Sources))
+		for _, source := range merged.Sources {
+			rename[source] = merged.Target
+		}
+
+		updated, err := m.mergeUsers(ctx, rename)
+		log.Printf("Moved %d users off topics %v", updated, merged.Sources)
+		return err
+	})
+}
+
+// mergeUsers rewrites the users' topics, and their skill on a source when they have none on the
+// target. Users already rewritten are left alone, so a failed run can be delivered again
+func (m *UserTopicMerger) mergeUsers(ctx context.Context, rename map[string]string) (int, error) {
+	updated := 0
+	request := pagination.Request{First: userPageSize}
+	for {
+		page, err := m.users.ListUsers(ctx, request)
+		if err != nil {
+			return updated, err
+		}
+
+		for _, user := range page.Items {
+			changed, err := m.mergeUser(ctx, user, rename)
+			if err != nil {
+				return updated, err
+			}
+			if changed {
+				updated++
+			}
+		}
+
+		if !page.HasNextPage {
+			break
+		}
+		request.After = page.NextPosition()
+	}
+
+	return updated, nil
+}
+
+// mergeUser writes the renamed topics and skills of the user only if they are those read, and
+// reads the user again when they changed in between
+func (m *UserTopicMerger) mergeUser(ctx context.Context, user *domain.User, rename map[string]string) (bool, error) {
+	for attempt := 0; attempt < userTopicMergeAttempts; attempt++ {
+		topics, skills, changed := mergedTopics(user, rename)
+		if !changed {
+			return false, nil
+		}
+
+		written, err := m.users.UpdateTopics(ctx, user, topics, skills)
+		if err != nil || written {
+			return written, err
+		}
+
+		if user, err = m.users.GetUserByName(user.Name); err != nil {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("topics of user %s kept changing while merging", user.Name)
+}
+
+// mergedTopics are the user's topics and skills with the renames applied, a skill on a source
+// moving to the target when the user has none there
+func mergedTopics(user *domain.User, rename map[string]string) ([]string, map[string]float64, bool) {
+	changed := false
+	for _, topic := range user.Topics {
+		if rename[topic] != "" {
+			changed = true
+		}
+	}
+	topics := user.Topics
+	if changed {
+		topics = renameTopics(user.Topics, rename)
+	}
+
+	skills := make(map[string]float64, len(user.TopicSkills))
+	for topic, skill := range user.TopicSkills {
+		if rename[topic] == "" {
+			skills[topic] = skill
+		}
+	}
+	for topic, skill := range user.TopicSkills {
+		target := rename[topic]
+		if target == "" {
+			continue
+		}
+		if _, ok := skills[target]; !ok {
+			skills[target] = skill
+		}
+		changed = true
+	}
+
+	return topics, skills, changed
+}
+
+// renameTopics applies the renames and drops the duplicates they create, keeping the order
+func renameTopics(topics []string, rename map[string]string) []string {
+	renamed := make([]string, 0, len(topics))
+	seen := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if target := rename[topic]; target != "" {
+			topic = target
+		}
+		if !seen[topic] {
+			seen[topic] = true
+			renamed = append(renamed, topic)
+		}
+	}
+	return renamed
+}
+
+// uniqueAliases drops blank aliases and those sharing a slug with an earlier one
+func uniqueAliases(aliases []string) []string {
+	unique := make([]string, 0, len(aliases))
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		slug := domain.TopicSlug(alias)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		unique = append(unique, alias)
+	}
+	return unique
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type memoryUsers struct {
+	users map[string]*domain.User
+	// Applied to the stored user right after it is listed, as a concurrent write would be
+	changeAfterList func(user *domain.User)
+}
+
+func (m *memoryUsers) UpsertUser(user domain.User) (domain.User, error) {
+	m.users[user.Name] = &user
+	return user, nil
+}
+
+func (m *memoryUsers) GetUsers() []*domain.User {
+	return nil
+}
+
+func (m *memoryUsers) ListUsers(ctx context.Context, request pagination.Request) (pagination.Page[*domain.User], error) {
+	page := pagination.Page[*domain.User]{}
+	for _, user := range m.users {
+		listed := *user
+		page.Items = append(page.Items, &listed)
+		if m.changeAfterList != nil {
+			m.changeAfterList(user)
+		}
+	}
+	return page, nil
+}
+
+func (m *memoryUsers) GetUserByName(name string) (*domain.User, error) {
+	user, ok := m.users[name]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	read := *user
+	return &read, nil
+}
+
+func (m *memoryUsers) UpdateAchievements(ctx context.Context, user *domain.User) error {
+	return nil
+}
+
+func (m *memoryUsers) UpdateTopics(ctx context.Context, user *domain.User, topics []string, skills map[string]float64) (bool, error) {
+	stored := m.users[user.Name]
+	if !reflect.DeepEqual(stored.Topics, user.Topics) || !reflect.DeepEqual(stored.TopicSkills, user.TopicSkills) {
+		return false, nil
+	}
+	stored.Topics = topics
+	stored.TopicSkills = skills
+	return true, nil
+}
+
+func TestMergeUsersRenamesTopicsAndSkills(t *testing.T) {
+	users := &memoryUsers{users: map[string]*domain.User{
+		"ada": {
+			Name:        "ada",
+			Topics:      []string{"golang", "Go", "Rust"},
+			TopicSkills: map[string]float64{"golang": 3, "Rust": 1},
+		},
+		"bob": {Name: "bob", Topics: []string{"Rust"}},
+	}}
+
+	updated, err := NewUserTopicMerger(users).mergeUsers(context.Background(), map[string]string{"golang": "Go"})
+	if err != nil {
+		t.Fatalf("mergeUsers: %v", err)
+	}
+	if updated != 1 {
+		t.Errorf("updated %d users, want 1", updated)
+	}
+
+	ada := users.users["ada"]
+	if want := []string{"Go", "Rust"}; !reflect.DeepEqual(ada.Topics, want) {
+		t.Errorf("topics = %v, want %v", ada.Topics, want)
+	}
+	if want := map[string]float64{"Go": 3, "Rust": 1}; !reflect.DeepEqual(ada.TopicSkills, want) {
+		t.Errorf("skills = %v, want %v", ada.TopicSkills, want)
+	}
+}
+
+func TestMergeUsersKeepsTopicsChangedWhileMerging(t *testing.T) {
+	users := &memoryUsers{users: map[string]*domain.User{
+		"ada": {Name: "ada", Topics: []string{"golang"}},
+	}}
+	// The user follows another topic between the listing and the write
+	users.changeAfterList = func(user *domain.User) {
+		user.Topics = append(user.Topics, "Rust")
+	}
+
+	if _, err := NewUserTopicMerger(users).mergeUsers(context.Background(), map[string]string{"golang": "Go"}); err != nil {
+		t.Fatalf("mergeUsers: %v", err)
+	}
+
+	if want := []string{"Go", "Rust"}; !reflect.DeepEqual(users.users["ada"].Topics, want) {
+		t.Errorf("topics = %v, want %v", users.users["ada"].Topics, want)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A like says more than following a roadmap, which says more than opening it
+const (
+	trendingLikeWeight     = 3.0
+	trendingProgressWeight = 2.0
+	trendingViewWeight     = 1.0
+)
+
+var trendingWindowDays = map[string]int{
+	constants.TrendingDaily:   1,
+	constants.TrendingWeekly:  7,
+	constants.TrendingMonthly: 30,
+}
+
+// TrendingService counts likes, views and progress per roadmap and day, and ranks roadmaps by
+// that recent activity rather than by their lifetime likes
+type TrendingService struct {
+	activity repository.IRoadmapActivityRepository
+	roadmaps repository.IRoadmapRepository
+	edges    repository.ITopicEdgeRepository
+	now      func() time.Time
+}
+
+func NewTrendingService(activity repository.IRoadmapActivityRepository, roadmaps repository.IRoadmapRepository, edges repository.ITopicEdgeRepository) *TrendingService {
+	return NewTrendingServiceWithClock(activity, roadmaps, edges, time.Now)
+}
+
+func NewTrendingServiceWithClock(activity repository.IRoadmapActivityRepository, roadmaps repository.IRoadmapRepository, edges repository.ITopicEdgeRepository, now func() time.Time) *TrendingService {
+	return &TrendingService{activity: activity, roadmaps: roadmaps, edges: edges, now: now}
+}
+
+func (s *TrendingService) Register(bus events.Subscriber) {
+	bus.Subscribe(events.RoadmapLikedEvent, "trending", func(ctx context.Context, event events.Event) error {
+		return s.record(ctx, domain.RoadmapActivity{RoadmapID: event.(events.RoadmapLiked).RoadmapID, Likes: 1})
+	})
+	bus.Subscribe(events.RoadmapViewedEvent, "trending", func(ctx context.Context, event events.Event) error {
+		return s.record(ctx, domain.RoadmapActivity{RoadmapID: event.(events.RoadmapViewed).RoadmapID, Views: 1})
+	})
+	bus.Subscribe(events.RoadmapProgressedEvent, "trending", func(ctx context.Context, event events.Event) error {
+		return s.record(ctx, domain.RoadmapActivity{RoadmapID: event.(events.RoadmapProgressed).RoadmapID, Progress: 1})
+	})
+}
+
+// record counts the activity on the day the event occurred, events delivered late from the
+// outbox still count on their day
+func (s *TrendingService) record(ctx context.Context, activity domain.RoadmapActivity) error {
+	occurredAt := s.now()
+	if message, ok := events.MessageFrom(ctx); ok && !message.OccurredAt.IsZero() {
+		occurredAt = message.OccurredAt
+	}
+
+	activity.Day = occurredAt.UTC().Format(time.DateOnly)
+	return s.activity.Increment(ctx, &activity)
+}
+
+// Trending ranks the published roadmaps with activity over the window by that activity, keeping
+// those on the topic unless it is empty. A topic's roadmaps are read from its edges, so only the
+// ranked roadmaps on it are loaded. Every day's activity weighs half as much as it did a third
+// of the window later, so a burst fades instead of holding the top spot until it leaves the window
+func (s *TrendingService) Trending(ctx context.Context, topic, window string) ([]*domain.Roadmap, error) {
+	if window == "" {
+		window = constants.TrendingWeekly
+	}
+	days, ok := trendingWindowDays[window]
+	if !ok {
+		return nil, fmt.Errorf("unknown trending window %s", window)
+	}
+	halfLife := float64(days) / 3
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var activityErr error
+	scores := make(map[string]float64)
+	today := s.now().UTC()
+	for age := 0; age < days; age++ {
+		wg.Add(1)
+		go func(age int) {
+			defer wg.Done()
+			activity, err := s.activity.GetDay(ctx, today.AddDate(0, 0, -age).Format(time.DateOnly))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				activityErr = err
+				return
+			}
+
+			decay := math.Pow(0.5, float64(age)/halfLife)
+			for _, counters := range activity {
+				scores[counters.RoadmapID] += decay * (trendingLikeWeight*float64(counters.Likes) +
+					trendingProgressWeight*float64(counters.Progress) +
+					trendingViewWeight*float64(counters.Views))
+			}
+		}(age)
+	}
+	wg.Wait()
+	if activityErr != nil {
+		return nil, activityErr
+	}
+
+	// A nil set keeps every roadmap
+	var onTopic map[string]bool
+	if topic != "" {
+		var err error
+		if onTopic, err = s.topicRoadmapIDs(ctx, topic); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+	for roadmapID, score := range scores {
+		if score > 0 && (onTopic == nil || onTopic[roadmapID]) {
+			ids = append(ids, roadmapID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	// Rankings are paged up to MaxRankedItems, the few custom roadmaps dropped below may leave it short
+	if len(ids) > MaxRankedItems {
+		ids = ids[:MaxRankedItems]
+	}
+
+	roadmaps, err := s.roadmaps.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	trending := make([]*domain.Roadmap, 0, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		if !roadmap.IsCustom && (topic == "" || hasTopic(roadmap.Topics, topic)) {
+			trending = append(trending, roadmap)
+		}
+	}
+	return trending, nil
+}
+
+// topicRoadmapIDs reads the ids of the roadmaps tagged with the topic from its edges
+func (s *TrendingService) topicRoadmapIDs(ctx context.Context, topic string) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	request := pagination.Request{First: pagination.MaxFirst}
+	for {
+		page, err := s.edges.GetByTopic(ctx, topic, constants.TopicItemRoadmap, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, edge := range page.Items {
+			ids[edge.ItemID] = true
+		}
+
+		if !page.HasNextPage {
+			return ids, nil
+		}
+		request.After = page.NextPosition()
+	}
+}
+
+// Newest pages through the published roadmaps on the topic, or on every topic when empty,
+// newest first. Every roadmap is paged through the newest index, a topic's roadmaps are read
+// through its topic edges. Roadmaps published before creation dates were recorded are left out
+func (s *TrendingService) Newest(ctx context.Context, topic string, request pagination.Request) (pagination.Page[*domain.Roadmap], error) {
+	if topic == "" {
+		return s.roadmaps.FindNewest(ctx, request)
+	}
+
+	roadmaps, err := s.roadmaps.GetByTopic(ctx, topic)
+	if err != nil {
+		return pagination.Page[*domain.Roadmap]{}, err
+	}
+
+	newest := make([]*domain.Roadmap, 0, len(roadmaps))
+	for _, roadmap := range roadmaps {
+		if !roadmap.IsCustom && !roadmap.CreatedAt.IsZero() {
+			newest = append(newest, roadmap)
+		}
+	}
+
+	sort.SliceStable(newest, func(i, j int) bool {
+		if !newest[i].CreatedAt.Equal(newest[j].CreatedAt) {
+			return newest[i].CreatedAt.After(newest[j].CreatedAt)
+		}
+		return newest[i].ID < newest[j].ID
+	})
+
+	return pagination.SliceByOffset(newest, request), nil
+}
+
+func hasTopic(topics []string, topic string) bool {
+	for _, candidate := range topics {
+		if strings.EqualFold(candidate, topic) {
+			return true
+		}
+	}
+	return false
+}
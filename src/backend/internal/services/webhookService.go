@@ -0,0 +1,414 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/events"
+	"backend/internal/pagination"
+	"backend/internal/repository"
+	"backend/internal/safehttp"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	webhookMaxAttempts    = 4
+	webhookInitialBackoff = time.Second
+	webhookTimeout        = 10 * time.Second
+	// Deliveries sent at the same time by SendPending
+	webhookConcurrency = 8
+	// SendPending stops attempting this long before its deadline, to save the outcomes in time
+	webhookSaveMargin = 5 * time.Second
+	// Claims outlive the longest lambda run, a delivery is only taken over once its run is gone
+	webhookClaimLease = 15 * time.Minute
+	// Owners have few endpoints, which keeps the webhooks query a plain list
+	MaxWebhooksPerOwner = 20
+)
+
+var errWebhookForbidden = errors.New("user is not authorized to manage this webhook")
+
+// WebhookService notifies integrators' endpoints of the events they subscribed to. Events only
+// queue deliveries, SendPending sends them from the outbox lambda so slow endpoints never hold up
+// a learner's request. Every delivery is signed with the webhook's secret, retried with
+// exponential backoff and logged
+type WebhookService struct {
+	webhooks   repository.IWebhookRepository
+	deliveries repository.IWebhookDeliveryRepository
+	client     HTTPFetcher
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewWebhookService posts through a client that refuses non-public addresses, redirects included
+func NewWebhookService(webhooks repository.IWebhookRepository, deliveries repository.IWebhookDeliveryRepository) *WebhookService {
+	return NewWebhookServiceWithClient(webhooks, deliveries, safehttp.NewClient(webhookTimeout), sleepContext)
+}
+
+func NewWebhookServiceWithClient(webhooks repository.IWebhookRepository, deliveries repository.IWebhookDeliveryRepository, client HTTPFetcher, sleep func(ctx context.Context, d time.Duration) error) *WebhookService {
+	return &WebhookService{
+		webhooks:   webhooks,
+		deliveries: deliveries,
+		client:     client,
+		sleep:      sleep,
+	}
+}
+
+// Create registers an endpoint for the given events. Admins receive events about all content,
+// creators only the completions and publications of their own roadmaps
+func (s *WebhookService) Create(ctx context.Context, owner *domain.User, url string, eventNames []string) (*domain.Webhook, error) {
+	if owner.Role != constants.CreatorRole && owner.Role != constants.AdminRole {
+		return nil, errors.New("user is not authorized to register webhooks")
+	}
+
+	if err := validateWebhookURL(ctx, url); err != nil {
+		return nil, err
+	}
+
+	if len(eventNames) == 0 {
+		return nil, errors.New("webhooks need at least one event")
+	}
+
+	existing, err := s.webhooks.GetByOwner(ctx, owner.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxWebhooksPerOwner {
+		return nil, fmt.Errorf("users can register at most %d webhooks", MaxWebhooksPerOwner)
+	}
+
+	for _, name := range eventNames {
+		switch name {
+		case constants.WebhookRoadmapCompleted, constants.WebhookRoadmapPublished:
+		case constants.WebhookChallengeAnswered:
+			if owner.Role != constants.AdminRole {
+				return nil, fmt.Errorf("only admins can subscribe to %s", name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown webhook event %s", name)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	webhook := &domain.Webhook{
+		ID:        uuid.NewString(),
+		OwnerID:   owner.Name,
+		URL:       url,
+		Events:    eventNames,
+		Global:    owner.Role == constants.AdminRole,
+		Secret:    hex.EncodeToString(secret),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.webhooks.Insert(ctx, webhook); err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// List returns the user's webhooks. Secrets are only shown once, when the webhook is created
+func (s *WebhookService) List(ctx context.Context, owner *domain.User) ([]*domain.Webhook, error) {
+	webhooks, err := s.webhooks.GetByOwner(ctx, owner.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, owner *domain.User, webhookID string) error {
+	if _, err := s.get(ctx, owner, webhookID); err != nil {
+		return err
+	}
+
+	return s.webhooks.Delete(ctx, webhookID)
+}
+
+// Deliveries pages through the deliveries of the webhook, newest first
+func (s *WebhookService) Deliveries(ctx context.Context, owner *domain.User, webhookID string, request pagination.Request) (pagination.Page[*domain.WebhookDelivery], error) {
+	if _, err := s.get(ctx, owner, webhookID); err != nil {
+		return pagination.Page[*domain.WebhookDelivery]{}, err
+	}
+
+	return s.deliveries.GetByWebhook(ctx, webhookID, request)
+}
+
+// Replay queues the payload of a past delivery again, as a new delivery
+func (s *WebhookService) Replay(ctx context.Context, owner *domain.User, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	webhook, err := s.get(ctx, owner, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	original, err := s.deliveries.Get(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &domain.WebhookDelivery{
+		ID:        uuid.NewString(),
+		WebhookID: webhook.ID,
+		Event:     original.Event,
+		Payload:   original.Payload,
+		Status:    constants.WebhookDeliveryPending,
+		ReplayOf:  original.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.deliveries.Insert(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (s *WebhookService) get(ctx context.Context, owner *domain.User, webhookID string) (*domain.Webhook, error) {
+	webhook, err := s.webhooks.Get(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook.OwnerID != owner.Name && owner.Role != constants.AdminRole {
+		return nil, errWebhookForbidden
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) Register(bus events.Subscriber) {
+	bus.Subscribe(events.RoadmapCompletedEvent, "webhooks", func(ctx context.Context, event events.Event) error {
+		return s.dispatch(ctx, constants.WebhookRoadmapCompleted, event.(events.RoadmapCompleted).AuthorID, event)
+	})
+	bus.Subscribe(events.RoadmapCreatedEvent, "webhooks", func(ctx context.Context, event events.Event) error {
+		return s.dispatch(ctx, constants.WebhookRoadmapPublished, event.(events.RoadmapCreated).UserID, event)
+	})
+	bus.Subscribe(events.ChallengeAnsweredEvent, "webhooks", func(ctx context.Context, event events.Event) error {
+		return s.dispatch(ctx, constants.WebhookChallengeAnswered, "", event)
+	})
+}
+
+// dispatch queues a delivery of the event for every webhook subscribed to it that may see
+// content of the given author
+func (s *WebhookService) dispatch(ctx context.Context, name, authorID string, event events.Event) error {
+	webhooks, err := s.webhooks.GetByEvent(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, webhook := range webhooks {
+		if !webhook.Global && (authorID == "" || webhook.OwnerID != authorID) {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			ID:        uuid.NewString(),
+			WebhookID: webhook.ID,
+			Event:     name,
+			Status:    constants.WebhookDeliveryPending,
+			CreatedAt: time.Now().UTC(),
+		}
+
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":        delivery.ID,
+			"event":     name,
+			"createdAt": delivery.CreatedAt,
+			"data":      event,
+		})
+		if err != nil {
+			return err
+		}
+		delivery.Payload = string(payload)
+
+		errs = append(errs, s.deliveries.Insert(ctx, delivery))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SendPending sends up to limit queued deliveries and returns how many reached their endpoint.
+// Endpoint failures end up in the delivery log, only storage errors are returned. Deliveries
+// still retrying when the context ends stay pending and continue on the next run. Each delivery is
+// claimed before it is posted, so concurrent runs never send the same one
+func (s *WebhookService) SendPending(ctx context.Context, limit int) (int, error) {
+	deliveries, err := s.deliveries.GetPending(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	sendCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		sendCtx, cancel = context.WithDeadline(ctx, deadline.Add(-webhookSaveMargin))
+		defer cancel()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	sent := 0
+	slots := make(chan struct{}, webhookConcurrency)
+	for _, delivery := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery *domain.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			claimed, err := s.deliveries.Claim(ctx, delivery, time.Now().Add(webhookClaimLease))
+			if err == nil && claimed {
+				err = s.sendDelivery(ctx, sendCtx, delivery)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if delivery.Success {
+				sent++
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return sent, errors.Join(errs...)
+}
+
+func (s *WebhookService) sendDelivery(ctx, sendCtx context.Context, delivery *domain.WebhookDelivery) error {
+	webhook, err := s.webhooks.Get(ctx, delivery.WebhookID)
+	if err != nil {
+		// The webhook was deleted since the delivery was queued
+		delivery.Status = constants.WebhookDeliveryFailed
+		delivery.Error = err.Error()
+		return s.deliveries.Update(ctx, delivery)
+	}
+
+	s.send(sendCtx, webhook, delivery)
+	if delivery.Status == constants.WebhookDeliverySending {
+		// The run ended with attempts left, the next one continues
+		delivery.Status = constants.WebhookDeliveryPending
+	}
+	return s.deliveries.Update(ctx, delivery)
+}
+
+// send posts the delivery until the endpoint answers with a 2xx, waiting twice as long after
+// every failed attempt, and records the outcome of the last attempt on the delivery. Attempts
+// left when the context ends are kept for the next run
+func (s *WebhookService) send(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) {
+	backoff := webhookInitialBackoff << max(delivery.Attempts-1, 0)
+	for attempt := delivery.Attempts + 1; attempt <= webhookMaxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := s.sleep(ctx, backoff); err != nil {
+				return
+			}
+			backoff *= 2
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		delivery.Attempts = attempt
+		statusCode, err := s.post(ctx, webhook, delivery)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Status = constants.WebhookDeliveryDelivered
+			delivery.Success = true
+			delivery.Error = ""
+			return
+		}
+		delivery.Error = err.Error()
+	}
+
+	delivery.Status = constants.WebhookDeliveryFailed
+}
+
+func (s *WebhookService) post(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "QriosityWebhooks/1.0")
+	req.Header.Set("X-Qriosity-Event", delivery.Event)
+	req.Header.Set("X-Qriosity-Delivery", delivery.ID)
+	req.Header.Set("X-Qriosity-Timestamp", timestamp)
+	req.Header.Set("X-Qriosity-Signature", "sha256="+SignWebhookPayload(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxPageSize))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload is the hex HMAC-SHA256 of "<timestamp>.<body>" with the webhook's secret.
+// Receivers recompute it to check the payload came from us and reject old timestamps to stop replays
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateWebhookURL only accepts HTTPS endpoints on public addresses. Plain HTTP and local
+// receivers are allowed for development when ALLOW_PRIVATE_URLS is set
+func validateWebhookURL(ctx context.Context, url string) error {
+	parsed, err := neturl.Parse(url)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("invalid webhook url %s", url)
+	}
+
+	switch {
+	case parsed.Scheme == "https":
+	case parsed.Scheme == "http" && safehttp.AllowPrivate():
+	default:
+		return errors.New("webhook urls must use https")
+	}
+
+	if err := safehttp.CheckHost(ctx, parsed.Hostname()); err != nil {
+		return fmt.Errorf("invalid webhook url %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// sleepContext waits for d, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/domain"
+	"backend/internal/pagination"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryWebhooks struct {
+	webhooks map[string]*domain.Webhook
+}
+
+func (m *memoryWebhooks) Insert(ctx context.Context, webhook *domain.Webhook) error {
+	m.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+func (m *memoryWebhooks) Get(ctx context.Context, webhookID string) (*domain.Webhook, error) {
+	webhook, ok := m.webhooks[webhookID]
+	if !ok {
+		return nil, errors.New("webhook not found")
+	}
+	return webhook, nil
+}
+
+func (m *memoryWebhooks) GetByOwner(ctx context.Context, ownerID string) ([]*domain.Webhook, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhooks) GetByEvent(ctx context.Context, event string) ([]*domain.Webhook, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhooks) Delete(ctx context.Context, webhookID string) error {
+	delete(m.webhooks, webhookID)
+	return nil
+}
+
+type memoryDeliveries struct {
+	mu         sync.Mutex
+	deliveries []*domain.WebhookDelivery
+	// Deliveries claimed by another run between GetPending and Claim
+	claimedElsewhere map[string]bool
+}
+
+func (m *memoryDeliveries) Insert(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func (m *memoryDeliveries) Get(ctx context.Context, webhookID, deliveryID string) (*domain.WebhookDelivery, error) {
+	return nil, errors.New("webhook delivery not found")
+}
+
+func (m *memoryDeliveries) GetByWebhook(ctx context.Context, webhookID string, request pagination.Request) (pagination.Page[*domain.WebhookDelivery], error) {
+	return pagination.Page[*domain.WebhookDelivery]{}, nil
+}
+
+func (m *memoryDeliveries) GetPending(ctx context.Context, limit int) ([]*domain.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := make([]*domain.WebhookDelivery, 0)
+	for _, delivery := range m.deliveries {
+		if delivery.Status == constants.WebhookDeliveryPending && len(pending) < limit {
+			pending = append(pending, delivery)
+		}
+	}
+	return pending, nil
+}
+
+func (m *memoryDeliveries) Claim(ctx context.Context, delivery *domain.WebhookDelivery, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.claimedElsewhere[delivery.ID] || delivery.Status != constants.WebhookDeliveryPending {
+		return false, nil
+	}
+	delivery.Status = constants.WebhookDeliverySending
+	delivery.ClaimExpiresAt = until.Unix()
+	return true, nil
+}
+
+func (m *memoryDeliveries) Update(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return nil
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "payload",
+			secret:    "secret",
+			timestamp: "1700000000",
+			body:      `{"event":"roadmap.completed"}`,
+			want:      "a34ff99bdd4f3fda8c65cf7298133990a53e69cfb4c4c9c5981e2331f1f801d0",
+		},
+		{
+			name:      "another secret",
+			secret:    "other",
+			timestamp: "1700000000",
+			body:      `{"event":"roadmap.completed"}`,
+			want:      "08ee67ed013a441720831a413307ecd66dbab5a2c5106676e7bcef73f3955966",
+		},
+		{
+			name:      "another timestamp",
+			secret:    "secret",
+			timestamp: "1700000001",
+			body:      `{"event":"roadmap.completed"}`,
+			want:      "c00da8a3dcead9edc557466413fb383bbe74057bda6a8a49445bb2a946ca975b",
+		},
+		{
+			name:      "empty body",
+			secret:    "secret",
+			timestamp: "1700000000",
+			want:      "4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := SignWebhookPayload(test.secret, test.timestamp, []byte(test.body))
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSendPendingRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		answers      []int
+		attempts     int
+		cancelled    bool
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+		wantSleeps   []time.Duration
+	}{
+		{
+			name:         "delivered on the first attempt",
+			answers:      []int{http.StatusOK},
+			wantStatus:   constants.WebhookDeliveryDelivered,
+			wantAttempts: 1,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "delivered after failures",
+			answers:      []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent},
+			wantStatus:   constants.WebhookDeliveryDelivered,
+			wantAttempts: 3,
+			wantCode:     http.StatusNoContent,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name:         "failed after the last attempt",
+			answers:      []int{http.StatusInternalServerError},
+			wantStatus:   constants.WebhookDeliveryFailed,
+			wantAttempts: webhookMaxAttempts,
+			wantCode:     http.StatusInternalServerError,
+			wantSleeps:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:         "a resumed delivery continues the backoff",
+			answers:      []int{http.StatusOK},
+			attempts:     2,
+			wantStatus:   constants.WebhookDeliveryDelivered,
+			wantAttempts: 3,
+			wantCode:     http.StatusOK,
+			wantSleeps:   []time.Duration{2 * time.Second},
+		},
+		{
+			name:         "a cancelled run leaves the delivery pending",
+			answers:      []int{http.StatusOK},
+			attempts:     1,
+			cancelled:    true,
+			wantStatus:   constants.WebhookDeliveryPending,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			const secret = "secret"
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				want := "sha256=" + SignWebhookPayload(secret, r.Header.Get("X-Qriosity-Timestamp"), body)
+				if r.Header.Get("X-Qriosity-Signature") != want {
+					t.Errorf("got signature %s, want %s", r.Header.Get("X-Qriosity-Signature"), want)
+				}
+				w.WriteHeader(test.answers[min(requests, len(test.answers)-1)])
+				requests++
+			}))
+			t.Cleanup(server.Close)
+
+			webhooks := &memoryWebhooks{webhooks: map[string]*domain.Webhook{
+				"webhook": {ID: "webhook", URL: server.URL, Secret: secret},
+			}}
+			deliveries := &memoryDeliveries{}
+			delivery := &domain.WebhookDelivery{
+				ID:        "delivery",
+				WebhookID: "webhook",
+				Event:     constants.WebhookRoadmapCompleted,
+				Payload:   `{"event":"roadmap.completed"}`,
+				Status:    constants.WebhookDeliveryPending,
+				Attempts:  test.attempts,
+			}
+			_ = deliveries.Insert(context.Background(), delivery)
+
+			var sleeps []time.Duration
+			sleep := func(ctx context.Context, d time.Duration) error {
+				if test.cancelled {
+					return context.Canceled
+				}
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			service := NewWebhookServiceWithClient(webhooks, deliveries, server.Client(), sleep)
+			if _, err := service.SendPending(context.Background(), 10); err != nil {
+				t.Fatalf("SendPending: %v", err)
+			}
+
+			if delivery.Status != test.wantStatus {
+				t.Errorf("status: got %s, want %s", delivery.Status, test.wantStatus)
+			}
+			if delivery.Attempts != test.wantAttempts {
+				t.Errorf("attempts: got %d, want %d", delivery.Attempts, test.wantAttempts)
+			}
+			if delivery.StatusCode != test.wantCode {
+				t.Errorf("status code: got %d, want %d", delivery.StatusCode, test.wantCode)
+			}
+			if !reflect.DeepEqual(sleeps, test.wantSleeps) {
+				t.Errorf("sleeps: got %v, want %v", sleeps, test.wantSleeps)
+			}
+		})
+	}
+}
+
+func TestSendPendingFailsDeletedWebhooks(t *testing.T) {
+	deliveries := &memoryDeliveries{}
+	delivery := &domain.WebhookDelivery{ID: "delivery", WebhookID: "deleted", Status: constants.WebhookDeliveryPending}
+	_ = deliveries.Insert(context.Background(), delivery)
+
+	service := NewWebhookServiceWithClient(&memoryWebhooks{webhooks: map[string]*domain.Webhook{}}, deliveries, http.DefaultClient, sleepContext)
+	if _, err := service.SendPending(context.Background(), 10); err != nil {
+		t.Fatalf("SendPending: %v", err)
+	}
+
+	if delivery.Status != constants.WebhookDeliveryFailed || delivery.Attempts != 0 {
+		t.Errorf("got status %s after %d attempts, want failed without attempts", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestSendPendingSkipsDeliveriesClaimedElsewhere(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	t.Cleanup(server.Close)
+
+	webhooks := &memoryWebhooks{webhooks: map[string]*domain.Webhook{
+		"webhook": {ID: "webhook", URL: server.URL, Secret: "secret"},
+	}}
+	deliveries := &memoryDeliveries{claimedElsewhere: map[string]bool{"delivery": true}}
+	delivery := &domain.WebhookDelivery{ID: "delivery", WebhookID: "webhook", Status: constants.WebhookDeliveryPending}
+	_ = deliveries.Insert(context.Background(), delivery)
+
+	service := NewWebhookServiceWithClient(webhooks, deliveries, server.Client(), sleepContext)
+	sent, err := service.SendPending(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("SendPending: %v", err)
+	}
+
+	if sent != 0 || requests != 0 || delivery.Attempts != 0 {
+		t.Errorf("got %d sent and %d requests, want the claimed delivery left to its run", sent, requests)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		wantErr      bool
+	}{
+		{name: "https on a public address", url: "https://93.184.215.14/hooks"},
+		{name: "plain http", url: "http://93.184.215.14/hooks", wantErr: true},
+		{name: "localhost", url: "https://localhost/hooks", wantErr: true},
+		{name: "loopback", url: "https://127.0.0.1:8443/hooks", wantErr: true},
+		{name: "private network", url: "https://10.0.0.1/hooks", wantErr: true},
+		{name: "instance metadata", url: "https://169.254.169.254/latest/meta-data/", wantErr: true},
+		{name: "IPv6 loopback", url: "https://[::1]/hooks", wantErr: true},
+		{name: "no host", url: "https:///hooks", wantErr: true},
+		{name: "local http receiver in development", url: "http://127.0.0.1:8080/hooks", allowPrivate: true},
+		{name: "other schemes in development", url: "ftp://127.0.0.1/hooks", allowPrivate: true, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.allowPrivate {
+				t.Setenv("ALLOW_PRIVATE_URLS", "true")
+			} else {
+				t.Setenv("ALLOW_PRIVATE_URLS", "")
+			}
+
+			err := validateWebhookURL(context.Background(), test.url)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"backend/internal/constants"
+	"backend/internal/events"
+	"backend/internal/xapi"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultXAPIHomePage     = "https://qriosity.app"
+	defaultXAPIActivityBase = "https://qriosity.app/xapi/activities"
+)
+
+// Statement ids are name based UUIDs in this namespace, so an event delivered twice is sent as
+// the same statement and the LRS keeps one
+var xapiStatementNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://qriosity.app/xapi/statements"))
+
+// XAPISubscriber records roadmap progress, course completions and daily challenge results in a
+// Learning Record Store as xAPI statements. Without an LRS configured it subscribes to nothing
+type XAPISubscriber struct {
+	emitter  *xapi.Emitter
+	mapper   *xapi.ActivityMapper
+	homePage string
+	// Each statement is sent by the handler of its event instead of being buffered
+	immediate bool
+}
+
+func NewXAPISubscriber(emitter *xapi.Emitter, mapper *xapi.ActivityMapper, homePage string) *XAPISubscriber {
+	return &XAPISubscriber{
+		emitter:  emitter,
+		mapper:   mapper,
+		homePage: homePage,
+	}
+}
+
+// NewXAPISubscriberFromEnv reads the LRS from XAPI_LRS_ENDPOINT, XAPI_LRS_USERNAME and
+// XAPI_LRS_PASSWORD. XAPI_ACTIVITY_MAP holds a JSON object of explicit activity IRIs
+func NewXAPISubscriberFromEnv() (*XAPISubscriber, error) {
+	endpoint := os.Getenv("XAPI_LRS_ENDPOINT")
+	if endpoint == "" {
+		return &XAPISubscriber{}, nil
+	}
+
+	overrides := make(map[string]string)
+	if activityMap := os.Getenv("XAPI_ACTIVITY_MAP"); activityMap != "" {
+		if err := json.Unmarshal([]byte(activityMap), &overrides); err != nil {
+			return nil, fmt.Errorf("invalid XAPI_ACTIVITY_MAP: %w", err)
+		}
+	}
+
+	batchSize, _ := strconv.Atoi(os.Getenv("XAPI_BATCH_SIZE"))
+	client := xapi.NewClient(endpoint, os.Getenv("XAPI_LRS_USERNAME"), os.Getenv("XAPI_LRS_PASSWORD"))
+
+	return NewXAPISubscriber(
+		xapi.NewEmitter(client, batchSize),
+		xapi.NewActivityMapper(envOrDefault("XAPI_ACTIVITY_BASE", defaultXAPIActivityBase), overrides),
+		envOrDefault("XAPI_ACCOUNT_HOMEPAGE", defaultXAPIHomePage),
+	), nil
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// SendImmediately has every handler send its statement before returning, so an LRS that is
+// down fails the handler. The outbox uses it to retry the xapi handler of the message later
+func (s *XAPISubscriber) SendImmediately() *XAPISubscriber {
+	s.immediate = true
+	return s
+}
+
+func (s *XAPISubscriber) Register(bus events.Subscriber) {
+	if s.emitter == nil {
+		return
+	}
+
+	bus.Subscribe(events.RoadmapProgressedEvent, "xapi", s.onRoadmapProgressed)
+	bus.Subscribe(events.CourseCompletedEvent, "xapi", s.onCourseCompleted)
+	bus.Subscribe(events.RoadmapCompletedEvent, "xapi", s.onRoadmapCompleted)
+	bus.Subscribe(events.ChallengeAnsweredEvent, "xapi", s.onChallengeAnswered)
+}
+
+// Flush sends the statements still buffered, lambdas call it before returning
+func (s *XAPISubscriber) Flush(ctx context.Context) error {
+	if s.emitter == nil {
+		return nil
+	}
+	return s.emitter.Flush(ctx)
+}
+
+func (s *XAPISubscriber) onRoadmapProgressed(ctx context.Context, event events.Event) error {
+	progressed := event.(events.RoadmapProgressed)
+
+	percent := 0
+	if progressed.TotalCourses > 0 {
+		percent = min(100, progressed.Progress*100/progressed.TotalCourses)
+	}
+	completion := percent == 100
+
+	return s.add(ctx, s.statement(ctx, event, progressed.UserID, xapi.Progressed, s.mapper.Roadmap(progressed.RoadmapID), &xapi.Result{
+		Completion: &completion,
+		Extensions: map[string]interface{}{xapi.ProgressExtension: percent},
+	}))
+}
+
+func (s *XAPISubscriber) onCourseCompleted(ctx context.Context, event events.Event) error {
+	completed := event.(events.CourseCompleted)
+	completion := true
+
+	return s.add(ctx, s.statement(ctx, event, completed.UserID, xapi.Completed, s.mapper.Course(completed.CourseID), &xapi.Result{
+		Completion: &completion,
+	}))
+}
+
+func (s *XAPISubscriber) onRoadmapCompleted(ctx context.Context, event events.Event) error {
+	completed := event.(events.RoadmapCompleted)
+	completion := true
+
+	return s.add(ctx, s.statement(ctx, event, completed.UserID, xapi.Completed, s.mapper.Roadmap(completed.RoadmapID), &xapi.Result{
+		Completion: &completion,
+	}))
+}
+
+// onChallengeAnswered reports the rating as the score, answers that keep the streak going are a success
+func (s *XAPISubscriber) onChallengeAnswered(ctx context.Context, event events.Event) error {
+	answered := event.(events.ChallengeAnswered)
+
+	// Generated challenges that never made it to the question bank share one activity
+	questionID := answered.QuestionID
+	if questionID == "" {
+		questionID = "daily"
+	}
+	success := answered.Rating > constants.StreakMinimumRating
+
+	return s.add(ctx, s.statement(ctx, event, answered.UserID, xapi.Answered, s.mapper.Challenge(questionID), &xapi.Result{
+		Score: &xapi.Score{
+			Scaled: float64(answered.Rating) / float64(MaxRating),
+			Raw:    answered.Rating,
+			Min:    MinRating,
+			Max:    MaxRating,
+		},
+		Success: &success,
+	}))
+}
+
+// add buffers the statement, or sends it when sending immediately. Failures that may pass are
+// returned so the handler can be retried, statements the LRS rejects are logged and dropped as
+// another try would be rejected too
+func (s *XAPISubscriber) add(ctx context.Context, statement xapi.Statement) error {
+	var err error
+	if s.immediate {
+		err = s.emitter.Send(ctx, statement)
+	} else {
+		err = s.emitter.Add(ctx, statement)
+	}
+
+	if xapi.IsTransient(err) {
+		return err
+	}
+	if err != nil {
+		log.Printf("LRS rejected xAPI statement %s: %v", statement.ID, err)
+	}
+	return nil
+}
+
+// statement is identified by the message and dated when the event happened. Outside of a bus
+// handler it gets a random id and the current time
+func (s *XAPISubscriber) statement(ctx context.Context, event events.Event, userID string, verb xapi.Verb, object xapi.Activity, result *xapi.Result) xapi.Statement {
+	id := uuid.New()
+	timestamp := time.Now().UTC()
+	if message, ok := events.MessageFrom(ctx); ok {
+		id = uuid.NewSHA1(xapiStatementNamespace, []byte(message.ID+"/"+event.EventName()))
+		timestamp = message.OccurredAt.UTC()
+	}
+
+	return xapi.Statement{
+		ID: id.String(),
+		Actor: xapi.Agent{
+			ObjectType: "Agent",
+			Account:    xapi.Account{HomePage: s.homePage, Name: userID},
+		},
+		Verb:      verb,
+		Object:    object,
+		Result:    result,
+		Timestamp: timestamp.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"backend/internal/events"
+	"backend/internal/xapi"
+	"context"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingSender struct {
+	statements []xapi.Statement
+	err        error
+}
+
+func (r *recordingSender) Send(ctx context.Context, statements []xapi.Statement) error {
+	r.statements = append(r.statements, statements...)
+	return r.err
+}
+
+// subscriptions keeps the handlers registered per event, to call them directly
+type subscriptions map[string]events.Handler
+
+func (s subscriptions) Subscribe(event, handlerName string, handler events.Handler) {
+	s[event] = handler
+}
+
+func newTestXAPISubscriber(sender *recordingSender) *XAPISubscriber {
+	return NewXAPISubscriber(
+		xapi.NewEmitter(sender, 1),
+		xapi.NewActivityMapper("https://lrs.example/activities", map[string]string{"course:mapped": "https://lms.example/courses/42"}),
+		"https://qriosity.app",
+	)
+}
+
+func TestXAPIStatements(t *testing.T) {
+	yes, no := true, false
+	actor := xapi.Agent{ObjectType: "Agent", Account: xapi.Account{HomePage: "https://qriosity.app", Name: "ada"}}
+	activity := func(path, activityType string) xapi.Activity {
+		return xapi.Activity{ObjectType: "Activity", ID: "https://lrs.example/activities/" + path, Definition: &xapi.ActivityDefinition{Type: activityType}}
+	}
+
+	tests := []struct {
+		name  string
+		event events.Event
+		want  xapi.Statement
+	}{
+		{
+			name:  "roadmap progress",
+			event: events.RoadmapProgressed{UserID: "ada", RoadmapID: "go", Progress: 3, TotalCourses: 4},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Progressed,
+				Object: activity("roadmaps/go", xapi.CourseActivityType),
+				Result: &xapi.Result{Completion: &no, Extensions: map[string]interface{}{xapi.ProgressExtension: 75}},
+			},
+		},
+		{
+			name:  "last course of a roadmap",
+			event: events.RoadmapProgressed{UserID: "ada", RoadmapID: "go", Progress: 4, TotalCourses: 4},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Progressed,
+				Object: activity("roadmaps/go", xapi.CourseActivityType),
+				Result: &xapi.Result{Completion: &yes, Extensions: map[string]interface{}{xapi.ProgressExtension: 100}},
+			},
+		},
+		{
+			name:  "course with an explicit activity",
+			event: events.CourseCompleted{UserID: "ada", RoadmapID: "go", CourseID: "mapped"},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Completed,
+				Object: xapi.Activity{ObjectType: "Activity", ID: "https://lms.example/courses/42", Definition: &xapi.ActivityDefinition{Type: xapi.ModuleActivityType}},
+				Result: &xapi.Result{Completion: &yes},
+			},
+		},
+		{
+			name:  "roadmap completed",
+			event: events.RoadmapCompleted{UserID: "ada", RoadmapID: "go"},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Completed,
+				Object: activity("roadmaps/go", xapi.CourseActivityType),
+				Result: &xapi.Result{Completion: &yes},
+			},
+		},
+		{
+			name:  "good challenge answer",
+			event: events.ChallengeAnswered{UserID: "ada", QuestionID: "q1", Rating: 8},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Answered,
+				Object: activity("challenges/q1", xapi.QuestionActivityType),
+				Result: &xapi.Result{Score: &xapi.Score{Scaled: 0.8, Raw: 8, Min: MinRating, Max: MaxRating}, Success: &yes},
+			},
+		},
+		{
+			name:  "generated challenge answered poorly",
+			event: events.ChallengeAnswered{UserID: "ada", Rating: 2},
+			want: xapi.Statement{
+				Actor:  actor,
+				Verb:   xapi.Answered,
+				Object: activity("challenges/daily", xapi.QuestionActivityType),
+				Result: &xapi.Result{Score: &xapi.Score{Scaled: 0.2, Raw: 2, Min: MinRating, Max: MaxRating}, Success: &no},
+			},
+		},
+	}
+
+	occurredAt := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sender := &recordingSender{}
+			handlers := subscriptions{}
+			newTestXAPISubscriber(sender).Register(handlers)
+
+			// The same message handled twice, as after a redelivery, then another occurrence
+			message := events.Message{ID: "message-1", OccurredAt: occurredAt}
+			for _, ctx := range []context.Context{
+				events.WithMessage(context.Background(), message),
+				events.WithMessage(context.Background(), message),
+				events.WithMessage(context.Background(), events.Message{ID: "message-2", OccurredAt: occurredAt}),
+			} {
+				if err := handlers[test.event.EventName()](ctx, test.event); err != nil {
+					t.Fatalf("handler: %v", err)
+				}
+			}
+
+			if len(sender.statements) != 3 {
+				t.Fatalf("got %d statements, want 3", len(sender.statements))
+			}
+			first, again, other := sender.statements[0], sender.statements[1], sender.statements[2]
+
+			id, err := uuid.Parse(first.ID)
+			if err != nil || id.Version() != 5 {
+				t.Errorf("got id %s, want a name based UUID", first.ID)
+			}
+			if again.ID != first.ID {
+				t.Errorf("redelivery got id %s, want %s", again.ID, first.ID)
+			}
+			if other.ID == first.ID {
+				t.Errorf("another occurrence reused id %s", first.ID)
+			}
+
+			test.want.ID = first.ID
+			test.want.Timestamp = "2024-01-15T12:30:00Z"
+			if !reflect.DeepEqual(first, test.want) {
+				t.Errorf("got %+v, want %+v", first, test.want)
+			}
+		})
+	}
+}
+
+type statusDoer int
+
+func (d statusDoer) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: int(d), Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestXAPILRSErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantFail bool
+	}{
+		{name: "LRS down fails the handler so it is retried", status: http.StatusServiceUnavailable, wantFail: true},
+		{name: "rejected statement is dropped", status: http.StatusBadRequest, wantFail: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := xapi.NewClientWithHTTP("https://lrs.example", "", "", statusDoer(test.status), func(time.Duration) {})
+			subscriber := NewXAPISubscriber(
+				xapi.NewEmitter(client, xapi.DefaultBatchSize),
+				xapi.NewActivityMapper("https://lrs.example/activities", nil),
+				"https://qriosity.app",
+			).SendImmediately()
+			ctx := events.WithMessage(context.Background(), events.Message{ID: "message-1", OccurredAt: time.Now()})
+
+			err := subscriber.onRoadmapCompleted(ctx, events.RoadmapCompleted{UserID: "ada", RoadmapID: "go"})
+			if (err != nil) != test.wantFail {
+				t.Errorf("got %v, want failure %v", err, test.wantFail)
+			}
+		})
+	}
+}
@@ -90,7 +90,7 @@ func getRSAPublicKey(kid string) (*rsa.PublicKey, error) {
 	return key, nil
 }
 
-// Step 2: Validate JWT token using Cognito's public keys
+// Step 2: Validate JWT token using Cognito's public keys, returning the username it was issued to
 func validateToken(tokenString string) (string, error) {
 
 	if rsaPublicKeys == nil {
@@ -131,7 +131,11 @@ func validateToken(tokenString string) (string, error) {
 			return "", errors.New("invalid token audience")
 		}
 
-		return "Authorized", nil
+		username, _ := claims["cognito:username"].(string)
+		if username == "" {
+			return "", errors.New("token has no username")
+		}
+		return username, nil
 	}
 
 	return "", errors.New("invalid token")
@@ -149,6 +153,16 @@ func CheckAuthorization(ctx context.Context, event AppSyncEvent) error {
 	return err
 }
 
+// CallerUsername validates the token of the request and returns the Cognito username of the caller
+func CallerUsername(event AppSyncEvent) (string, error) {
+	tokenString, exists := event.Headers["authorization"]
+	if !exists {
+		return "", errors.New("authorization header missing")
+	}
+
+	return validateToken(tokenString)
+}
+
 func CheckAuthorizationTokenOnly(ctx context.Context, token string) error {
 	// Validate the token using the existing validateToken function
 	_, err := validateToken(token)
@@ -165,3 +179,24 @@ func SecureResolver(ctx context.Context, event AppSyncEvent, resolver func(conte
 	response, err := resolver(ctx, event.Arguments)
 	return response, err
 }
+
+// SecureSelfResolver is SecureResolver for operations users can only run for themselves, it
+// refuses calls whose userId argument is not the caller's username
+func SecureSelfResolver(ctx context.Context, event AppSyncEvent, resolver func(context.Context, json.RawMessage) (json.RawMessage, error)) (json.RawMessage, error) {
+	caller, err := CallerUsername(event)
+	if err != nil {
+		return nil, err
+	}
+
+	var args struct {
+		UserID string `json:"userId"`
+	}
+	if err := json.Unmarshal(event.Arguments, &args); err != nil {
+		return nil, err
+	}
+	if args.UserID != caller {
+		return nil, errors.New("user is not authorized to act for another user")
+	}
+
+	return resolver(ctx, event.Arguments)
+}
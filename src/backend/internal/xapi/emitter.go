@@ -0,0 +1,191 @@
+package xapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sendMaxAttempts    = 3
+	sendInitialBackoff = 500 * time.Millisecond
+	DefaultBatchSize   = 20
+)
+
+// HTTPDoer is the subset of *http.Client used to reach the LRS, so it can be pointed at a stub
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client posts statements to the statements resource of an LRS with basic auth
+type Client struct {
+	endpoint string
+	username string
+	password string
+	http     HTTPDoer
+	sleep    func(time.Duration)
+}
+
+func NewClient(endpoint, username, password string) *Client {
+	return NewClientWithHTTP(endpoint, username, password, &http.Client{Timeout: 10 * time.Second}, time.Sleep)
+}
+
+func NewClientWithHTTP(endpoint, username, password string, doer HTTPDoer, sleep func(time.Duration)) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		username: username,
+		password: password,
+		http:     doer,
+		sleep:    sleep,
+	}
+}
+
+// errPermanent marks answers retrying cannot fix, such as a statement the LRS rejects
+var errPermanent = errors.New("rejected by the LRS")
+
+// IsTransient tells whether sending may succeed later: the LRS could not be reached or was
+// failing for at least one of the batches behind err, rather than rejecting them all
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			if IsTransient(err) {
+				return true
+			}
+		}
+		return false
+	}
+	return !errors.Is(err, errPermanent)
+}
+
+// Send posts the statements as one batch. Network errors, 429 and 5xx answers are retried
+// with exponential backoff, other errors are returned straight away
+func (c *Client) Send(ctx context.Context, statements []Statement) error {
+	body, err := json.Marshal(statements)
+	if err != nil {
+		return err
+	}
+
+	backoff := sendInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err = c.post(ctx, body)
+		if err == nil || errors.Is(err, errPermanent) || attempt == sendMaxAttempts {
+			return err
+		}
+
+		c.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (c *Client) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/statements", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Experience-API-Version", Version)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("LRS answered %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	return err
+}
+
+type sender interface {
+	Send(ctx context.Context, statements []Statement) error
+}
+
+// Emitter buffers statements and sends them in batches. A full batch is sent as soon as it
+// fills up, the rest when Flush is called, which lambdas do before returning
+type Emitter struct {
+	client    sender
+	batchSize int
+
+	mu     sync.Mutex
+	buffer []Statement
+}
+
+func NewEmitter(client sender, batchSize int) *Emitter {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Emitter{client: client, batchSize: batchSize}
+}
+
+func (e *Emitter) Add(ctx context.Context, statements ...Statement) error {
+	e.mu.Lock()
+	e.buffer = append(e.buffer, statements...)
+	if len(e.buffer) < e.batchSize {
+		e.mu.Unlock()
+		return nil
+	}
+	batch := e.buffer
+	e.buffer = nil
+	e.mu.Unlock()
+
+	return e.send(ctx, batch)
+}
+
+// Send sends the statements right away as one batch, leaving the buffer alone. The caller
+// decides what to do with statements that could not be sent
+func (e *Emitter) Send(ctx context.Context, statements ...Statement) error {
+	return e.client.Send(ctx, statements)
+}
+
+func (e *Emitter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.buffer
+	e.buffer = nil
+	e.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return e.send(ctx, batch)
+}
+
+// send splits the buffer into batches. Statements the LRS keeps failing on are dropped rather
+// than held on to, a lambda may not be around for another try
+func (e *Emitter) send(ctx context.Context, statements []Statement) error {
+	var errs []error
+	for start := 0; start < len(statements); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(statements) {
+			end = len(statements)
+		}
+
+		if err := e.client.Send(ctx, statements[start:end]); err != nil {
+			log.Printf("Dropping %d xAPI statements: %v", end-start, err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+package xapi
+
+import (
+	"strings"
+)
+
+// Version is the xAPI version sent to the LRS with every request
+const Version = "1.0.3"
+
+// Statement is an xAPI statement, only the parts QRiosity emits are modelled
+type Statement struct {
+	ID        string   `json:"id"`
+	Actor     Agent    `json:"actor"`
+	Verb      Verb     `json:"verb"`
+	Object    Activity `json:"object"`
+	Result    *Result  `json:"result,omitempty"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// Agent identifies a learner by their QRiosity account rather than by email
+type Agent struct {
+	ObjectType string  `json:"objectType"`
+	Account    Account `json:"account"`
+}
+
+type Account struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+type Verb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display"`
+}
+
+type Activity struct {
+	ObjectType string              `json:"objectType"`
+	ID         string              `json:"id"`
+	Definition *ActivityDefinition `json:"definition,omitempty"`
+}
+
+type ActivityDefinition struct {
+	Type string `json:"type"`
+}
+
+type Result struct {
+	Score      *Score                 `json:"score,omitempty"`
+	Success    *bool                  `json:"success,omitempty"`
+	Completion *bool                  `json:"completion,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type Score struct {
+	Scaled float64 `json:"scaled"`
+	Raw    int     `json:"raw"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+}
+
+var (
+	Progressed = Verb{ID: "http://adlnet.gov/expapi/verbs/progressed", Display: map[string]string{"en-US": "progressed"}}
+	Completed  = Verb{ID: "http://adlnet.gov/expapi/verbs/completed", Display: map[string]string{"en-US": "completed"}}
+	Answered   = Verb{ID: "http://adlnet.gov/expapi/verbs/answered", Display: map[string]string{"en-US": "answered"}}
+)
+
+const (
+	CourseActivityType   = "http://adlnet.gov/expapi/activities/course"
+	ModuleActivityType   = "http://adlnet.gov/expapi/activities/module"
+	QuestionActivityType = "http://adlnet.gov/expapi/activities/question"
+
+	// ProgressExtension carries the completion percentage of a progressed statement
+	ProgressExtension = "https://w3id.org/xapi/cmi5/result/extensions/progress"
+)
+
+// ActivityMapper turns QRiosity ids into activity IRIs. Customers whose LRS already knows a
+// roadmap or course under its own IRI map it explicitly, e.g. "roadmap:<id>": "<iri>"
+type ActivityMapper struct {
+	base      string
+	overrides map[string]string
+}
+
+func NewActivityMapper(base string, overrides map[string]string) *ActivityMapper {
+	return &ActivityMapper{
+		base:      strings.TrimRight(base, "/"),
+		overrides: overrides,
+	}
+}
+
+// Roadmaps are courses in xAPI terms, their QRiosity courses are modules
+func (m *ActivityMapper) Roadmap(id string) Activity {
+	return m.activity("roadmap", "roadmaps", id, CourseActivityType)
+}
+
+func (m *ActivityMapper) Course(id string) Activity {
+	return m.activity("course", "courses", id, ModuleActivityType)
+}
+
+func (m *ActivityMapper) Challenge(id string) Activity {
+	return m.activity("challenge", "challenges", id, QuestionActivityType)
+}
+
+func (m *ActivityMapper) activity(kind, path, id, activityType string) Activity {
+	iri, ok := m.overrides[kind+":"+id]
+	if !ok {
+		iri = m.base + "/" + path + "/" + id
+	}
+
+	return Activity{
+		ObjectType: "Activity",
+		ID:         iri,
+		Definition: &ActivityDefinition{Type: activityType},
+	}
+}
@@ -17,7 +17,7 @@ func TestGetBulkByUrl() {
 		log.Fatal(err)
 	}
 
-	courseRepository := repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses")
+	courseRepository := repository.NewDynamoDBCourseRepository(sess, "Qriosity-Courses", repository.NewDynamoDBTopicEdgeRepository(sess, "Qriosity-TopicEdges"), repository.NewDynamoDBFacetCountRepository(sess, "Qriosity-FacetCounts"))
 
 	urls := []string{"https://www.coursera.org/learn/learning-how-to-learn", "https://www.coursera.org/learn/machine-learning"}
 
@@ -2,7 +2,7 @@
 import React, {useEffect, useState} from 'react';
 import {Course} from './Course.tsx';
 import {useApolloClient} from "@apollo/client";
-import LearningService from "./LearningService.tsx";
+import LearningService, {PAGE_SIZE, PageInfo} from "./LearningService.tsx";
 import AuthService from "../auth/AuthService.tsx";
 import './CourseSelector.css';
 
@@ -19,31 +19,35 @@ const CourseSelector: React.FC<CourseSelectorProps> = ({isOpen, onClose, onCours
 	const authService = new AuthService(apolloClient);
 
 	const [courses, setCourses] = useState<Course[]>([]);
-	const [pagination, setPagination] = useState({page: 0, perPage: 12, lastEvaluatedKey: null as string | null});
+	// cursors holds the after cursor of every page up to the current one, the first page has none
+	const [cursors, setCursors] = useState<(string | null)[]>([null]);
+	const [pageInfo, setPageInfo] = useState<PageInfo>({hasNextPage: false, endCursor: null});
 
-	const fetchCourses = () => {
+	const fetchCourses = (after: string | null) => {
 		const cognitoUsername = authService.getCognitoUsername() as string;
-		learningService.getCourses(cognitoUsername, pagination).then((result) => {
-			setCourses(result.courses);
-			setPagination(result.pagination);
+		learningService.getCourses(cognitoUsername, PAGE_SIZE, after).then((result) => {
+			setCourses(result.items);
+			setPageInfo(result.pageInfo);
 		}).catch((error) => {
 			console.error(error);
 		});
 	};
 
 	useEffect(() => {
-		fetchCourses();
+		fetchCourses(null);
 	}, []);
 
 	const handleNextPage = () => {
-		setPagination(prev => ({...prev, page: prev.page + 1}));
-		console.log(pagination);
-		fetchCourses();
+		if (!pageInfo.hasNextPage) return;
+		setCursors(prev => [...prev, pageInfo.endCursor]);
+		fetchCourses(pageInfo.endCursor);
 	};
 
 	const handlePreviousPage = () => {
-		setPagination(prev => ({...prev, page: Math.max(prev.page - 1, 0)}));
-		fetchCourses();
+		if (cursors.length < 2) return;
+		const previous = cursors.slice(0, -1);
+		setCursors(previous);
+		fetchCourses(previous[previous.length - 1]);
 	};
 
 	if (!isOpen) return null;
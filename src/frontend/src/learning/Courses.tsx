@@ -1,7 +1,7 @@
 import './Courses.css';
 import {useEffect, useState} from "react";
 import {useApolloClient} from "@apollo/client";
-import LearningService from "./LearningService.tsx";
+import LearningService, {PAGE_SIZE, PageInfo} from "./LearningService.tsx";
 import AuthService from "../auth/AuthService.tsx";
 import {Course} from "./Course.tsx";
 
@@ -12,30 +12,35 @@ const Courses = () => {
 	const authService = new AuthService(client);
 
 	const [courses, setCourses] = useState<Course[]>([]);
-	const [pagination, setPagination] = useState({page: 0, perPage: 12, lastEvaluatedKey: null as string | null});
+	// cursors holds the after cursor of every page up to the current one, the first page has none
+	const [cursors, setCursors] = useState<(string | null)[]>([null]);
+	const [pageInfo, setPageInfo] = useState<PageInfo>({hasNextPage: false, endCursor: null});
 
-	const fetchCourses = () => {
+	const fetchCourses = (after: string | null) => {
 		const cognitoUsername = authService.getCognitoUsername() as string;
-		learningService.getCourses(cognitoUsername, pagination).then((result) => {
-			setCourses(result.courses);
-			setPagination(result.pagination);
+		learningService.getCourses(cognitoUsername, PAGE_SIZE, after).then((result) => {
+			setCourses(result.items);
+			setPageInfo(result.pageInfo);
 		}).catch((error) => {
 			console.error(error);
 		});
 	};
 
 	useEffect(() => {
-		fetchCourses();
+		fetchCourses(null);
 	}, []);
 
 	const handleNextPage = () => {
-		setPagination(prev => ({...prev, page: prev.page + 1}));
-		fetchCourses();
+		if (!pageInfo.hasNextPage) return;
+		setCursors(prev => [...prev, pageInfo.endCursor]);
+		fetchCourses(pageInfo.endCursor);
 	};
 
 	const handlePreviousPage = () => {
-		setPagination(prev => ({...prev, page: Math.max(prev.page - 1, 0)}));
-		fetchCourses();
+		if (cursors.length < 2) return;
+		const previous = cursors.slice(0, -1);
+		setCursors(previous);
+		fetchCourses(previous[previous.length - 1]);
 	};
 
 	const handleCardClick = (url: string) => {
@@ -1,6 +1,7 @@
 import {ApolloClient, gql} from '@apollo/client';
 import AuthService from '../auth/AuthService';
 import {Roadmap} from "./Roadmap.tsx";
+import {Course} from "./Course.tsx";
 
 const GET_NAVBAR_DATA = gql`
     query GetNavbarData($name: String!) {
@@ -13,45 +14,56 @@ const GET_NAVBAR_DATA = gql`
 
 
 const GET_COURSES = gql`
-    query GetCourses($userId: String!, $pagination: PaginationInput!) {
-        getCourses(userId: $userId, pagination: $pagination) {
-            courses {
-                id
-                title
-                url
-                description
-                difficulty
-                topics
-                isFree
-                duration
-                language
+    query GetCourses($userId: String!, $first: Int, $after: String) {
+        getCourses(userId: $userId, first: $first, after: $after) {
+            edges {
+                node {
+                    id
+                    title
+                    url
+                    description
+                    difficulty
+                    topics
+                    isFree
+                    duration
+                    language
+                }
+                cursor
             }
-            pagination {
-                page
-                perPage
-                lastEvaluatedKey
+            pageInfo {
+                hasNextPage
+                endCursor
             }
         }
     }
 `;
 
 const GET_ROADMAPS = gql`
-    query GetRoadmaps {
-        getRoadmaps {
-            id
-            title
-            author
-            topics
-            isCustom
-            likes
-            difficulty
+    query GetRoadmaps($first: Int, $after: String) {
+        getRoadmaps(first: $first, after: $after) {
+            edges {
+                node {
+                    id
+                    title
+                    author
+                    topics
+                    isCustom
+                    likes
+                    difficulty
+                }
+                cursor
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
         }
     }
 `;
 
 const GET_ROADMAP_BY_ID = gql`
-    query GetRoadmapById($id: ID!, $userId: String!, $from: String) {
-        getRoadmapById(id: $id, userId: $userId, from: $from) {
+    query GetRoadmapById($id: ID!, $userId: String!) {
+        getRoadmapById(id: $id, userId: $userId) {
             id
             title
             author
@@ -97,16 +109,25 @@ const USER_LIKED_ROADMAP = gql`
 `;
 
 const GET_ROADMAPS_BY_USER = gql`
-    query GetRoadmapsByUser($userId: String!) {
-        getRoadmapsByUser(userId: $userId) {
-            id
-            title
-            author
-            topics
-            isCustom
-            likes
-            difficulty
-            imageUrl
+    query GetRoadmapsByUser($userId: String!, $first: Int, $after: String) {
+        getRoadmapsByUser(userId: $userId, first: $first, after: $after) {
+            edges {
+                node {
+                    id
+                    title
+                    author
+                    topics
+                    isCustom
+                    likes
+                    difficulty
+                    imageUrl
+                }
+                cursor
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
         }
     }
 `;
@@ -133,21 +154,63 @@ const CUSTOM_ROADMAP_REQUESTED = gql`
 `;
 
 const GET_ROADMAP_FEED = gql`
-    query GetRoadmapFeed($userId: String!) {
-        getRoadmapFeed(userId: $userId) {
-            id
-            title
-            author
-            topics
-            isCustom
-            likes
-            difficulty
-            liked
-            imageUrl
+    query GetRoadmapFeed($userId: String!, $first: Int, $after: String) {
+        getRoadmapFeed(userId: $userId, first: $first, after: $after) {
+            edges {
+                node {
+                    id
+                    title
+                    author
+                    topics
+                    isCustom
+                    likes
+                    difficulty
+                    liked
+                    imageUrl
+                }
+                cursor
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
         }
     }
 `;
 
+// Lists are returned as connections, pages are requested with first and the endCursor of the last
+export interface PageInfo {
+	hasNextPage: boolean;
+	endCursor: string | null;
+}
+
+export interface Page<T> {
+	items: T[];
+	pageInfo: PageInfo;
+}
+
+export const PAGE_SIZE = 12;
+// The server returns at most 100 items per page
+const MAX_PAGE_SIZE = 100;
+
+export function toPage<T>(connection: { edges: { node: T }[], pageInfo: PageInfo }): Page<T> {
+	return {items: connection.edges.map((edge) => edge.node), pageInfo: connection.pageInfo};
+}
+
+// readAllPages follows the cursors of a short list until its last page
+export async function readAllPages<T>(readPage: (after: string | null) => Promise<Page<T>>): Promise<T[]> {
+	const items: T[] = [];
+	let after: string | null = null;
+	while (true) {
+		const page = await readPage(after);
+		items.push(...page.items);
+		if (!page.pageInfo.hasNextPage || !page.pageInfo.endCursor) {
+			return items;
+		}
+		after = page.pageInfo.endCursor;
+	}
+}
+
 class LearningService {
 	private client: ApolloClient<any>;
 	private authService: AuthService;
@@ -166,29 +229,20 @@ class LearningService {
 		return data.getUserByName;
 	}
 
-	async getCourses(userId: string, pagination: {
-		page: number,
-		perPage: number,
-		lastEvaluatedKey?: any
-	}): Promise<any> {
-		let temp = {
-			page: pagination.page,
-			perPage: pagination.perPage,
-			lastEvaluatedKey: pagination.lastEvaluatedKey
-		};
-
+	async getCourses(userId: string, first: number, after: string | null): Promise<Page<Course>> {
 		const {data} = await this.client.query({
 			query: GET_COURSES,
-			variables: {userId: userId, pagination: temp},
+			variables: {userId, first, after},
 		});
-		return data.getCourses;
+		return toPage<Course>(data.getCourses);
 	}
 
-	async getRoadmaps(): Promise<any[]> {
+	async getRoadmaps(first: number, after: string | null): Promise<Page<any>> {
 		const {data} = await this.client.query({
 			query: GET_ROADMAPS,
+			variables: {first, after},
 		});
-		return data.getRoadmaps;
+		return toPage<any>(data.getRoadmaps);
 	}
 
 	async getRoadmapById(id: string): Promise<Roadmap> {
@@ -228,21 +282,24 @@ class LearningService {
 		return data.userLikedRoadmap.success;
 	}
 
+	// A user follows few roadmaps, all of them are read
 	async getRoadmapsByUser(userId: string): Promise<any[]> {
-		const {data} = await this.client.query({
-			query: GET_ROADMAPS_BY_USER,
-			variables: {userId},
+		return readAllPages(async (after) => {
+			const {data} = await this.client.query({
+				query: GET_ROADMAPS_BY_USER,
+				variables: {userId, first: MAX_PAGE_SIZE, after},
+			});
+			return toPage<any>(data.getRoadmapsByUser);
 		});
-		return data.getRoadmapsByUser;
 	}
 
-	async getRoadmapFeed(userId: string): Promise<any> {
+	async getRoadmapFeed(userId: string, first: number, after: string | null): Promise<Page<any>> {
 		const {data} = await this.client.query({
 			query: GET_ROADMAP_FEED,
-			variables: {userId},
+			variables: {userId, first, after},
 			fetchPolicy: 'no-cache',
 		});
-		return data.getRoadmapFeed;
+		return toPage<any>(data.getRoadmapFeed);
 	}
 
 	async customRoadmapRequested(prompt: string, userId: string): Promise<any> {
@@ -1,6 +1,6 @@
 import {useEffect, useState} from "react";
 import {useApolloClient} from "@apollo/client";
-import LearningService from "./LearningService.tsx";
+import LearningService, {PAGE_SIZE} from "./LearningService.tsx";
 import {Roadmap} from "./Roadmap.tsx";
 import RoadmapList from "./RoadmapList.tsx";
 import AuthService from "../auth/AuthService.tsx";
@@ -14,8 +14,8 @@ const Roadmaps = () => {
 	const [roadmaps, setRoadmaps] = useState<Roadmap[]>([]);
 
 	useEffect(() => {
-		learningService.getRoadmapFeed(authService.getCognitoUsername() as string).then((roadmaps) => {
-			setRoadmaps(roadmaps);
+		learningService.getRoadmapFeed(authService.getCognitoUsername() as string, PAGE_SIZE, null).then((feed) => {
+			setRoadmaps(feed.items);
 		}).catch((error) => {
 			console.error(error);
 		});
@@ -1,4 +1,22 @@
 import { ApolloClient, gql } from '@apollo/client';
+import { readAllPages, toPage } from './LearningService.tsx';
+
+const GET_ALL_TOPICS = gql`
+    query GetAllTopics($first: Int, $after: String) {
+        getAllTopics(first: $first, after: $after) {
+            edges {
+                node {
+                    name
+                }
+                cursor
+            }
+            pageInfo {
+                hasNextPage
+                endCursor
+            }
+        }
+    }
+`;
 
 class TopicService {
     private client: ApolloClient<any>;
@@ -7,17 +25,13 @@ class TopicService {
         this.client = client;
     }
 
+    // Registration offers every topic, all pages are read
     async getAllTopics(): Promise<string[]> {
-        const query = gql`
-            query {
-                getAllTopics {
-                    name
-                }
-            }
-        `;
-
-        const response = await this.client.query({ query });
-		return response.data.getAllTopics.map((topic: { name: string }) => topic.name)
+        const topics = await readAllPages(async (after) => {
+            const response = await this.client.query({ query: GET_ALL_TOPICS, variables: { first: 100, after } });
+            return toPage<{ name: string }>(response.data.getAllTopics);
+        });
+        return topics.map((topic) => topic.name);
     }
 }
 